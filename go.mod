@@ -4,7 +4,7 @@ go 1.17
 
 require (
 	github.com/nikolaydubina/multiline-jsonl v1.0.3 // indirect
-	golang.org/x/exp v0.0.0-20211119212139-c35f6fddc5c3 // indirect
+	golang.org/x/exp v0.0.0-20211119212139-c35f6fddc5c3
 	gonum.org/v1/gonum v0.9.3
 )
 
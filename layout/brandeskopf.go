@@ -10,11 +10,21 @@ import (
 // Computes horizontal coordinate in layered graph, given ordering within each layer.
 // Produces result such that neighbors are close and long edges cross Layers are straight.
 // Works on fully connected graphs.
-// Assuming nodes do not have width.
+// Separation between neighbors in layer is computed from edges of their boxes,
+// real nodes have width Node.W and fake nodes have width DummyWidth, or make space for label of edge.
+// Nodes in block are shifted from each other, so that aligned segments with ports are straight.
+// Borders of clusters are straightened after, pushing nodes to the right when needed.
 type BrandesKopfLayersNodesHorizontalAssigner struct {
-	Delta int // distance between nodes, including fake ones
+	Delta      int // distance between boxes of nodes, including fake ones
+	DummyWidth int // width of fake nodes
 }
 
+// separation is minimal distance between centers of left and right neighbors in layer.
+type separation func(left, right uint64) int
+
+// portShift is distance of ports of {start, end} of segment from centers of nodes.
+type portShift func(segment [2]uint64) [2]int
+
 type Neighbors struct {
 	Up   map[uint64][]uint64
 	Down map[uint64][]uint64
@@ -26,18 +36,15 @@ func computeOrderedNeighbors(g LayeredGraph) Neighbors {
 		Down: make(map[uint64][]uint64),
 	}
 
-	for e := range g.Segments {
-		n.Down[e[0]] = append(n.Down[e[0]], e[1])
-		n.Up[e[1]] = append(n.Up[e[1]], e[0])
-	}
-	for _, d := range n.Down {
-		sort.Slice(d, func(i, j int) bool { return g.NodeYX[d[i]][1] < g.NodeYX[d[j]][1] })
-	}
-
-	for _, d := range n.Up {
-		sort.Slice(d, func(i, j int) bool { return g.NodeYX[d[i]][1] < g.NodeYX[d[j]][1] })
+	adj := g.adjacency()
+	for v := range g.NodeYX {
+		if up := g.neighbors(v, adj.up[v], -1); len(up) > 0 {
+			n.Up[v] = up
+		}
+		if down := g.neighbors(v, adj.down[v], 1); len(down) > 0 {
+			n.Down[v] = down
+		}
 	}
-
 	return n
 }
 
@@ -50,14 +57,44 @@ func (x LayoutResult) width() int {
 	return x.maxX - x.minX
 }
 
-func (s BrandesKopfLayersNodesHorizontalAssigner) NodesHorizontalCoordinates(_ Graph, g LayeredGraph) map[uint64]int {
+// nodeWidth is width of real node or width of fake node.
+func (s BrandesKopfLayersNodesHorizontalAssigner) nodeWidth(g Graph, lg LayeredGraph, node uint64) int {
+	if w, _, ok := lg.labelSize(g, node); ok {
+		return w
+	}
+	if lg.Dummy[node] {
+		return s.DummyWidth
+	}
+	return g.Nodes[node].W
+}
+
+// separation makes boxes of neighbors to be at least Delta apart.
+// Centers are at x, and boxes start at x - W/2, same as when exporting coordinates.
+func (s BrandesKopfLayersNodesHorizontalAssigner) separation(g Graph, lg LayeredGraph) separation {
+	return func(left, right uint64) int {
+		wl := s.nodeWidth(g, lg, left)
+		wr := s.nodeWidth(g, lg, right)
+		return (wl - wl/2) + wr/2 + s.Delta
+	}
+}
+
+func (s BrandesKopfLayersNodesHorizontalAssigner) NodesHorizontalCoordinates(graph Graph, g LayeredGraph) map[uint64]int {
 	neighbors := computeOrderedNeighbors(g)
 	typeOneSegments := preprocessing(g, neighbors)
+	sep := s.separation(graph, g)
+	width := func(node uint64) int { return s.nodeWidth(graph, g, node) }
+	ports := func(segment [2]uint64) [2]int {
+		p := g.Ports[segment]
+		return [2]int{
+			int(math.Round(p[0] * float64(width(segment[0])))),
+			int(math.Round(p[1] * float64(width(segment[1])))),
+		}
+	}
 
-	resTL := runAlgo(TopLeft{}, g, typeOneSegments, neighbors, s)
-	resTR := runAlgo(TopRight{}, g, typeOneSegments, neighbors, s)
-	resBL := runAlgo(BottomLeft{}, g, typeOneSegments, neighbors, s)
-	resBR := runAlgo(BottomRight{}, g, typeOneSegments, neighbors, s)
+	resTL := runAlgo(TopLeft{}, g, typeOneSegments, neighbors, sep, width, ports)
+	resTR := runAlgo(TopRight{}, g, typeOneSegments, neighbors, sep, width, ports)
+	resBL := runAlgo(BottomLeft{}, g, typeOneSegments, neighbors, sep, width, ports)
+	resBR := runAlgo(BottomRight{}, g, typeOneSegments, neighbors, sep, width, ports)
 
 	best := resTL
 	if resTR.width() < best.width() {
@@ -86,30 +123,65 @@ func (s BrandesKopfLayersNodesHorizontalAssigner) NodesHorizontalCoordinates(_ G
 		x[n] = (place[1] + place[2]) / 2
 	}
 
+	straightenBorders(g, x, sep)
 	return x
 }
 
-func runAlgo(dir singleDirAlgo, g LayeredGraph, typeOneSegments map[[2]uint64]bool, neighbors Neighbors, s BrandesKopfLayersNodesHorizontalAssigner) LayoutResult {
+func runAlgo(dir singleDirAlgo, g LayeredGraph, typeOneSegments map[[2]uint64]bool, neighbors Neighbors, sep separation, width func(node uint64) int, ports portShift) LayoutResult {
 	root, align := dir.verticalAlignment(g, typeOneSegments, neighbors)
-	x := dir.horizontalCompaction(g, root, align, s.Delta)
+
+	// blocks are placed by their coordinate, nodes are shifted from it
+	inner := innerShifts(g, root, align, ports)
+	blockSep := func(left, right uint64) int { return sep(left, right) + inner[left] - inner[right] }
+
+	x := dir.horizontalCompaction(g, root, align, blockSep)
+	for n := range x {
+		x[n] += inner[n]
+	}
 
 	res := LayoutResult{
 		x:    x,
 		minX: math.MaxInt,
 		maxX: math.MinInt,
 	}
-	for _, v := range x {
-		if v < res.minX {
-			res.minX = v
+	for n, v := range x {
+		w := width(n)
+		if l := v - w/2; l < res.minX {
+			res.minX = l
 		}
-		if v > res.maxX {
-			res.maxX = v
+		if r := v + (w - w/2); r > res.maxX {
+			res.maxX = r
 		}
 	}
 
 	return res
 }
 
+// innerShifts are distances of nodes from coordinate of their block,
+// such that ports of segments between nodes in block are above each other.
+func innerShifts(g LayeredGraph, root, align map[uint64]uint64, ports portShift) map[uint64]int {
+	inner := make(map[uint64]int, len(g.NodeYX))
+	if len(g.Ports) == 0 {
+		return inner
+	}
+	for v := range g.NodeYX {
+		if root[v] != v {
+			continue
+		}
+		for w := v; align[w] != v; w = align[w] {
+			next := align[w]
+			if g.Segments[[2]uint64{w, next}] {
+				p := ports([2]uint64{w, next})
+				inner[next] = inner[w] + p[0] - p[1]
+			} else {
+				p := ports([2]uint64{next, w})
+				inner[next] = inner[w] + p[1] - p[0]
+			}
+		}
+	}
+	return inner
+}
+
 // Alg 1.
 // Type 1 conflicts arise when a non-inner segment (normal edge) crosses an inner segment (edge between two fake nodes).
 // The algorithm traverses Layers from left to right (index l) while maintaining the upper neighbors,
@@ -160,7 +232,7 @@ func preprocessing(g LayeredGraph, n Neighbors) (typeOneSegments map[[2]uint64]b
 type singleDirAlgo interface {
 	verticalAlignment(g LayeredGraph, typeOneSegments map[[2]uint64]bool, n Neighbors) (root map[uint64]uint64, align map[uint64]uint64)
 
-	horizontalCompaction(g LayeredGraph, root map[uint64]uint64, align map[uint64]uint64, delta int) (x map[uint64]int)
+	horizontalCompaction(g LayeredGraph, root map[uint64]uint64, align map[uint64]uint64, sep separation) (x map[uint64]int)
 }
 
 type TopLeft struct{}
@@ -204,15 +276,17 @@ func (s TopLeft) verticalAlignment(g LayeredGraph, typeOneSegments map[[2]uint64
 }
 
 // part of Alg 3.
-func (s TopLeft) placeBlock(g LayeredGraph, x map[uint64]int, root map[uint64]uint64, align map[uint64]uint64, sink map[uint64]uint64, shift map[uint64]int, delta int, v uint64, layers [][]uint64) {
+func (s TopLeft) placeBlock(g LayeredGraph, x map[uint64]int, root map[uint64]uint64, align map[uint64]uint64, sink map[uint64]uint64, shift map[uint64]int, sep separation, v uint64, layers [][]uint64) {
 	if _, ok := x[v]; !ok {
 		x[v] = 0
 		flag := true
 		w := v
 		for ; flag; flag = v != w {
 			if g.NodeYX[w][1] > 0 {
-				u := root[layers[g.NodeYX[w][0]][g.NodeYX[w][1]-1]]
-				s.placeBlock(g, x, root, align, sink, shift, delta, u, layers)
+				pred := layers[g.NodeYX[w][0]][g.NodeYX[w][1]-1]
+				delta := sep(pred, w)
+				u := root[pred]
+				s.placeBlock(g, x, root, align, sink, shift, sep, u, layers)
 				if sink[v] == v {
 					sink[v] = sink[u]
 				}
@@ -246,7 +320,7 @@ func (s TopLeft) placeBlock(g LayeredGraph, x map[uint64]int, root map[uint64]ui
 // the preceding blocks in the same class, plus minimum separation.
 // For each class, from top to bottom, we then compute the absolute coordinates
 // of its members by placing the class with minimum separation from previously placed classes.
-func (s TopLeft) horizontalCompaction(g LayeredGraph, root map[uint64]uint64, align map[uint64]uint64, delta int) (x map[uint64]int) {
+func (s TopLeft) horizontalCompaction(g LayeredGraph, root map[uint64]uint64, align map[uint64]uint64, sep separation) (x map[uint64]int) {
 	sink := map[uint64]uint64{}
 	shift := map[uint64]int{}
 	x = map[uint64]int{}
@@ -257,10 +331,12 @@ func (s TopLeft) horizontalCompaction(g LayeredGraph, root map[uint64]uint64, al
 	}
 
 	layers := g.Layers()
-	// root coordinates relative to sink
-	for v := range g.NodeYX {
-		if root[v] == v {
-			s.placeBlock(g, x, root, align, sink, shift, delta, v, layers)
+	// root coordinates relative to sink, in order of layers for determinism
+	for _, layer := range layers {
+		for _, v := range layer {
+			if root[v] == v {
+				s.placeBlock(g, x, root, align, sink, shift, sep, v, layers)
+			}
 		}
 	}
 
@@ -282,7 +358,7 @@ func (s TopLeft) horizontalCompaction(g LayeredGraph, root map[uint64]uint64, al
 					j++
 					if g.NodeYX[v][1] > 0 {
 						u := layers[g.NodeYX[v][0]][g.NodeYX[v][1]-1]
-						shifted := shift[sink[v]] + x[v] - (x[u] + delta)
+						shifted := shift[sink[v]] + x[v] - (x[u] + sep(u, v))
 						if shifted < shift[sink[u]] {
 							shift[sink[u]] = shifted
 						}
@@ -351,20 +427,22 @@ func (s TopRight) verticalAlignment(g LayeredGraph, typeOneSegments map[[2]uint6
 }
 
 // part of Alg 3.
-func (s TopRight) placeBlock(g LayeredGraph, x map[uint64]int, root map[uint64]uint64, align map[uint64]uint64, sink map[uint64]uint64, shift map[uint64]int, delta int, v uint64, layers [][]uint64) {
+func (s TopRight) placeBlock(g LayeredGraph, x map[uint64]int, root map[uint64]uint64, align map[uint64]uint64, sink map[uint64]uint64, shift map[uint64]int, sep separation, v uint64, layers [][]uint64) {
 	if _, ok := x[v]; !ok {
 		x[v] = 0
 		flag := true
 		w := v
 		for ; flag; flag = v != w {
 			if g.NodeYX[w][1] < len(layers[g.NodeYX[w][0]])-1 {
-				u := root[layers[g.NodeYX[w][0]][g.NodeYX[w][1]+1]]
-				s.placeBlock(g, x, root, align, sink, shift, delta, u, layers)
+				succ := layers[g.NodeYX[w][0]][g.NodeYX[w][1]+1]
+				delta := sep(w, succ)
+				u := root[succ]
+				s.placeBlock(g, x, root, align, sink, shift, sep, u, layers)
 				if sink[v] == v {
 					sink[v] = sink[u]
 				}
 				if sink[v] != sink[u] {
-					if s := x[v] + x[u] + delta; s > shift[sink[u]] {
+					if s := x[v] - x[u] + delta; s > shift[sink[u]] {
 						shift[sink[u]] = s
 					}
 				} else {
@@ -393,7 +471,7 @@ func (s TopRight) placeBlock(g LayeredGraph, x map[uint64]int, root map[uint64]u
 // the preceding blocks in the same class, plus minimum separation.
 // For each class, from top to bottom, we then compute the absolute coordinates
 // of its members by placing the class with minimum separation from previously placed classes.
-func (s TopRight) horizontalCompaction(g LayeredGraph, root map[uint64]uint64, align map[uint64]uint64, delta int) (x map[uint64]int) {
+func (s TopRight) horizontalCompaction(g LayeredGraph, root map[uint64]uint64, align map[uint64]uint64, sep separation) (x map[uint64]int) {
 	sink := map[uint64]uint64{}
 	shift := map[uint64]int{}
 	x = map[uint64]int{}
@@ -404,10 +482,12 @@ func (s TopRight) horizontalCompaction(g LayeredGraph, root map[uint64]uint64, a
 	}
 
 	layers := g.Layers()
-	// root coordinates relative to sink
-	for v := range g.NodeYX {
-		if root[v] == v {
-			s.placeBlock(g, x, root, align, sink, shift, delta, v, layers)
+	// root coordinates relative to sink, in order of layers for determinism
+	for _, layer := range layers {
+		for _, v := range layer {
+			if root[v] == v {
+				s.placeBlock(g, x, root, align, sink, shift, sep, v, layers)
+			}
 		}
 	}
 
@@ -429,7 +509,7 @@ func (s TopRight) horizontalCompaction(g LayeredGraph, root map[uint64]uint64, a
 					j++
 					if g.NodeYX[v][1] < len(layers[j])-1 {
 						u := layers[g.NodeYX[v][0]][g.NodeYX[v][1]+1]
-						shifted := shift[sink[v]] + x[v] - (x[u] - delta)
+						shifted := shift[sink[v]] + x[v] - (x[u] - sep(v, u))
 						if shifted > shift[sink[u]] {
 							shift[sink[u]] = shifted
 						}
@@ -494,15 +574,17 @@ func (s BottomLeft) verticalAlignment(g LayeredGraph, typeOneSegments map[[2]uin
 }
 
 // part of Alg 3.
-func (s BottomLeft) placeBlock(g LayeredGraph, x map[uint64]int, root map[uint64]uint64, align map[uint64]uint64, sink map[uint64]uint64, shift map[uint64]int, delta int, v uint64, layers [][]uint64) {
+func (s BottomLeft) placeBlock(g LayeredGraph, x map[uint64]int, root map[uint64]uint64, align map[uint64]uint64, sink map[uint64]uint64, shift map[uint64]int, sep separation, v uint64, layers [][]uint64) {
 	if _, ok := x[v]; !ok {
 		x[v] = 0
 		flag := true
 		w := v
 		for ; flag; flag = v != w {
 			if g.NodeYX[w][1] > 0 {
-				u := root[layers[g.NodeYX[w][0]][g.NodeYX[w][1]-1]]
-				s.placeBlock(g, x, root, align, sink, shift, delta, u, layers)
+				pred := layers[g.NodeYX[w][0]][g.NodeYX[w][1]-1]
+				delta := sep(pred, w)
+				u := root[pred]
+				s.placeBlock(g, x, root, align, sink, shift, sep, u, layers)
 				if sink[v] == v {
 					sink[v] = sink[u]
 				}
@@ -536,7 +618,7 @@ func (s BottomLeft) placeBlock(g LayeredGraph, x map[uint64]int, root map[uint64
 // the preceding blocks in the same class, plus minimum separation.
 // For each class, from top to bottom, we then compute the absolute coordinates
 // of its members by placing the class with minimum separation from previously placed classes.
-func (s BottomLeft) horizontalCompaction(g LayeredGraph, root map[uint64]uint64, align map[uint64]uint64, delta int) (x map[uint64]int) {
+func (s BottomLeft) horizontalCompaction(g LayeredGraph, root map[uint64]uint64, align map[uint64]uint64, sep separation) (x map[uint64]int) {
 	sink := map[uint64]uint64{}
 	shift := map[uint64]int{}
 	x = map[uint64]int{}
@@ -547,10 +629,12 @@ func (s BottomLeft) horizontalCompaction(g LayeredGraph, root map[uint64]uint64,
 	}
 
 	layers := g.Layers()
-	// root coordinates relative to sink
-	for v := range g.NodeYX {
-		if root[v] == v {
-			s.placeBlock(g, x, root, align, sink, shift, delta, v, layers)
+	// root coordinates relative to sink, in order of layers for determinism
+	for _, layer := range layers {
+		for _, v := range layer {
+			if root[v] == v {
+				s.placeBlock(g, x, root, align, sink, shift, sep, v, layers)
+			}
 		}
 	}
 
@@ -572,7 +656,7 @@ func (s BottomLeft) horizontalCompaction(g LayeredGraph, root map[uint64]uint64,
 					j--
 					if g.NodeYX[v][1] > 0 {
 						u := layers[g.NodeYX[v][0]][g.NodeYX[v][1]-1]
-						shifted := shift[sink[v]] + x[v] - (x[u] + delta)
+						shifted := shift[sink[v]] + x[v] - (x[u] + sep(u, v))
 						if shifted < shift[sink[u]] {
 							shift[sink[u]] = shifted
 						}
@@ -641,20 +725,22 @@ func (s BottomRight) verticalAlignment(g LayeredGraph, typeOneSegments map[[2]ui
 }
 
 // part of Alg 3.
-func (s BottomRight) placeBlock(g LayeredGraph, x map[uint64]int, root map[uint64]uint64, align map[uint64]uint64, sink map[uint64]uint64, shift map[uint64]int, delta int, v uint64, layers [][]uint64) {
+func (s BottomRight) placeBlock(g LayeredGraph, x map[uint64]int, root map[uint64]uint64, align map[uint64]uint64, sink map[uint64]uint64, shift map[uint64]int, sep separation, v uint64, layers [][]uint64) {
 	if _, ok := x[v]; !ok {
 		x[v] = 0
 		flag := true
 		w := v
 		for ; flag; flag = v != w {
 			if g.NodeYX[w][1] < len(layers[g.NodeYX[w][0]])-1 {
-				u := root[layers[g.NodeYX[w][0]][g.NodeYX[w][1]+1]]
-				s.placeBlock(g, x, root, align, sink, shift, delta, u, layers)
+				succ := layers[g.NodeYX[w][0]][g.NodeYX[w][1]+1]
+				delta := sep(w, succ)
+				u := root[succ]
+				s.placeBlock(g, x, root, align, sink, shift, sep, u, layers)
 				if sink[v] == v {
 					sink[v] = sink[u]
 				}
 				if sink[v] != sink[u] {
-					if s := x[v] + x[u] + delta; s > shift[sink[u]] {
+					if s := x[v] - x[u] + delta; s > shift[sink[u]] {
 						shift[sink[u]] = s
 					}
 				} else {
@@ -683,7 +769,7 @@ func (s BottomRight) placeBlock(g LayeredGraph, x map[uint64]int, root map[uint6
 // the preceding blocks in the same class, plus minimum separation.
 // For each class, from top to bottom, we then compute the absolute coordinates
 // of its members by placing the class with minimum separation from previously placed classes.
-func (s BottomRight) horizontalCompaction(g LayeredGraph, root map[uint64]uint64, align map[uint64]uint64, delta int) (x map[uint64]int) {
+func (s BottomRight) horizontalCompaction(g LayeredGraph, root map[uint64]uint64, align map[uint64]uint64, sep separation) (x map[uint64]int) {
 	sink := map[uint64]uint64{}
 	shift := map[uint64]int{}
 	x = map[uint64]int{}
@@ -694,10 +780,12 @@ func (s BottomRight) horizontalCompaction(g LayeredGraph, root map[uint64]uint64
 	}
 
 	layers := g.Layers()
-	// root coordinates relative to sink
-	for v := range g.NodeYX {
-		if root[v] == v {
-			s.placeBlock(g, x, root, align, sink, shift, delta, v, layers)
+	// root coordinates relative to sink, in order of layers for determinism
+	for _, layer := range layers {
+		for _, v := range layer {
+			if root[v] == v {
+				s.placeBlock(g, x, root, align, sink, shift, sep, v, layers)
+			}
 		}
 	}
 
@@ -719,7 +807,7 @@ func (s BottomRight) horizontalCompaction(g LayeredGraph, root map[uint64]uint64
 					j--
 					if g.NodeYX[v][1] < len(layers[j])-1 {
 						u := layers[g.NodeYX[v][0]][g.NodeYX[v][1]+1]
-						shifted := shift[sink[v]] + x[v] - (x[u] - delta)
+						shifted := shift[sink[v]] + x[v] - (x[u] - sep(v, u))
 						if shifted > shift[sink[u]] {
 							shift[sink[u]] = shifted
 						}
@@ -0,0 +1,79 @@
+package layout_test
+
+import (
+	"testing"
+
+	"github.com/nikolaydubina/go-graph-layout/layout"
+)
+
+// overlappingNodes finds pair of nodes that have overlapping boxes with gap.
+func overlappingNodes(g layout.Graph, gap int) (a, b uint64, ok bool) {
+	for i, ni := range g.Nodes {
+		for j, nj := range g.Nodes {
+			if i >= j {
+				continue
+			}
+			if ni.XY[0] < nj.XY[0]+nj.W+gap && nj.XY[0] < ni.XY[0]+ni.W+gap && ni.XY[1] < nj.XY[1]+nj.H+gap && nj.XY[1] < ni.XY[1]+ni.H+gap {
+				return i, j, true
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+func TestBrandesKopfLayersNodesHorizontalAssignerNodesWidth(t *testing.T) {
+	for _, input := range []string{ginJSONL, smallJSONL, brandeskopfJSONL} {
+		_, g, err := parseJSONLGraph(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		l := newTestSugiyamaLayout()
+		l.NodesHorizontalCoordinatesAssigner = layout.BrandesKopfLayersNodesHorizontalAssigner{Delta: 10, DummyWidth: 5}
+		if err := l.UpdateGraphLayoutE(*g); err != nil {
+			t.Fatal(err)
+		}
+
+		if a, b, ok := overlappingNodes(*g, 0); ok {
+			t.Errorf("nodes %d %v and %d %v overlap", a, g.Nodes[a], b, g.Nodes[b])
+		}
+	}
+}
+
+func TestBrandesKopfLayersNodesHorizontalAssignerMirrored(t *testing.T) {
+	// right alignments are mirrors of left alignments, so nodes in mirrored layers are at mirrored coordinates
+	layers := [][]uint64{{0, 1, 2}, {3, 4, 5}, {6, 7, 8}, {9}}
+	widths := []int{10, 20, 20, 20, 10, 20, 20, 20, 30, 30}
+	g := layout.Graph{Nodes: map[uint64]layout.Node{}, Edges: map[layout.EdgeID]layout.Edge{}}
+	lg := layout.LayeredGraph{
+		NodeYX:   map[uint64][2]int{},
+		Segments: map[[2]uint64]bool{},
+		Edges:    map[layout.EdgeID][]uint64{},
+		Dummy:    map[uint64]bool{},
+	}
+	for _, e := range [][2]uint64{{0, 4}, {3, 6}, {7, 9}, {8, 9}} {
+		g.Edges[layout.EdgeID{e[0], e[1]}] = layout.Edge{}
+		lg.Edges[layout.EdgeID{e[0], e[1]}] = []uint64{e[0], e[1]}
+		lg.Segments[e] = true
+	}
+
+	coordinates := func(mirrored bool) map[uint64]int {
+		for i, layer := range layers {
+			for j, n := range layer {
+				if mirrored {
+					j = len(layer) - 1 - j
+				}
+				lg.NodeYX[n] = [2]int{i, j}
+				g.Nodes[n] = layout.Node{W: widths[n], H: 10}
+			}
+		}
+		return layout.BrandesKopfLayersNodesHorizontalAssigner{Delta: 10}.NodesHorizontalCoordinates(g, lg)
+	}
+	x, xm := coordinates(false), coordinates(true)
+
+	for n := range x {
+		if d := x[n] + xm[n] - (x[0] + xm[0]); d < -1 || d > 1 {
+			t.Errorf("node %d at %d is not mirrored at %d, node 0 is at %d and %d", n, x[n], xm[n], x[0], xm[0])
+		}
+	}
+}
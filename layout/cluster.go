@@ -0,0 +1,114 @@
+package layout
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Cluster is group of nodes that is drawn in box, clusters can be nested in other clusters.
+// Each node and each cluster can be directly in at most one cluster.
+type Cluster struct {
+	Nodes    []uint64 // nodes directly in cluster
+	Clusters []uint64 // clusters directly in cluster
+	XY       [2]int   // smallest x,y corner of box, set by layouts
+	W        int
+	H        int
+}
+
+// clusterTree is nesting of clusters.
+type clusterTree struct {
+	parent      map[uint64]uint64 // cluster -> enclosing cluster, missing for top clusters
+	nodeCluster map[uint64]uint64 // node -> cluster that has node directly, missing for nodes out of clusters
+}
+
+func newClusterTree(g Graph) clusterTree {
+	t := clusterTree{
+		parent:      make(map[uint64]uint64, len(g.Clusters)),
+		nodeCluster: make(map[uint64]uint64),
+	}
+	for id, c := range g.Clusters {
+		for _, n := range c.Nodes {
+			t.nodeCluster[n] = id
+		}
+		for _, child := range c.Clusters {
+			t.parent[child] = id
+		}
+	}
+	return t
+}
+
+// chain is clusters from top cluster to given cluster.
+func (t clusterTree) chain(c uint64) []uint64 {
+	chain := []uint64{c}
+	for p, ok := t.parent[c]; ok; p, ok = t.parent[p] {
+		chain = append(chain, p)
+	}
+	for i, j := 0, len(chain)-1; i < j; i, j = i+1, j-1 {
+		chain[i], chain[j] = chain[j], chain[i]
+	}
+	return chain
+}
+
+// nodeChain is clusters that have node, from top cluster.
+func (t clusterTree) nodeChain(n uint64) []uint64 {
+	c, ok := t.nodeCluster[n]
+	if !ok {
+		return nil
+	}
+	return t.chain(c)
+}
+
+// commonChain is clusters that are in both chains.
+func commonChain(a, b []uint64) []uint64 {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return a[:i]
+}
+
+func (g Graph) clusterIDs() []uint64 {
+	ids := make([]uint64, 0, len(g.Clusters))
+	for id := range g.Clusters {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// validateClusters checks that clusters reference existing nodes and clusters and form trees.
+func (g Graph) validateClusters() error {
+	nodeCluster := make(map[uint64]uint64)
+	parent := make(map[uint64]uint64)
+	for _, id := range g.clusterIDs() {
+		c := g.Clusters[id]
+		for _, n := range c.Nodes {
+			if _, ok := g.Nodes[n]; !ok {
+				return ClusterError{Cluster: id, Reason: fmt.Sprintf("has missing node(%d)", n)}
+			}
+			if other, ok := nodeCluster[n]; ok {
+				return ClusterError{Cluster: id, Reason: fmt.Sprintf("has node(%d) that is already in cluster(%d)", n, other)}
+			}
+			nodeCluster[n] = id
+		}
+		for _, child := range c.Clusters {
+			if _, ok := g.Clusters[child]; !ok {
+				return ClusterError{Cluster: id, Reason: fmt.Sprintf("has missing cluster(%d)", child)}
+			}
+			if other, ok := parent[child]; ok {
+				return ClusterError{Cluster: id, Reason: fmt.Sprintf("has cluster(%d) that is already in cluster(%d)", child, other)}
+			}
+			parent[child] = id
+		}
+	}
+
+	for _, id := range g.clusterIDs() {
+		steps := 0
+		for p, ok := parent[id]; ok; p, ok = parent[p] {
+			if steps++; p == id || steps > len(g.Clusters) {
+				return ClusterError{Cluster: id, Reason: "is nested in itself"}
+			}
+		}
+	}
+	return nil
+}
@@ -7,12 +7,13 @@ import (
 // SimpleCycleRemover will keep testing for cycles, if cycle found will randomly reverse one edge in cycle.
 // When restoring, will reverse previously reversed edges.
 type SimpleCycleRemover struct {
-	Reversed map[[2]uint64]bool
+	Reversed map[EdgeID]EdgeID // original edge -> reversed edge
+	Rand     *rand.Rand        // source of randomness, source with fixed seed when nil
 }
 
 func NewSimpleCycleRemover() SimpleCycleRemover {
 	return SimpleCycleRemover{
-		Reversed: map[[2]uint64]bool{},
+		Reversed: map[EdgeID]EdgeID{},
 	}
 }
 
@@ -48,29 +49,99 @@ func getCycle(roots []uint64, neighbors map[uint64][]uint64) []uint64 {
 	return nil
 }
 
-func reverseEdge(g Graph, e [2]uint64) {
+// reverseEdge moves edge to reversed edge id.
+func reverseEdge(g Graph, e, reversed EdgeID) {
+	edge := g.Edges[e]
+	edge.Path = reversePoints(edge.Path)
+	edge.Curve = reversePoints(edge.Curve)
+	edge.FromPort, edge.ToPort = edge.ToPort, edge.FromPort
+
 	delete(g.Edges, e)
-	g.Edges[[2]uint64{e[1], e[0]}] = Edge{}
+	g.Edges[reversed] = edge
+}
+
+// reversedEdgeID is id of edge in opposite direction with same number,
+// or with next free number when there is parallel edge in opposite direction already or id is of reversed original edge.
+func reversedEdgeID(g Graph, e EdgeID, reversed map[EdgeID]EdgeID) EdgeID {
+	r := EdgeID{e[1], e[0], e[2]}
+	for {
+		_, inGraph := g.Edges[r]
+		_, isOriginal := reversed[r]
+		if !inGraph && !isOriginal {
+			return r
+		}
+		r[2]++
+	}
+}
+
+// reversePoints makes new points in reverse order, nil when there are no points.
+func reversePoints(points [][2]int) [][2]int {
+	if len(points) == 0 {
+		return nil
+	}
+	reversed := make([][2]int, len(points))
+	for i, xy := range points {
+		reversed[len(points)-1-i] = xy
+	}
+	return reversed
 }
 
 func (s SimpleCycleRemover) RemoveCycles(g Graph) {
+	// edge can be reversed more than once, when it is in other cycle after reversal
+	original := make(map[EdgeID]EdgeID, len(s.Reversed))
+	for e, reversed := range s.Reversed {
+		original[reversed] = e
+	}
+
 	neighbors := make(map[uint64][]uint64)
-	for e := range g.Edges {
+	parallel := make(map[[2]uint64][]EdgeID)
+	for _, e := range g.edgeIDs() {
+		if e.isSelfLoop() {
+			continue
+		}
 		neighbors[e[0]] = append(neighbors[e[0]], e[1])
+		parallel[[2]uint64{e[0], e[1]}] = append(parallel[[2]uint64{e[0], e[1]}], e)
 	}
-	for cycle := getCycle(g.Roots(), neighbors); len(cycle) > 0; cycle = getCycle(g.Roots(), neighbors) {
+	r := randOrDefault(s.Rand)
+
+	// cycles that are not reachable from roots are searched from other nodes
+	starts := append(g.Roots(), g.nodeIDs()...)
+	for cycle := getCycle(starts, neighbors); len(cycle) > 0; cycle = getCycle(starts, neighbors) {
 		// pick edge randomly
-		i := rand.Intn(len(cycle) - 1)
+		i := r.Intn(len(cycle) - 1)
 		e := [2]uint64{cycle[i], cycle[i+1]}
 
-		reverseEdge(g, e)
-		s.Reversed[e] = true
+		// one of parallel edges, others are reversed when found in cycles again
+		id := parallel[e][0]
+		parallel[e] = parallel[e][1:]
+		reversed := reversedEdgeID(g, id, s.Reversed)
+		reverseEdge(g, id, reversed)
+		parallel[[2]uint64{e[1], e[0]}] = append(parallel[[2]uint64{e[1], e[0]}], reversed)
+
+		o, ok := original[id]
+		if !ok {
+			o = id
+		}
+		delete(original, id)
+		original[reversed] = o
+		s.Reversed[o] = reversed
 
 		neighbors[e[0]] = deleteValue(neighbors[e[0]], e[1])
 		neighbors[e[1]] = append(neighbors[e[1]], e[0])
 	}
 }
 
+// defaultSeed is seed of source of randomness when it is not set, so that layouts are same on each run.
+const defaultSeed = 1
+
+// randOrDefault is r, or new source with default seed when r is nil.
+func randOrDefault(r *rand.Rand) *rand.Rand {
+	if r == nil {
+		return rand.New(rand.NewSource(defaultSeed))
+	}
+	return r
+}
+
 func deleteValue(slice []uint64, value uint64) []uint64 {
 	for i, n := range slice {
 		if n == value {
@@ -81,9 +152,15 @@ func deleteValue(slice []uint64, value uint64) []uint64 {
 	return slice
 }
 
+// Restore moves edges back to original ids, edges that were reversed even number of times keep their paths.
 func (s SimpleCycleRemover) Restore(g Graph) {
-	for e := range s.Reversed {
-		reverseEdge(g, e)
+	for e, reversed := range s.Reversed {
+		if reversed[0] == e[0] {
+			g.Edges[e] = g.Edges[reversed]
+			delete(g.Edges, reversed)
+		} else {
+			reverseEdge(g, reversed, e)
+		}
 		delete(s.Reversed, e)
 	}
 }
@@ -0,0 +1,212 @@
+package layout
+
+import (
+	"container/heap"
+	"sort"
+)
+
+// GreedyCycleRemover finds small feedback arc set and reverses edges in it.
+// Nodes are ordered such that sinks go to the end and sources go to the start,
+// otherwise node with largest difference of out and in degree goes to the start.
+// Edges that go against this order are reversed.
+// Deterministic and runs in O((V + E) * log(V)).
+// When restoring, will reverse previously reversed edges.
+// "A fast and effective heuristic for the feedback arc set problem", P. Eades, X. Lin, W. F. Smyth, 1993
+type GreedyCycleRemover struct {
+	Reversed map[EdgeID]EdgeID // original edge -> reversed edge
+}
+
+func NewGreedyCycleRemover() GreedyCycleRemover {
+	return GreedyCycleRemover{
+		Reversed: map[EdgeID]EdgeID{},
+	}
+}
+
+func (s GreedyCycleRemover) RemoveCycles(g Graph) {
+	order := make(map[uint64]int, len(g.Nodes))
+	for i, n := range greedyFeedbackArcSetOrder(g) {
+		order[n] = i
+	}
+
+	// edges with opposite edge become parallel to it
+	var backward []EdgeID
+	for _, e := range g.edgeIDs() {
+		if !e.isSelfLoop() && order[e[0]] > order[e[1]] {
+			backward = append(backward, e)
+		}
+	}
+
+	for _, e := range backward {
+		reversed := reversedEdgeID(g, e, s.Reversed)
+		reverseEdge(g, e, reversed)
+		s.Reversed[e] = reversed
+	}
+}
+
+func (s GreedyCycleRemover) Restore(g Graph) {
+	for e, reversed := range s.Reversed {
+		reverseEdge(g, reversed, e)
+		delete(s.Reversed, e)
+	}
+}
+
+// greedyFeedbackArcSetOrder orders nodes such that few edges go from later node to earlier node.
+// Self loops are ignored.
+func greedyFeedbackArcSetOrder(g Graph) []uint64 {
+	nodes := make([]uint64, 0, len(g.Nodes))
+	for n := range g.Nodes {
+		nodes = append(nodes, n)
+	}
+	for e := range g.Edges {
+		for _, n := range e[:2] {
+			if _, ok := g.Nodes[n]; !ok {
+				nodes = append(nodes, n)
+			}
+		}
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i] < nodes[j] })
+	nodes = uniqueSorted(nodes)
+
+	out := make(map[uint64][]uint64, len(nodes))
+	in := make(map[uint64][]uint64, len(nodes))
+	for e := range g.Edges {
+		if e[0] == e[1] {
+			continue
+		}
+		out[e[0]] = append(out[e[0]], e[1])
+		in[e[1]] = append(in[e[1]], e[0])
+	}
+	for _, n := range nodes {
+		sort.Slice(out[n], func(i, j int) bool { return out[n][i] < out[n][j] })
+		sort.Slice(in[n], func(i, j int) bool { return in[n][i] < in[n][j] })
+	}
+
+	outDeg := make(map[uint64]int, len(nodes))
+	inDeg := make(map[uint64]int, len(nodes))
+	for _, n := range nodes {
+		outDeg[n] = len(out[n])
+		inDeg[n] = len(in[n])
+	}
+
+	removed := make(map[uint64]bool, len(nodes))
+	var sinks, sources []uint64
+	deltas := &nodeDeltaHeap{}
+	for _, n := range nodes {
+		switch {
+		case outDeg[n] == 0:
+			sinks = append(sinks, n)
+		case inDeg[n] == 0:
+			sources = append(sources, n)
+		default:
+			heap.Push(deltas, nodeDelta{node: n, delta: outDeg[n] - inDeg[n]})
+		}
+	}
+
+	remove := func(n uint64) {
+		removed[n] = true
+		for _, m := range out[n] {
+			if removed[m] {
+				continue
+			}
+			inDeg[m]--
+			if inDeg[m] == 0 {
+				sources = append(sources, m)
+			} else {
+				heap.Push(deltas, nodeDelta{node: m, delta: outDeg[m] - inDeg[m]})
+			}
+		}
+		for _, m := range in[n] {
+			if removed[m] {
+				continue
+			}
+			outDeg[m]--
+			if outDeg[m] == 0 {
+				sinks = append(sinks, m)
+			} else {
+				heap.Push(deltas, nodeDelta{node: m, delta: outDeg[m] - inDeg[m]})
+			}
+		}
+	}
+
+	var head, tail []uint64
+	for len(head)+len(tail) < len(nodes) {
+		if len(sinks) > 0 {
+			n := sinks[0]
+			sinks = sinks[1:]
+			if !removed[n] {
+				tail = append(tail, n)
+				remove(n)
+			}
+			continue
+		}
+
+		if len(sources) > 0 {
+			n := sources[0]
+			sources = sources[1:]
+			if !removed[n] {
+				head = append(head, n)
+				remove(n)
+			}
+			continue
+		}
+
+		for deltas.Len() > 0 {
+			d := heap.Pop(deltas).(nodeDelta)
+			if removed[d.node] || d.delta != (outDeg[d.node]-inDeg[d.node]) {
+				// stale entry
+				continue
+			}
+			head = append(head, d.node)
+			remove(d.node)
+			break
+		}
+	}
+
+	// sinks were collected from the end
+	for i := len(tail) - 1; i >= 0; i-- {
+		head = append(head, tail[i])
+	}
+	return head
+}
+
+func uniqueSorted(nodes []uint64) []uint64 {
+	if len(nodes) == 0 {
+		return nodes
+	}
+	j := 0
+	for i := 1; i < len(nodes); i++ {
+		if nodes[i] != nodes[j] {
+			j++
+			nodes[j] = nodes[i]
+		}
+	}
+	return nodes[:j+1]
+}
+
+type nodeDelta struct {
+	node  uint64
+	delta int
+}
+
+// nodeDeltaHeap pops largest delta first, ties broken by smallest node.
+type nodeDeltaHeap []nodeDelta
+
+func (h nodeDeltaHeap) Len() int { return len(h) }
+
+func (h nodeDeltaHeap) Less(i, j int) bool {
+	if h[i].delta != h[j].delta {
+		return h[i].delta > h[j].delta
+	}
+	return h[i].node < h[j].node
+}
+
+func (h nodeDeltaHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *nodeDeltaHeap) Push(x interface{}) { *h = append(*h, x.(nodeDelta)) }
+
+func (h *nodeDeltaHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
@@ -0,0 +1,107 @@
+package layout_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/nikolaydubina/go-graph-layout/layout"
+)
+
+func TestGreedyCycleRemover(t *testing.T) {
+	tests := []struct {
+		name        string
+		edges       []layout.EdgeID
+		numReversed int
+	}{
+		{
+			name:        "cycle without roots",
+			edges:       []layout.EdgeID{{1, 2}, {2, 3}, {3, 1}},
+			numReversed: 1,
+		},
+		{
+			name:        "two cycles sharing edge",
+			edges:       []layout.EdgeID{{1, 2}, {2, 3}, {3, 1}, {3, 4}, {4, 2}, {0, 1}},
+			numReversed: 1,
+		},
+		{
+			name:        "opposite edges",
+			edges:       []layout.EdgeID{{1, 2}, {2, 1}, {2, 3}},
+			numReversed: 1,
+		},
+		{
+			name:        "self loops and parallel edges",
+			edges:       []layout.EdgeID{{1, 1}, {1, 2}, {1, 2, 1}, {2, 3}, {3, 1}, {3, 1, 1}},
+			numReversed: 1,
+		},
+		{
+			name:  "acyclic",
+			edges: []layout.EdgeID{{1, 2}, {1, 3}, {2, 3}},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g := layout.Graph{
+				Nodes: map[uint64]layout.Node{},
+				Edges: map[layout.EdgeID]layout.Edge{},
+			}
+			for _, e := range tc.edges {
+				g.Nodes[e[0]] = layout.Node{}
+				g.Nodes[e[1]] = layout.Node{}
+				g.Edges[e] = layout.Edge{Weight: int(e[0]*10 + e[1])}
+			}
+
+			r := layout.NewGreedyCycleRemover()
+			r.RemoveCycles(g)
+
+			if len(r.Reversed) != tc.numReversed {
+				t.Errorf("expected %d reversed edges, got %v", tc.numReversed, r.Reversed)
+			}
+			if err := layout.NewLayeredGraph(g).Validate(); err != nil {
+				t.Error(err)
+			}
+
+			r.Restore(g)
+
+			if len(g.Edges) != len(tc.edges) {
+				t.Errorf("expected %d edges after restore, got %d", len(tc.edges), len(g.Edges))
+			}
+			for _, e := range tc.edges {
+				if edge, ok := g.Edges[e]; !ok || edge.Weight != int(e[0]*10+e[1]) {
+					t.Errorf("edge %v is not restored: %v", e, edge)
+				}
+			}
+		})
+	}
+}
+
+func TestGreedyCycleRemoverOppositeEdgesLayout(t *testing.T) {
+	for _, label := range []layout.Label{{}, {W: 5, H: 5}} {
+		g := layout.Graph{
+			Nodes: map[uint64]layout.Node{1: {W: 10, H: 10}, 2: {W: 10, H: 10}},
+			Edges: map[layout.EdgeID]layout.Edge{{1, 2}: {}, {2, 1}: {Label: label}},
+		}
+		if err := newTestSugiyamaLayout().UpdateGraphLayoutE(g); err != nil {
+			t.Fatal(err)
+		}
+
+		// opposite edge is reversed into parallel edge, so edges are drawn apart
+		a, b := g.Edges[layout.EdgeID{1, 2}], g.Edges[layout.EdgeID{2, 1}]
+		if fmt.Sprint(a.Path) == fmt.Sprint(reversed(b.Path)) {
+			t.Errorf("edges are drawn on top of each other %v %v", a.Path, b.Path)
+		}
+		if b.Path[0] != g.Nodes[2].PortXY("") || b.Path[len(b.Path)-1] != g.Nodes[1].PortXY("") {
+			t.Errorf("edge %v path %v does not connect its nodes", layout.EdgeID{2, 1}, b.Path)
+		}
+		if l := b.Label; l.W > 0 && l.XY == [2]int{} {
+			t.Errorf("label of edge %v is not placed", layout.EdgeID{2, 1})
+		}
+	}
+}
+
+func reversed(points [][2]int) [][2]int {
+	r := make([][2]int, len(points))
+	for i, p := range points {
+		r[len(points)-1-i] = p
+	}
+	return r
+}
@@ -0,0 +1,105 @@
+package layout_test
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/nikolaydubina/go-graph-layout/layout"
+)
+
+// newTestRandomMultiGraph is small graph with cycles, opposite and parallel edges and self loops.
+// Weights of edges identify them.
+func newTestRandomMultiGraph(seed int64) layout.Graph {
+	r := rand.New(rand.NewSource(seed))
+	g := layout.Graph{
+		Nodes: map[uint64]layout.Node{},
+		Edges: map[layout.EdgeID]layout.Edge{},
+	}
+	n := 5 + r.Intn(4)
+	for i := 0; i < n; i++ {
+		g.Nodes[uint64(i)] = layout.Node{}
+	}
+	for i, m := 0, n+r.Intn(2*n); i < m; i++ {
+		e := layout.EdgeID{uint64(r.Intn(n)), uint64(r.Intn(n))}
+		for _, ok := g.Edges[e]; ok; _, ok = g.Edges[e] {
+			e[2]++
+		}
+		g.Edges[e] = layout.Edge{Weight: i + 1}
+	}
+	return g
+}
+
+func TestCycleRemovers(t *testing.T) {
+	removers := map[string]func(seed int64) layout.CycleRemover{
+		"simple": func(seed int64) layout.CycleRemover {
+			r := layout.NewSimpleCycleRemover()
+			r.Rand = rand.New(rand.NewSource(seed))
+			return r
+		},
+		"simple without rand": func(int64) layout.CycleRemover { return layout.NewSimpleCycleRemover() },
+		"greedy":              func(int64) layout.CycleRemover { return layout.NewGreedyCycleRemover() },
+	}
+	for name, newRemover := range removers {
+		for seed := int64(0); seed < 300; seed++ {
+			t.Run(fmt.Sprintf("%s seed(%d)", name, seed), func(t *testing.T) {
+				g := newTestRandomMultiGraph(seed)
+				edges := make(map[layout.EdgeID]int, len(g.Edges))
+				for e, edge := range g.Edges {
+					edges[e] = edge.Weight
+				}
+
+				r := newRemover(seed)
+				r.RemoveCycles(g)
+				if err := layout.NewLayeredGraph(g).Validate(); err != nil {
+					t.Error(err)
+				}
+				r.Restore(g)
+
+				if len(g.Edges) != len(edges) {
+					t.Errorf("expected %d edges after restore, got %d", len(edges), len(g.Edges))
+				}
+				for e, w := range edges {
+					if edge, ok := g.Edges[e]; !ok || edge.Weight != w {
+						t.Errorf("edge %v is not restored: %v", e, edge)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestSimpleCycleRemoverRestore(t *testing.T) {
+	g := layout.Graph{
+		Nodes: map[uint64]layout.Node{1: {}, 2: {}, 3: {}},
+		Edges: map[layout.EdgeID]layout.Edge{{1, 2}: {}, {2, 3}: {}, {3, 1}: {}},
+	}
+	r := layout.NewSimpleCycleRemover()
+	r.RemoveCycles(g)
+	if len(r.Reversed) != 1 {
+		t.Fatalf("expected one reversed edge, got %v", r.Reversed)
+	}
+
+	// paths are laid out for reversed edges, from their sources to their targets
+	for e, edge := range g.Edges {
+		edge.Path = [][2]int{{int(e[0]), 0}, {int(e[1]), 0}}
+		g.Edges[e] = edge
+	}
+	r.Restore(g)
+
+	if len(r.Reversed) != 0 {
+		t.Errorf("expected no reversed edges after restore, got %v", r.Reversed)
+	}
+	for _, e := range []layout.EdgeID{{1, 2}, {2, 3}, {3, 1}} {
+		edge, ok := g.Edges[e]
+		if !ok {
+			t.Fatalf("edge %v is not restored: %v", e, g.Edges)
+		}
+		if p := edge.Path; len(p) != 2 || p[0][0] != int(e[0]) || p[1][0] != int(e[1]) {
+			t.Errorf("edge %v path %v does not go from its source to its target", e, p)
+		}
+	}
+	if len(g.Edges) != 3 {
+		t.Errorf("expected 3 edges after restore, got %v", g.Edges)
+	}
+}
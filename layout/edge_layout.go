@@ -17,10 +17,25 @@ func DirectEdge(from, to Node) Edge {
 }
 
 // DirectEdgesLayout are straight single line edges.
+// Self loops are curves on right side of nodes, parallel edges are curves apart from each other.
 type DirectEdgesLayout struct{}
 
+// UpdateGraphLayout sets paths from port of source node to port of target node.
 func (l DirectEdgesLayout) UpdateGraphLayout(g Graph) {
-	for e := range g.Edges {
-		g.Edges[e] = DirectEdge(g.Nodes[e[0]], g.Nodes[e[1]])
+	for e, edge := range g.Edges {
+		edge.Path = [][2]int{g.Nodes[e[0]].PortXY(edge.FromPort), g.Nodes[e[1]].PortXY(edge.ToPort)}
+		edge.Curve = nil
+		g.Edges[e] = edge
 	}
+	selfLoops(g, SideRight, true)
+	separateParallelEdges(g)
+}
+
+// UpdateGraphLayoutE validates graph and updates its layout.
+func (l DirectEdgesLayout) UpdateGraphLayoutE(g Graph) error {
+	if err := g.Validate(); err != nil {
+		return err
+	}
+	l.UpdateGraphLayout(g)
+	return nil
 }
@@ -0,0 +1,107 @@
+package layout
+
+import "math"
+
+// ClipEdgesLayout cuts paths and curves of edges at outlines of their nodes,
+// so that edges start and end at borders of nodes instead of centers.
+// Ends that are already outside of node, for example at ports, are kept.
+// Should be applied after layout that assigns edge paths.
+type ClipEdgesLayout struct{}
+
+func (l ClipEdgesLayout) UpdateGraphLayout(g Graph) {
+	for e, edge := range g.Edges {
+		from, to := g.Nodes[e[0]], g.Nodes[e[1]]
+		edge.Path = clipPath(edge.Path, from, to)
+		if len(edge.Curve) >= 4 {
+			edge.Curve = clipCurve(edge.Curve, from, to)
+		}
+		g.Edges[e] = edge
+	}
+}
+
+// UpdateGraphLayoutE validates graph and updates its layout.
+func (l ClipEdgesLayout) UpdateGraphLayoutE(g Graph) error {
+	if err := g.Validate(); err != nil {
+		return err
+	}
+	l.UpdateGraphLayout(g)
+	return nil
+}
+
+// clipPath cuts polyline at first exit from start node and at last entry to end node.
+func clipPath(path [][2]int, from, to Node) [][2]int {
+	path = clipPathStart(path, from)
+	return reversePoints(clipPathStart(reversePoints(path), to))
+}
+
+func clipPathStart(path [][2]int, n Node) [][2]int {
+	for i := 1; i < len(path); i++ {
+		if n.containsXY(toFloat(path[i])) {
+			continue
+		}
+		if i == 1 && !n.containsXY(toFloat(path[0])) {
+			return path
+		}
+		a, b := toFloat(path[i-1]), toFloat(path[i])
+		t := exitParam(n, func(t float64) [2]float64 { return lerp(a, b, t) })
+		return append([][2]int{toInt(lerp(a, b, t))}, path[i:]...)
+	}
+	return path
+}
+
+// clipCurve cuts piecewise cubic Bezier curve at first exit from start node and at last entry to end node.
+func clipCurve(curve [][2]int, from, to Node) [][2]int {
+	curve = clipCurveStart(curve, from)
+	return reversePoints(clipCurveStart(reversePoints(curve), to))
+}
+
+func clipCurveStart(curve [][2]int, n Node) [][2]int {
+	for i := 3; i < len(curve); i += 3 {
+		if n.containsXY(toFloat(curve[i])) {
+			continue
+		}
+		if i == 3 && !n.containsXY(toFloat(curve[0])) {
+			return curve
+		}
+		var p [4][2]float64
+		for j := range p {
+			p[j] = toFloat(curve[i-3+j])
+		}
+		t := exitParam(n, func(t float64) [2]float64 { return bezierSplit(p, t)[3] })
+		s := bezierSplit(p, t)
+		clipped := [][2]int{toInt(s[3]), toInt(s[4]), toInt(s[5]), curve[i]}
+		return append(clipped, curve[i+1:]...)
+	}
+	return curve
+}
+
+// exitParam is parameter where curve that starts inside of node and ends outside crosses outline of node, by bisection.
+func exitParam(n Node, at func(t float64) [2]float64) float64 {
+	lo, hi := 0.0, 1.0
+	for i := 0; i < 32; i++ {
+		mid := (lo + hi) / 2
+		if n.containsXY(at(mid)) {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return hi
+}
+
+// bezierSplit splits cubic Bezier curve at t by de Casteljau algorithm.
+// Returns [start, control, control, split, control, control, end].
+func bezierSplit(p [4][2]float64, t float64) [7][2]float64 {
+	p01, p12, p23 := lerp(p[0], p[1], t), lerp(p[1], p[2], t), lerp(p[2], p[3], t)
+	p012, p123 := lerp(p01, p12, t), lerp(p12, p23, t)
+	m := lerp(p012, p123, t)
+	return [7][2]float64{p[0], p01, p012, m, p123, p23, p[3]}
+}
+
+func lerp(a, b [2]float64, t float64) [2]float64 {
+	return [2]float64{a[0] + (b[0]-a[0])*t, a[1] + (b[1]-a[1])*t}
+}
+
+func toFloat(p [2]int) [2]float64 { return [2]float64{float64(p[0]), float64(p[1])} }
+
+func toInt(p [2]float64) [2]int { return [2]int{int(math.Round(p[0])), int(math.Round(p[1]))} }
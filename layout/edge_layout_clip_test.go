@@ -0,0 +1,88 @@
+package layout_test
+
+import (
+	"testing"
+
+	"github.com/nikolaydubina/go-graph-layout/layout"
+)
+
+func TestClipEdgesLayout(t *testing.T) {
+	t.Run("box", func(t *testing.T) {
+		g := layout.Graph{
+			Nodes: map[uint64]layout.Node{
+				1: {XY: [2]int{0, 0}, W: 40, H: 20},
+				2: {XY: [2]int{100, 0}, W: 40, H: 20},
+			},
+			Edges: map[layout.EdgeID]layout.Edge{{1, 2}: {}},
+		}
+		layout.SequenceLayout{Layouts: []layout.Layout{layout.DirectEdgesLayout{}, layout.ClipEdgesLayout{}}}.UpdateGraphLayout(g)
+
+		if path := g.Edges[layout.EdgeID{1, 2}].Path; len(path) != 2 || path[0] != [2]int{40, 10} || path[1] != [2]int{100, 10} {
+			t.Errorf("path %v", path)
+		}
+	})
+
+	t.Run("shapes", func(t *testing.T) {
+		for _, tc := range []struct {
+			shape layout.Shape
+			start [2]int
+		}{
+			{shape: layout.ShapeBox, start: [2]int{40, 40}},
+			{shape: layout.ShapeEllipse, start: [2]int{34, 34}},
+			{shape: layout.ShapeDiamond, start: [2]int{30, 30}},
+		} {
+			g := layout.Graph{
+				Nodes: map[uint64]layout.Node{
+					1: {XY: [2]int{0, 0}, W: 40, H: 40, Shape: tc.shape},
+					2: {XY: [2]int{100, 100}, W: 40, H: 40, Shape: tc.shape},
+				},
+				Edges: map[layout.EdgeID]layout.Edge{{1, 2}: {}},
+			}
+			layout.SequenceLayout{Layouts: []layout.Layout{layout.DirectEdgesLayout{}, layout.ClipEdgesLayout{}}}.UpdateGraphLayout(g)
+
+			path := g.Edges[layout.EdgeID{1, 2}].Path
+			if len(path) != 2 || path[0] != tc.start || path[1] != [2]int{140 - tc.start[0], 140 - tc.start[1]} {
+				t.Errorf("shape %d: path %v", tc.shape, path)
+			}
+		}
+	})
+
+	t.Run("polyline and curve through nodes", func(t *testing.T) {
+		g := layout.Graph{
+			Nodes: map[uint64]layout.Node{
+				1: {XY: [2]int{0, 0}, W: 40, H: 20},
+				2: {XY: [2]int{0, 200}, W: 40, H: 20},
+			},
+			Edges: map[layout.EdgeID]layout.Edge{
+				{1, 2}: {
+					Path:  [][2]int{{20, 10}, {20, 15}, {20, 100}, {20, 210}},
+					Curve: [][2]int{{20, 10}, {20, 12}, {20, 13}, {20, 15}, {20, 50}, {20, 150}, {20, 210}},
+				},
+			},
+		}
+		layout.ClipEdgesLayout{}.UpdateGraphLayout(g)
+
+		edge := g.Edges[layout.EdgeID{1, 2}]
+		if len(edge.Path) != 3 || edge.Path[0] != [2]int{20, 20} || edge.Path[2] != [2]int{20, 200} {
+			t.Errorf("path %v", edge.Path)
+		}
+		if len(edge.Curve) != 4 || edge.Curve[0] != [2]int{20, 20} || edge.Curve[3] != [2]int{20, 200} {
+			t.Errorf("curve %v", edge.Curve)
+		}
+	})
+
+	t.Run("ports are kept", func(t *testing.T) {
+		g := layout.Graph{
+			Nodes: map[uint64]layout.Node{
+				1: {XY: [2]int{0, 0}, W: 40, H: 20, Ports: map[string]layout.Port{"out": {Side: layout.SideBottom, Offset: 5}}},
+				2: {XY: [2]int{0, 100}, W: 40, H: 20},
+			},
+			Edges: map[layout.EdgeID]layout.Edge{{1, 2}: {FromPort: "out"}},
+		}
+		layout.SequenceLayout{Layouts: []layout.Layout{layout.DirectEdgesLayout{}, layout.ClipEdgesLayout{}}}.UpdateGraphLayout(g)
+
+		if path := g.Edges[layout.EdgeID{1, 2}].Path; len(path) != 2 || path[0] != [2]int{5, 20} || path[1][1] != 100 {
+			t.Errorf("path %v", path)
+		}
+	})
+}
@@ -0,0 +1,165 @@
+package layout
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// EdgeLabelsLayout places labels of edges near middle of paths of edges, for layouts without layers.
+// Positions are tried on grid of half sizes of label around middle of path, closest first, and first one
+// that does not overlap nodes and labels placed before is used.
+// Search goes further out until free position, that always exists outside of all nodes and labels.
+// When search is limited by MaxRings and all positions are taken, label is in middle of path and overlaps,
+// and number of such labels is sent to Observer.
+// Should be applied after layout that assigns edge paths.
+type EdgeLabelsLayout struct {
+	Margin   int      // minimal distance from labels to nodes and other labels
+	MaxRings int      // how far from middle of path to look for free position, in steps of size of label, zero is no limit
+	Observer Observer // receives number of overlapping labels
+}
+
+// edgeLabelsNearRings is number of rings around middle of path, in which positions are tried closest first across rings.
+const edgeLabelsNearRings = 4
+
+func (l EdgeLabelsLayout) UpdateGraphLayout(g Graph) {
+	start := time.Now()
+	nearRings := edgeLabelsNearRings
+	if l.MaxRings > 0 {
+		nearRings = minInt(nearRings, l.MaxRings)
+	}
+	offsets := ringOffsets(nearRings)
+
+	nodes := g.nodeIDs()
+	boxes := make([]box, len(nodes))
+	for i, n := range nodes {
+		node := g.Nodes[n]
+		boxes[i] = box{minX: node.XY[0], minY: node.XY[1], maxX: node.XY[0] + node.W, maxY: node.XY[1] + node.H}
+	}
+	index := newBoxIndex(boxes)
+
+	var placed []box
+	isFree := func(b box) bool {
+		inflated := box{minX: b.minX - l.Margin, minY: b.minY - l.Margin, maxX: b.maxX + l.Margin, maxY: b.maxY + l.Margin}
+		if index.overlaps(inflated) {
+			return false
+		}
+		for _, o := range placed {
+			if o.overlaps(inflated) {
+				return false
+			}
+		}
+		return true
+	}
+
+	overlaps := 0
+	for _, e := range g.edgeIDs() {
+		edge := g.Edges[e]
+		if edge.Label.isEmpty() {
+			continue
+		}
+		w, h := edge.Label.W, edge.Label.H
+		mid := pathMiddle(edge.Path)
+		corner := [2]int{mid[0] - w/2, mid[1] - h/2}
+
+		edge.Label.XY = corner
+		free := false
+		try := func(offsets [][2]int) {
+			for _, d := range offsets {
+				xy := [2]int{corner[0] + d[0]*(w+l.Margin)/2, corner[1] + d[1]*(h+l.Margin)/2}
+				if b := (box{minX: xy[0], minY: xy[1], maxX: xy[0] + w, maxY: xy[1] + h}); isFree(b) {
+					edge.Label.XY = xy
+					free = true
+					return
+				}
+			}
+		}
+		try(offsets)
+		for k := 2*nearRings + 1; !free && (l.MaxRings <= 0 || k <= 2*l.MaxRings); k++ {
+			try(squareOffsets(k))
+		}
+		if !free {
+			overlaps++
+		}
+		placed = append(placed, box{minX: edge.Label.XY[0], minY: edge.Label.XY[1], maxX: edge.Label.XY[0] + w, maxY: edge.Label.XY[1] + h})
+		g.Edges[e] = edge
+	}
+	observe(l.Observer, Event{Phase: PhaseEdgeLabels, Overlaps: overlaps, Elapsed: time.Since(start)})
+}
+
+// UpdateGraphLayoutE validates graph and updates its layout.
+func (l EdgeLabelsLayout) UpdateGraphLayoutE(g Graph) error {
+	if err := g.Validate(); err != nil {
+		return err
+	}
+	l.UpdateGraphLayout(g)
+	return nil
+}
+
+// ringOffsets are points of grid within 2*rings steps from zero, closest to zero first.
+func ringOffsets(rings int) [][2]int {
+	var offsets [][2]int
+	for dx := -2 * rings; dx <= 2*rings; dx++ {
+		for dy := -2 * rings; dy <= 2*rings; dy++ {
+			offsets = append(offsets, [2]int{dx, dy})
+		}
+	}
+	sort.SliceStable(offsets, func(i, j int) bool {
+		a, b := offsets[i], offsets[j]
+		return a[0]*a[0]+a[1]*a[1] < b[0]*b[0]+b[1]*b[1]
+	})
+	return offsets
+}
+
+// squareOffsets are points of grid on border of square with k steps from zero to sides, closest to zero first.
+func squareOffsets(k int) [][2]int {
+	offsets := make([][2]int, 0, 8*k)
+	for i := -k; i < k; i++ {
+		offsets = append(offsets, [2]int{i, -k}, [2]int{k, i}, [2]int{-i, k}, [2]int{-k, -i})
+	}
+	sort.SliceStable(offsets, func(i, j int) bool {
+		a, b := offsets[i], offsets[j]
+		return a[0]*a[0]+a[1]*a[1] < b[0]*b[0]+b[1]*b[1]
+	})
+	return offsets
+}
+
+// pathMiddle is point at half of length of path.
+func pathMiddle(path [][2]int) [2]int {
+	if len(path) == 0 {
+		return [2]int{}
+	}
+	total := 0.0
+	for i := 1; i < len(path); i++ {
+		total += math.Hypot(float64(path[i][0]-path[i-1][0]), float64(path[i][1]-path[i-1][1]))
+	}
+	rest := total / 2
+	for i := 1; i < len(path); i++ {
+		a, b := toFloat(path[i-1]), toFloat(path[i])
+		d := math.Hypot(b[0]-a[0], b[1]-a[1])
+		if d > 0 && rest <= d {
+			return toInt(lerp(a, b, rest/d))
+		}
+		rest -= d
+	}
+	return path[len(path)-1]
+}
+
+// overlaps is when boxes have common inside, touching borders is not overlap.
+func (b box) overlaps(o box) bool {
+	return b.minX < o.maxX && o.minX < b.maxX && b.minY < o.maxY && o.minY < b.maxY
+}
+
+// overlaps is when box overlaps any box in index.
+func (ix boxIndex) overlaps(b box) bool {
+	for cx := ix.cell(b.minX); cx <= ix.cell(b.maxX); cx++ {
+		for cy := ix.cell(b.minY); cy <= ix.cell(b.maxY); cy++ {
+			for _, i := range ix.boxesAt(cx, cy) {
+				if ix.boxes[i].overlaps(b) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
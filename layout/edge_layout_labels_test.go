@@ -0,0 +1,158 @@
+package layout_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/nikolaydubina/go-graph-layout/layout"
+)
+
+type testBox struct {
+	name                   string
+	minX, minY, maxX, maxY int
+}
+
+// checkLabelsNoOverlap checks that labels do not overlap nodes and other labels.
+func checkLabelsNoOverlap(t *testing.T, g layout.Graph) {
+	var boxes []testBox
+	for n, node := range g.Nodes {
+		boxes = append(boxes, testBox{name: fmt.Sprintf("node(%d)", n), minX: node.XY[0], minY: node.XY[1], maxX: node.XY[0] + node.W, maxY: node.XY[1] + node.H})
+	}
+	numLabels := 0
+	for e, edge := range g.Edges {
+		if edge.Label.W == 0 {
+			continue
+		}
+		l := edge.Label
+		label := testBox{name: fmt.Sprintf("label(%v)", e), minX: l.XY[0], minY: l.XY[1], maxX: l.XY[0] + l.W, maxY: l.XY[1] + l.H}
+		for _, b := range boxes {
+			if label.minX < b.maxX && b.minX < label.maxX && label.minY < b.maxY && b.minY < label.maxY {
+				t.Errorf("%s %v overlaps %s %v", label.name, label, b.name, b)
+			}
+		}
+		boxes = append(boxes, label)
+		numLabels++
+	}
+	if numLabels == 0 {
+		t.Errorf("no labels")
+	}
+}
+
+func TestEdgeLabelsLayout(t *testing.T) {
+	t.Run("middle of path", func(t *testing.T) {
+		g := layout.Graph{
+			Nodes: map[uint64]layout.Node{
+				1: {XY: [2]int{0, 0}, W: 10, H: 10},
+				2: {XY: [2]int{200, 0}, W: 10, H: 10},
+			},
+			Edges: map[layout.EdgeID]layout.Edge{{1, 2}: {Label: layout.Label{W: 20, H: 10}}},
+		}
+		layout.SequenceLayout{Layouts: []layout.Layout{layout.DirectEdgesLayout{}, layout.EdgeLabelsLayout{}}}.UpdateGraphLayout(g)
+
+		if xy := g.Edges[layout.EdgeID{1, 2}].Label.XY; xy != [2]int{95, 0} {
+			t.Errorf("label at %v", xy)
+		}
+	})
+
+	t.Run("crowded", func(t *testing.T) {
+		g := layout.Graph{
+			Nodes: map[uint64]layout.Node{},
+			Edges: map[layout.EdgeID]layout.Edge{},
+		}
+		// star with center in middle of edges
+		for i := uint64(1); i <= 8; i++ {
+			g.Nodes[i] = layout.Node{XY: [2]int{100 * int(i%3), 100 * int(i/3)}, W: 40, H: 20}
+		}
+		for i := uint64(1); i <= 8; i++ {
+			for j := i + 1; j <= 8; j += 3 {
+				g.Edges[layout.EdgeID{i, j}] = layout.Edge{Label: layout.Label{W: 40, H: 15}}
+			}
+		}
+		layout.SequenceLayout{Layouts: []layout.Layout{layout.DirectEdgesLayout{}, layout.EdgeLabelsLayout{Margin: 2}}}.UpdateGraphLayout(g)
+		checkLabelsNoOverlap(t, g)
+	})
+
+	t.Run("dense", func(t *testing.T) {
+		// complete graph with nodes close to each other
+		dense := func() layout.Graph {
+			g := layout.Graph{Nodes: map[uint64]layout.Node{}, Edges: map[layout.EdgeID]layout.Edge{}}
+			for i := uint64(0); i < 12; i++ {
+				g.Nodes[i] = layout.Node{XY: [2]int{30 * int(i%4), 30 * int(i/4)}, W: 20, H: 20}
+				for j := uint64(0); j < i; j++ {
+					g.Edges[layout.EdgeID{j, i}] = layout.Edge{Label: layout.Label{W: 30, H: 10}}
+				}
+			}
+			layout.DirectEdgesLayout{}.UpdateGraphLayout(g)
+			return g
+		}
+
+		t.Run("no limit", func(t *testing.T) {
+			g := dense()
+			overlaps := -1
+			layout.EdgeLabelsLayout{Margin: 2, Observer: layout.ObserverFunc(func(e layout.Event) { overlaps = e.Overlaps })}.UpdateGraphLayout(g)
+			checkLabelsNoOverlap(t, g)
+			if overlaps != 0 {
+				t.Errorf("expected no overlaps, got %d", overlaps)
+			}
+		})
+
+		t.Run("limit", func(t *testing.T) {
+			g := dense()
+			var events []layout.Event
+			layout.EdgeLabelsLayout{Margin: 2, MaxRings: 1, Observer: layout.ObserverFunc(func(e layout.Event) { events = append(events, e) })}.UpdateGraphLayout(g)
+			if len(events) != 1 || events[0].Phase != layout.PhaseEdgeLabels || events[0].Overlaps == 0 {
+				t.Errorf("expected overlaps of labels, got %v", events)
+			}
+		})
+	})
+
+	t.Run("layers", func(t *testing.T) {
+		for _, d := range []layout.RankDirection{layout.TopToBottom, layout.BottomToTop, layout.LeftToRight, layout.RightToLeft} {
+			t.Run(fmt.Sprintf("direction(%d)", d), func(t *testing.T) {
+				_, g, err := parseJSONLGraph(labelsJSONL)
+				if err != nil {
+					t.Fatal(err)
+				}
+				l := newTestSugiyamaLayout()
+				l.RankDirection = d
+				if err := l.UpdateGraphLayoutE(*g); err != nil {
+					t.Fatal(err)
+				}
+				checkLabelsNoOverlap(t, *g)
+
+				// label is next to edge
+				for e, edge := range g.Edges {
+					if !isNearPath(edge.Label, edge.Path) {
+						t.Errorf("label of edge(%v) at %v is not near path %v", e, edge.Label, edge.Path)
+					}
+				}
+			})
+		}
+	})
+
+	t.Run("layers are inserted only for labeled edges", func(t *testing.T) {
+		g := layout.Graph{
+			Nodes: map[uint64]layout.Node{1: {W: 40, H: 20}, 2: {W: 40, H: 20}, 3: {W: 40, H: 20}},
+			Edges: map[layout.EdgeID]layout.Edge{{1, 2}: {Label: layout.Label{W: 40, H: 15}}, {2, 3}: {}},
+		}
+		if err := newTestSugiyamaLayout().UpdateGraphLayoutE(g); err != nil {
+			t.Fatal(err)
+		}
+		if path := g.Edges[layout.EdgeID{1, 2}].Path; len(path) != 3 {
+			t.Errorf("expected labeled edge to go through fake node, got %v", path)
+		}
+		if path := g.Edges[layout.EdgeID{2, 3}].Path; len(path) != 2 {
+			t.Errorf("expected edge without label to be short, got %v", path)
+		}
+	})
+}
+
+// isNearPath is when one of points of path is on border of label.
+func isNearPath(l layout.Label, path [][2]int) bool {
+	for _, p := range path {
+		if p[0] >= l.XY[0] && p[0] <= l.XY[0]+l.W && p[1] >= l.XY[1] && p[1] <= l.XY[1]+l.H {
+			return true
+		}
+	}
+	return false
+}
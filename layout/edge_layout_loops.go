@@ -0,0 +1,276 @@
+package layout
+
+import "math"
+
+const (
+	selfLoopSize         = 20 // distance from node to first self loop, next self loops of node are further
+	parallelEdgesSpacing = 10 // distance between middles of parallel edges that have same paths
+)
+
+// selfLoops sets paths of self loops as loops that go out of side of node and back.
+// Loops of same node are nested, labels are after loops.
+// Curves are set when smooth.
+func selfLoops(g Graph, side Side, smooth bool) {
+	count := make(map[uint64]int)
+	for _, e := range g.edgeIDs() {
+		if !e.isSelfLoop() {
+			continue
+		}
+		edge := g.Edges[e]
+		edge.Path, edge.Curve = selfLoop(g.Nodes[e[0]], side, count[e[0]])
+		if !smooth {
+			edge.Curve = nil
+		}
+		if !edge.Label.isEmpty() {
+			edge.Label.XY = selfLoopLabelXY(edge.Path, edge.Label, side)
+		}
+		g.Edges[e] = edge
+		count[e[0]]++
+	}
+}
+
+// selfLoop is path and curve of loop number i of node.
+// Loop starts and ends at side of node, a quarter of side away from its middle.
+func selfLoop(node Node, side Side, i int) (path, curve [][2]int) {
+	c := node.CenterXY()
+
+	// out is away from node, along is along side
+	var out, along [2]int
+	var half, span int
+	switch side {
+	case SideTop:
+		out, along, half, span = [2]int{0, -1}, [2]int{1, 0}, node.H/2, node.W/4
+	case SideBottom:
+		out, along, half, span = [2]int{0, 1}, [2]int{1, 0}, node.H-node.H/2, node.W/4
+	case SideLeft:
+		out, along, half, span = [2]int{-1, 0}, [2]int{0, 1}, node.W/2, node.H/4
+	default:
+		out, along, half, span = [2]int{1, 0}, [2]int{0, 1}, node.W-node.W/2, node.H/4
+	}
+
+	d := selfLoopSize * (i + 1)
+	at := func(o, a int) [2]int {
+		return [2]int{c[0] + out[0]*o + along[0]*a, c[1] + out[1]*o + along[1]*a}
+	}
+
+	path = [][2]int{at(half, -span), at(half+d, -span), at(half+d, span), at(half, span)}
+
+	// Bezier curve reaches 3/4 of distance of its control points
+	k := half + d*4/3
+	curve = [][2]int{path[0], at(k, -2*span), at(k, 2*span), path[3]}
+	return path, curve
+}
+
+// selfLoopLabelXY is position of label after farthest point of loop, centered on loop.
+func selfLoopLabelXY(path [][2]int, label Label, side Side) [2]int {
+	a, b := path[1], path[2]
+	switch side {
+	case SideTop:
+		return [2]int{(a[0]+b[0])/2 - label.W/2, a[1] - label.H}
+	case SideBottom:
+		return [2]int{(a[0]+b[0])/2 - label.W/2, a[1]}
+	case SideLeft:
+		return [2]int{a[0] - label.W, (a[1]+b[1])/2 - label.H/2}
+	default:
+		return [2]int{a[0], (a[1]+b[1])/2 - label.H/2}
+	}
+}
+
+// separateParallelEdges bends parallel edges that have same paths, so that each edge is visible.
+// Edges in both directions between same nodes are parallel.
+// Middle points of paths are moved across line between ends of path, and curves go through them.
+func separateParallelEdges(g Graph) {
+	for _, p := range sameParallelEdges(g) {
+		for j, e := range p.edges {
+			offset := (2*j - (len(p.edges) - 1)) * parallelEdgesSpacing / 2
+			if offset == 0 {
+				continue
+			}
+			path := offsetPath(p.path, offset)
+			if e[0] != p.from {
+				path = reversePoints(path)
+			}
+			edge := g.Edges[e]
+			edge.Path = path
+			edge.Curve = splineCurve(path, -1)
+			g.Edges[e] = edge
+		}
+	}
+}
+
+// shiftParallelEdges separates parallel edges that have same paths in layers layout, keeping ends at ports.
+// Orthogonal paths are moved across both axes with jogs in first and last segments, so they stay orthogonal.
+// Other paths are moved along layers, so curves still start and end along rank axis.
+func shiftParallelEdges(g Graph, rankAxis int) {
+	for _, p := range sameParallelEdges(g) {
+		for j, e := range p.edges {
+			offset := (2*j - (len(p.edges) - 1)) * parallelEdgesSpacing / 2
+			if offset == 0 {
+				continue
+			}
+			var path [][2]int
+			if isOrthogonalPath(p.path) {
+				to := e[0]
+				if e[0] == p.from {
+					to = e[1]
+				}
+				path = jogPath(p.path, g.Nodes[p.from], g.Nodes[to], rankAxis, offset)
+			} else {
+				path = bendPath(p.path, 1-rankAxis, offset)
+			}
+			if e[0] != p.from {
+				path = reversePoints(path)
+			}
+			edge := g.Edges[e]
+			edge.Path = path
+			if edge.Curve != nil {
+				edge.Curve = splineCurve(path, rankAxis)
+			}
+			g.Edges[e] = edge
+		}
+	}
+}
+
+func isOrthogonalPath(path [][2]int) bool {
+	for i := 0; i+1 < len(path); i++ {
+		if path[i][0] != path[i+1][0] && path[i][1] != path[i+1][1] {
+			return false
+		}
+	}
+	return true
+}
+
+// jogPath moves middle of path by offset in both axes and connects it to ends by jogs along borders of end nodes.
+// First and last segments of path are along rank axis.
+func jogPath(path [][2]int, from, to Node, rankAxis int, offset int) [][2]int {
+	n := len(path)
+	var layer [2]int
+	layer[1-rankAxis] = offset
+	both := [2]int{offset, offset}
+
+	first, last := leavePoint(path[0], path[1], from), leavePoint(path[n-1], path[n-2], to)
+	moved := [][2]int{path[0], first, addPoints(first, layer)}
+	for _, p := range path[1 : n-1] {
+		moved = append(moved, addPoints(p, both))
+	}
+	return append(moved, addPoints(last, layer), last, path[n-1])
+}
+
+// leavePoint is where orthogonal segment from p to q leaves box of node, or p when p is not inside of node.
+func leavePoint(p, q [2]int, node Node) [2]int {
+	b := box{minX: node.XY[0], minY: node.XY[1], maxX: node.XY[0] + node.W, maxY: node.XY[1] + node.H}
+	if !b.containsPoint(p) {
+		return p
+	}
+	bounds := [2][2]int{{b.minX, b.maxX}, {b.minY, b.maxY}}
+	for axis := 0; axis < 2; axis++ {
+		switch {
+		case q[axis] < p[axis]:
+			p[axis] = maxInt(q[axis], bounds[axis][0])
+		case q[axis] > p[axis]:
+			p[axis] = minInt(q[axis], bounds[axis][1])
+		}
+	}
+	return p
+}
+
+// bendPath moves points of path except ends along axis by offset.
+// Path of two points gets point in the middle.
+func bendPath(path [][2]int, axis int, offset int) [][2]int {
+	if len(path) == 2 {
+		path = [][2]int{path[0], {(path[0][0] + path[1][0]) / 2, (path[0][1] + path[1][1]) / 2}, path[1]}
+	}
+	moved := make([][2]int, len(path))
+	copy(moved, path)
+	for i := 1; i+1 < len(path); i++ {
+		moved[i][axis] += offset
+	}
+	return moved
+}
+
+func addPoints(a, b [2]int) [2]int { return [2]int{a[0] + b[0], a[1] + b[1]} }
+
+// parallelEdges are edges between same nodes that have same path.
+type parallelEdges struct {
+	from  uint64   // path goes from this node
+	path  [][2]int // path in direction from node
+	edges []EdgeID // at least two edges
+}
+
+func sameParallelEdges(g Graph) []parallelEdges {
+	groups := make(map[[2]uint64][]EdgeID)
+	var pairs [][2]uint64
+	for _, e := range g.edgeIDs() {
+		if e.isSelfLoop() {
+			continue
+		}
+		pair := [2]uint64{e[0], e[1]}
+		if pair[0] > pair[1] {
+			pair[0], pair[1] = pair[1], pair[0]
+		}
+		if _, ok := groups[pair]; !ok {
+			pairs = append(pairs, pair)
+		}
+		groups[pair] = append(groups[pair], e)
+	}
+
+	var parallel []parallelEdges
+	for _, pair := range pairs {
+		// paths in direction of pair
+		var same []parallelEdges
+		for _, e := range groups[pair] {
+			path := g.Edges[e].Path
+			if e[0] != pair[0] {
+				path = reversePoints(path)
+			}
+			i := 0
+			for i < len(same) && !equalPoints(same[i].path, path) {
+				i++
+			}
+			if i == len(same) {
+				same = append(same, parallelEdges{from: pair[0], path: path})
+			}
+			same[i].edges = append(same[i].edges, e)
+		}
+		for _, p := range same {
+			if len(p.edges) >= 2 && len(p.path) >= 2 {
+				parallel = append(parallel, p)
+			}
+		}
+	}
+	return parallel
+}
+
+// offsetPath moves points of path except ends across line from start to end of path.
+// Path of two points gets point in the middle.
+func offsetPath(path [][2]int, offset int) [][2]int {
+	a, b := toFloat(path[0]), toFloat(path[len(path)-1])
+	dx, dy := b[0]-a[0], b[1]-a[1]
+	length := math.Hypot(dx, dy)
+	if length == 0 {
+		return path
+	}
+	normal := [2]float64{-dy / length * float64(offset), dx / length * float64(offset)}
+
+	if len(path) == 2 {
+		path = [][2]int{path[0], toInt(lerp(a, b, 0.5)), path[1]}
+	}
+	moved := make([][2]int, len(path))
+	copy(moved, path)
+	for i := 1; i+1 < len(path); i++ {
+		moved[i] = [2]int{path[i][0] + int(normal[0]), path[i][1] + int(normal[1])}
+	}
+	return moved
+}
+
+func equalPoints(a, b [][2]int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
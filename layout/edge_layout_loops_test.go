@@ -0,0 +1,101 @@
+package layout_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/nikolaydubina/go-graph-layout/layout"
+)
+
+func newTestMultiGraph() layout.Graph {
+	return layout.Graph{
+		Nodes: map[uint64]layout.Node{
+			1: {XY: [2]int{0, 0}, W: 40, H: 20},
+			2: {XY: [2]int{200, 0}, W: 40, H: 20},
+			3: {XY: [2]int{100, 100}, W: 40, H: 20},
+		},
+		Edges: map[layout.EdgeID]layout.Edge{
+			{1, 2, 0}: {},
+			{1, 2, 1}: {},
+			{2, 1, 0}: {},
+			{1, 1, 0}: {},
+			{1, 1, 1}: {},
+			{2, 3, 0}: {},
+			{3, 3, 0}: {Label: layout.Label{W: 20, H: 10}},
+		},
+	}
+}
+
+// checkMultiEdges checks that each edge has own path and that self loops go around outside of their nodes.
+func checkMultiEdges(t *testing.T, g layout.Graph) {
+	t.Helper()
+	paths := map[string]layout.EdgeID{}
+	for e, edge := range g.Edges {
+		if len(edge.Path) < 2 {
+			t.Errorf("edge %v has no path", e)
+			continue
+		}
+		key := fmt.Sprint(edge.Path, edge.Curve)
+		if other, ok := paths[key]; ok {
+			t.Errorf("edges %v and %v have same path %v", e, other, edge.Path)
+		}
+		paths[key] = e
+
+		if e[0] != e[1] {
+			continue
+		}
+		node := g.Nodes[e[0]]
+		for _, p := range edge.Path[1 : len(edge.Path)-1] {
+			if p[0] >= node.XY[0] && p[0] <= node.XY[0]+node.W && p[1] >= node.XY[1] && p[1] <= node.XY[1]+node.H {
+				t.Errorf("self loop %v has point %v in node %v", e, p, node)
+			}
+		}
+	}
+}
+
+func TestSelfLoopsAndParallelEdges(t *testing.T) {
+	t.Run("direct", func(t *testing.T) {
+		g := newTestMultiGraph()
+		layout.DirectEdgesLayout{}.UpdateGraphLayout(g)
+		checkMultiEdges(t, g)
+
+		// next loop of same node is further from node
+		a, b := g.Edges[layout.EdgeID{1, 1, 0}].Path, g.Edges[layout.EdgeID{1, 1, 1}].Path
+		if b[1][0] <= a[1][0] {
+			t.Errorf("loops are not nested %v %v", a, b)
+		}
+	})
+
+	t.Run("obstacles", func(t *testing.T) {
+		g := newTestMultiGraph()
+		layout.ObstacleAvoidingEdgesLayout{Margin: 5, Smooth: true}.UpdateGraphLayout(g)
+		checkMultiEdges(t, g)
+	})
+
+	t.Run("orthogonal", func(t *testing.T) {
+		g := newTestMultiGraph()
+		layout.OrthogonalEdgesLayout{Margin: 5}.UpdateGraphLayout(g)
+		for e, edge := range g.Edges {
+			if e[0] == e[1] && len(edge.Path) < 2 {
+				t.Errorf("self loop %v has no path", e)
+			}
+		}
+	})
+
+	t.Run("layers", func(t *testing.T) {
+		for _, d := range []layout.RankDirection{layout.TopToBottom, layout.BottomToTop, layout.LeftToRight, layout.RightToLeft} {
+			t.Run(fmt.Sprintf("direction(%d)", d), func(t *testing.T) {
+				g := newTestMultiGraph()
+				l := newTestSugiyamaLayout()
+				l.RankDirection = d
+				if err := l.UpdateGraphLayoutE(g); err != nil {
+					t.Fatal(err)
+				}
+				if len(g.Edges) != len(newTestMultiGraph().Edges) {
+					t.Errorf("expected all edges after layout, got %v", g.Edges)
+				}
+				checkMultiEdges(t, g)
+			})
+		}
+	})
+}
@@ -0,0 +1,355 @@
+package layout
+
+import (
+	"container/heap"
+	"math"
+)
+
+// ObstacleAvoidingEdgesLayout routes edges by shortest paths around boxes of nodes.
+// Paths go through corners of boxes of nodes extended by Margin, as in visibility graph.
+// Edges that do not have nodes in the way are straight lines.
+// Edges start and end at ports of nodes, boxes of own nodes of edge are not obstacles.
+// Visibility of corners is computed only for corners reached by search, and only for lines tangent to boxes at corners,
+// and checks only boxes on the way in spatial index, so that it is cheap for graphs of hundreds of nodes.
+// Edge that can not be routed, for example when it ends inside of other node, is straight line.
+// "An algorithm for planning collision-free paths among polyhedral obstacles", T. Lozano-Pérez, M. A. Wesley, 1979
+type ObstacleAvoidingEdgesLayout struct {
+	Margin int  // distance from boxes of nodes to edges
+	Smooth bool // set Curve through points of path, curve may come closer to nodes than Margin
+}
+
+func (l ObstacleAvoidingEdgesLayout) UpdateGraphLayout(g Graph) {
+	r := newVisibilityRouter(g, l.Margin)
+	for _, e := range g.edgeIDs() {
+		if e.isSelfLoop() {
+			continue
+		}
+		edge := g.Edges[e]
+		edge.Path = r.route(g, e)
+		edge.Curve = nil
+		if l.Smooth {
+			edge.Curve = splineCurve(edge.Path, -1)
+		}
+		g.Edges[e] = edge
+	}
+	selfLoops(g, SideRight, l.Smooth)
+	if l.Smooth {
+		separateParallelEdges(g)
+	}
+}
+
+// UpdateGraphLayoutE validates graph and updates its layout.
+func (l ObstacleAvoidingEdgesLayout) UpdateGraphLayoutE(g Graph) error {
+	if err := g.Validate(); err != nil {
+		return err
+	}
+	l.UpdateGraphLayout(g)
+	return nil
+}
+
+// intersectsSegment is when segment goes through inside of box, touching border is not intersection.
+func (b box) intersectsSegment(p, q [2]int) bool {
+	lo, hi := 0.0, 1.0
+	for axis, bounds := range [2][2]int{{b.minX, b.maxX}, {b.minY, b.maxY}} {
+		start, d := float64(p[axis]), float64(q[axis]-p[axis])
+		if d == 0 {
+			if p[axis] <= bounds[0] || p[axis] >= bounds[1] {
+				return false
+			}
+			continue
+		}
+		t0, t1 := (float64(bounds[0])-start)/d, (float64(bounds[1])-start)/d
+		if t0 > t1 {
+			t0, t1 = t1, t0
+		}
+		lo, hi = math.Max(lo, t0), math.Min(hi, t1)
+	}
+	return lo < hi
+}
+
+func (b box) containsPoint(p [2]int) bool {
+	return b.minX < p[0] && p[0] < b.maxX && b.minY < p[1] && p[1] < b.maxY
+}
+
+// boxIndex is uniform grid of cells with boxes that overlap each cell.
+type boxIndex struct {
+	boxes      []box
+	size       int
+	minX, minY int // first cell
+	nx, ny     int // number of cells
+	cells      [][]int
+}
+
+func newBoxIndex(boxes []box) boxIndex {
+	// cells of average size of box
+	size := 1
+	if len(boxes) > 0 {
+		sum := 0
+		for _, b := range boxes {
+			sum += (b.maxX - b.minX) + (b.maxY - b.minY)
+		}
+		if s := sum / (2 * len(boxes)); s > size {
+			size = s
+		}
+	}
+
+	ix := boxIndex{boxes: boxes, size: size}
+	if len(boxes) == 0 {
+		return ix
+	}
+	maxX, maxY := boxes[0].maxX, boxes[0].maxY
+	ix.minX, ix.minY = boxes[0].minX, boxes[0].minY
+	for _, b := range boxes {
+		ix.minX, ix.minY = minInt(ix.minX, b.minX), minInt(ix.minY, b.minY)
+		maxX, maxY = maxInt(maxX, b.maxX), maxInt(maxY, b.maxY)
+	}
+	ix.minX, ix.minY = ix.cell(ix.minX), ix.cell(ix.minY)
+	ix.nx, ix.ny = ix.cell(maxX)-ix.minX+1, ix.cell(maxY)-ix.minY+1
+	ix.cells = make([][]int, ix.nx*ix.ny)
+
+	for i, b := range boxes {
+		for cx := ix.cell(b.minX); cx <= ix.cell(b.maxX); cx++ {
+			for cy := ix.cell(b.minY); cy <= ix.cell(b.maxY); cy++ {
+				c := (cx-ix.minX)*ix.ny + (cy - ix.minY)
+				ix.cells[c] = append(ix.cells[c], i)
+			}
+		}
+	}
+	return ix
+}
+
+// cell is coordinate of cell, rounded down.
+func (ix boxIndex) cell(v int) int {
+	if v < 0 {
+		return -((-v + ix.size - 1) / ix.size)
+	}
+	return v / ix.size
+}
+
+// boxesAt are boxes that overlap cell.
+func (ix boxIndex) boxesAt(cx, cy int) []int {
+	cx, cy = cx-ix.minX, cy-ix.minY
+	if cx < 0 || cy < 0 || cx >= ix.nx || cy >= ix.ny {
+		return nil
+	}
+	return ix.cells[cx*ix.ny+cy]
+}
+
+// isBlocked is when segment goes through box that is not skipped.
+// Checks boxes in cells along segment, column by column.
+func (ix boxIndex) isBlocked(p, q [2]int, skip func(i int) bool) bool {
+	if p[0] > q[0] {
+		p, q = q, p
+	}
+	for cx := ix.cell(p[0]); cx <= ix.cell(q[0]); cx++ {
+		// part of segment within column
+		ya, yb := float64(p[1]), float64(q[1])
+		if dx := q[0] - p[0]; dx != 0 {
+			slope := float64(q[1]-p[1]) / float64(dx)
+			xa := math.Max(float64(p[0]), float64(cx*ix.size))
+			xb := math.Min(float64(q[0]), float64((cx+1)*ix.size))
+			ya = float64(p[1]) + slope*(xa-float64(p[0]))
+			yb = float64(p[1]) + slope*(xb-float64(p[0]))
+		}
+		if ya > yb {
+			ya, yb = yb, ya
+		}
+		for cy := ix.cell(int(math.Floor(ya))); cy <= ix.cell(int(math.Ceil(yb))); cy++ {
+			for _, i := range ix.boxesAt(cx, cy) {
+				if !skip(i) && ix.boxes[i].intersectsSegment(p, q) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func (ix boxIndex) containsPoint(p [2]int, skip func(i int) bool) bool {
+	for _, i := range ix.boxesAt(ix.cell(p[0]), ix.cell(p[1])) {
+		if !skip(i) && ix.boxes[i].containsPoint(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// corner of box, box is in direction {sx, sy} from corner.
+type corner struct {
+	xy     [2]int
+	sx, sy int
+}
+
+// isTangent is when line through corner towards point does not go inside of box of corner.
+// Shortest paths bend only at such lines.
+func (c corner) isTangent(p [2]int) bool {
+	return (p[0]-c.xy[0])*c.sx*(p[1]-c.xy[1])*c.sy <= 0
+}
+
+// visibilityRouter finds shortest paths through corners of boxes.
+// Visible corners are computed on demand and reused for all edges.
+type visibilityRouter struct {
+	index   boxIndex
+	nodeBox map[uint64]int
+	corners []corner
+	visible map[int][]int // corner -> visible corners
+}
+
+func newVisibilityRouter(g Graph, margin int) *visibilityRouter {
+	nodes := g.nodeIDs()
+	boxes := make([]box, len(nodes))
+	r := &visibilityRouter{nodeBox: make(map[uint64]int, len(nodes)), visible: map[int][]int{}}
+	for i, n := range nodes {
+		node := g.Nodes[n]
+		boxes[i] = box{
+			minX: node.XY[0] - margin,
+			minY: node.XY[1] - margin,
+			maxX: node.XY[0] + node.W + margin,
+			maxY: node.XY[1] + node.H + margin,
+		}
+		r.nodeBox[n] = i
+	}
+	r.index = newBoxIndex(boxes)
+
+	none := func(int) bool { return false }
+	for _, b := range boxes {
+		for _, c := range [4]corner{
+			{xy: [2]int{b.minX, b.minY}, sx: 1, sy: 1},
+			{xy: [2]int{b.maxX, b.minY}, sx: -1, sy: 1},
+			{xy: [2]int{b.maxX, b.maxY}, sx: -1, sy: -1},
+			{xy: [2]int{b.minX, b.maxY}, sx: 1, sy: -1},
+		} {
+			if !r.index.containsPoint(c.xy, none) {
+				r.corners = append(r.corners, c)
+			}
+		}
+	}
+	return r
+}
+
+// visibleCorners are corners that can be reached by straight line from corner.
+func (r *visibilityRouter) visibleCorners(c int) []int {
+	if v, ok := r.visible[c]; ok {
+		return v
+	}
+	none := func(int) bool { return false }
+	v := []int{}
+	from := r.corners[c]
+	for o, to := range r.corners {
+		if o != c && from.xy != to.xy && from.isTangent(to.xy) && to.isTangent(from.xy) && !r.index.isBlocked(from.xy, to.xy, none) {
+			v = append(v, o)
+		}
+	}
+	r.visible[c] = v
+	return v
+}
+
+type visibilityItem struct {
+	vertex int
+	f      float64
+}
+
+type visibilityHeap []visibilityItem
+
+func (h visibilityHeap) Len() int { return len(h) }
+
+func (h visibilityHeap) Less(i, j int) bool {
+	if h[i].f != h[j].f {
+		return h[i].f < h[j].f
+	}
+	return h[i].vertex < h[j].vertex
+}
+
+func (h visibilityHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *visibilityHeap) Push(x interface{}) { *h = append(*h, x.(visibilityItem)) }
+
+func (h *visibilityHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// route finds shortest path from port to port with A* search.
+// Start and end are vertices after corners.
+func (r *visibilityRouter) route(g Graph, e EdgeID) [][2]int {
+	edge := g.Edges[e]
+	from := g.Nodes[e[0]].PortXY(edge.FromPort)
+	to := g.Nodes[e[1]].PortXY(edge.ToPort)
+
+	own := func(i int) bool { return i == r.nodeBox[e[0]] || i == r.nodeBox[e[1]] }
+	if from == to || !r.index.isBlocked(from, to, own) {
+		return [][2]int{from, to}
+	}
+
+	start, end := len(r.corners), len(r.corners)+1
+	xy := func(v int) [2]int {
+		switch v {
+		case start:
+			return from
+		case end:
+			return to
+		default:
+			return r.corners[v].xy
+		}
+	}
+	dist := func(a, b [2]int) float64 { return math.Hypot(float64(a[0]-b[0]), float64(a[1]-b[1])) }
+
+	best := make([]float64, end+1)
+	for i := range best {
+		best[i] = math.Inf(1)
+	}
+	best[start] = 0
+	parent := make([]int, end+1)
+	done := make([]bool, end+1)
+	que := &visibilityHeap{{vertex: start, f: dist(from, to)}}
+
+	for que.Len() > 0 {
+		u := heap.Pop(que).(visibilityItem).vertex
+		if done[u] {
+			continue
+		}
+		done[u] = true
+
+		if u == end {
+			var path [][2]int
+			for v := end; ; v = parent[v] {
+				path = append(path, xy(v))
+				if v == start {
+					break
+				}
+			}
+			return reversePoints(path)
+		}
+
+		var next []int
+		if u == start {
+			for c, corner := range r.corners {
+				if corner.isTangent(from) && !r.index.isBlocked(from, corner.xy, own) {
+					next = append(next, c)
+				}
+			}
+		} else {
+			next = r.visibleCorners(u)
+			if r.corners[u].isTangent(to) && !r.index.isBlocked(r.corners[u].xy, to, own) {
+				next = append(next[:len(next):len(next)], end)
+			}
+		}
+
+		for _, v := range next {
+			if done[v] {
+				continue
+			}
+			d := best[u] + dist(xy(u), xy(v))
+			if best[v] <= d {
+				continue
+			}
+			best[v] = d
+			parent[v] = u
+			heap.Push(que, visibilityItem{vertex: v, f: d + dist(xy(v), to)})
+		}
+	}
+
+	return [][2]int{from, to}
+}
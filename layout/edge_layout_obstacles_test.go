@@ -0,0 +1,97 @@
+package layout_test
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/nikolaydubina/go-graph-layout/layout"
+)
+
+// crossesNode checks points along segment, end points are not checked.
+func crossesNode(a, b [2]int, node layout.Node) bool {
+	const steps = 100
+	for i := 1; i < steps; i++ {
+		x := a[0] + (b[0]-a[0])*i/steps
+		y := a[1] + (b[1]-a[1])*i/steps
+		if x > node.XY[0] && x < node.XY[0]+node.W && y > node.XY[1] && y < node.XY[1]+node.H {
+			return true
+		}
+	}
+	return false
+}
+
+func checkPathsAvoidNodes(t *testing.T, g layout.Graph) {
+	t.Helper()
+	for e, edge := range g.Edges {
+		path := edge.Path
+		if len(path) < 2 || path[0] != g.Nodes[e[0]].PortXY(edge.FromPort) || path[len(path)-1] != g.Nodes[e[1]].PortXY(edge.ToPort) {
+			t.Errorf("edge %v has path %v that does not connect ports", e, path)
+			continue
+		}
+		for i := 1; i < len(path); i++ {
+			for n, node := range g.Nodes {
+				if n != e[0] && n != e[1] && crossesNode(path[i-1], path[i], node) {
+					t.Errorf("edge %v segment %v %v goes through node %d %v", e, path[i-1], path[i], n, node)
+				}
+			}
+		}
+	}
+}
+
+func TestObstacleAvoidingEdgesLayout(t *testing.T) {
+	t.Run("straight when nothing in the way", func(t *testing.T) {
+		g := layout.Graph{
+			Nodes: map[uint64]layout.Node{
+				1: {XY: [2]int{0, 0}, W: 20, H: 20},
+				2: {XY: [2]int{100, 100}, W: 20, H: 20},
+			},
+			Edges: map[layout.EdgeID]layout.Edge{{1, 2}: {}},
+		}
+		layout.ObstacleAvoidingEdgesLayout{Margin: 5}.UpdateGraphLayout(g)
+
+		if path := g.Edges[layout.EdgeID{1, 2}].Path; len(path) != 2 {
+			t.Errorf("expected straight line, got %v", path)
+		}
+	})
+
+	t.Run("around node", func(t *testing.T) {
+		g := layout.Graph{
+			Nodes: map[uint64]layout.Node{
+				1: {XY: [2]int{0, 0}, W: 20, H: 20},
+				2: {XY: [2]int{100, -20}, W: 20, H: 80},
+				3: {XY: [2]int{200, 0}, W: 20, H: 20},
+			},
+			Edges: map[layout.EdgeID]layout.Edge{{1, 3}: {}},
+		}
+		layout.ObstacleAvoidingEdgesLayout{Margin: 5, Smooth: true}.UpdateGraphLayout(g)
+
+		checkPathsAvoidNodes(t, g)
+		edge := g.Edges[layout.EdgeID{1, 3}]
+		if len(edge.Path) != 4 {
+			t.Errorf("expected path through two corners, got %v", edge.Path)
+		}
+		if len(edge.Curve) != 1+3*(len(edge.Path)-1) {
+			t.Errorf("expected curve through path %v, got %v", edge.Path, edge.Curve)
+		}
+	})
+
+	t.Run("hundreds of nodes", func(t *testing.T) {
+		r := rand.New(rand.NewSource(1))
+		g := layout.Graph{
+			Nodes: map[uint64]layout.Node{},
+			Edges: map[layout.EdgeID]layout.Edge{},
+		}
+		const w, h = 20, 15
+		for i := 0; i < w*h; i++ {
+			g.Nodes[uint64(i)] = layout.Node{XY: [2]int{(i % w) * 100, (i / w) * 80}, W: 40 + r.Intn(20), H: 20 + r.Intn(20)}
+		}
+		for i := 0; i < 2*w*h; i++ {
+			if a, b := uint64(r.Intn(w*h)), uint64(r.Intn(w*h)); a != b {
+				g.Edges[layout.EdgeID{a, b}] = layout.Edge{}
+			}
+		}
+
+		layout.ObstacleAvoidingEdgesLayout{Margin: 5}.UpdateGraphLayout(g)
+		checkPathsAvoidNodes(t, g)
+	})
+}
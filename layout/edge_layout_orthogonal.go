@@ -0,0 +1,730 @@
+package layout
+
+import (
+	"container/heap"
+	"sort"
+)
+
+// OrthogonalEdgesLayout routes edges with horizontal and vertical segments around boxes of nodes.
+// Each edge has fewest bends possible, and then shortest length.
+// Edges start and end at ports of nodes, and leave nodes in direction of side of port.
+// Edges routed on orthogonal visibility graph of boxes of nodes extended by Margin and ends of edges, that is made once for all edges.
+// Parallel segments that share same channel are moved apart by Spacing, but not more than Margin from channel.
+// Can be used after any layout that places nodes, and as edge path assigner in layered layout.
+// Edge that can not be routed, for example when it ends inside of other node, is set to path with one bend.
+// "Orthogonal Connector Routing", M. Wybrow, K. Marriott, P. J. Stuckey, 2009
+type OrthogonalEdgesLayout struct {
+	Margin  int // distance from boxes of nodes to edges
+	Spacing int // distance between parallel segments in same channel
+}
+
+func (l OrthogonalEdgesLayout) UpdateGraphLayout(g Graph) {
+	r := newOrthogonalRouter(g, l.Margin)
+
+	paths := make(map[EdgeID][][2]int, len(g.Edges))
+	for _, e := range g.edgeIDs() {
+		if !e.isSelfLoop() {
+			paths[e] = r.route(g, e)
+		}
+	}
+	l.nudge(g, paths)
+
+	for e, path := range paths {
+		edge := g.Edges[e]
+		edge.Path = path
+		edge.Curve = nil
+		g.Edges[e] = edge
+	}
+	selfLoops(g, SideRight, false)
+}
+
+// UpdateGraphLayoutE validates graph and updates its layout.
+func (l OrthogonalEdgesLayout) UpdateGraphLayoutE(g Graph) error {
+	if err := g.Validate(); err != nil {
+		return err
+	}
+	l.UpdateGraphLayout(g)
+	return nil
+}
+
+// AssignEdgePaths routes edges in layered layout, where real nodes are centered at allNodesXY.
+// Fake nodes are not used, edges are routed around real nodes.
+func (l OrthogonalEdgesLayout) AssignEdgePaths(g Graph, _ LayeredGraph, allNodesXY map[uint64][2]int) {
+	placed := Graph{Nodes: make(map[uint64]Node, len(g.Nodes)), Edges: g.Edges}
+	for n, node := range g.Nodes {
+		node.XY = [2]int{allNodesXY[n][0] - node.W/2, allNodesXY[n][1] - node.H/2}
+		placed.Nodes[n] = node
+	}
+	l.UpdateGraphLayout(placed)
+}
+
+// nudge moves apart segments of different edges that are on same line and overlap.
+// First and last segments of edges are not moved, so that edges stay attached to ports.
+// Segments are moved by at most Margin and by at most distance to boxes of nodes next to them, so they do not go into nodes.
+func (l OrthogonalEdgesLayout) nudge(g Graph, paths map[EdgeID][][2]int) {
+	type segment struct {
+		edge   EdgeID
+		k      int // segment from point k to point k+1
+		lo, hi int // range along line
+		score  int // where segment turns to at its ends, negative is towards smaller coordinates
+	}
+
+	// segments by {axis of coordinate, coordinate}
+	channels := map[[2]int][]segment{}
+	edges := make([]EdgeID, 0, len(paths))
+	for e := range paths {
+		edges = append(edges, e)
+	}
+	sortEdgeIDs(edges)
+	for _, e := range edges {
+		path := paths[e]
+		for k := 1; k+2 < len(path); k++ {
+			a, b := path[k], path[k+1]
+			axis, along := 0, 1 // vertical segment
+			if a[1] == b[1] {
+				axis, along = 1, 0
+			}
+			s := segment{edge: e, k: k, lo: a[along], hi: b[along]}
+			if s.lo > s.hi {
+				s.lo, s.hi = s.hi, s.lo
+			}
+			s.score = sign(path[k-1][axis]-a[axis]) + sign(path[k+2][axis]-b[axis])
+			key := [2]int{axis, a[axis]}
+			channels[key] = append(channels[key], s)
+		}
+	}
+
+	keys := make([][2]int, 0, len(channels))
+	for k := range channels {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i][0] != keys[j][0] {
+			return keys[i][0] < keys[j][0]
+		}
+		return keys[i][1] < keys[j][1]
+	})
+
+	type move struct {
+		edge   EdgeID
+		k      int
+		axis   int
+		offset int
+	}
+	var moves []move
+
+	for _, key := range keys {
+		segments := channels[key]
+		sort.SliceStable(segments, func(i, j int) bool { return segments[i].lo < segments[j].lo })
+
+		// groups of overlapping segments
+		for start := 0; start < len(segments); {
+			end, hi := start+1, segments[start].hi
+			for end < len(segments) && segments[end].lo < hi {
+				if segments[end].hi > hi {
+					hi = segments[end].hi
+				}
+				end++
+			}
+			group, lo := segments[start:end], segments[start].lo
+			start = end
+
+			if len(group) < 2 {
+				continue
+			}
+			sort.SliceStable(group, func(i, j int) bool {
+				if group[i].score != group[j].score {
+					return group[i].score < group[j].score
+				}
+				if group[i].edge[0] != group[j].edge[0] {
+					return group[i].edge[0] < group[j].edge[0]
+				}
+				return group[i].edge[1] < group[j].edge[1]
+			})
+
+			// distance from channel to closest box of node next to group, up to Margin
+			room := l.Margin
+			for _, node := range g.Nodes {
+				minB, maxB := node.XY, [2]int{node.XY[0] + node.W, node.XY[1] + node.H}
+				along := 1 - key[0]
+				if maxB[along] <= lo || minB[along] >= hi {
+					continue
+				}
+				if v := key[1] - maxB[key[0]]; v >= 0 && v < room {
+					room = v
+				}
+				if v := minB[key[0]] - key[1]; v >= 0 && v < room {
+					room = v
+				}
+			}
+
+			step := l.Spacing
+			if n := len(group) - 1; step*n > 2*room {
+				step = 2 * room / n
+			}
+			for i, s := range group {
+				moves = append(moves, move{edge: s.edge, k: s.k, axis: key[0], offset: i*step - (len(group)-1)*step/2})
+			}
+		}
+	}
+
+	for _, m := range moves {
+		path := paths[m.edge]
+		path[m.k][m.axis] += m.offset
+		path[m.k+1][m.axis] += m.offset
+	}
+}
+
+func sign(v int) int {
+	switch {
+	case v > 0:
+		return 1
+	case v < 0:
+		return -1
+	default:
+		return 0
+	}
+}
+
+// box is rectangle, points on its border are outside of it.
+type box struct {
+	minX, minY, maxX, maxY int
+}
+
+// containsSegment is when middle of segment is inside of box.
+func (b box) containsSegment(p, q [2]int) bool {
+	x, y := p[0]+q[0], p[1]+q[1]
+	return 2*b.minX < x && x < 2*b.maxX && 2*b.minY < y && y < 2*b.maxY
+}
+
+// directions of moves on visibility graph
+var orthogonalMoves = [4][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}}
+
+// sideDirection is direction of move away from node through side.
+func sideDirection(s Side) int {
+	switch s {
+	case SideRight:
+		return 0
+	case SideLeft:
+		return 1
+	case SideBottom:
+		return 2
+	default:
+		return 3
+	}
+}
+
+// orthogonalRouter finds paths in orthogonal visibility graph, that is made once for all edges.
+// Lines go from corners of boxes of nodes and from ends of edges until they meet other boxes,
+// lines from ends of edges go through boxes of their nodes.
+// Vertices are intersections of lines, and edges of visibility graph connect next vertices on same line.
+type orthogonalRouter struct {
+	points  [][2]int
+	vertex  map[[2]int]int
+	next    [][4]int // vertex -> next vertex in each direction, -1 when none
+	blocked [][4]int // vertex -> number of boxes that contain segment to next vertex in each direction
+	boxes   map[uint64]box
+
+	// search state, reused by routes
+	bends     []int // fewest bends from state to target
+	bendsSeen []int // route that found bends of state
+	best      []orthogonalCost
+	parent    []int
+	seen      []int // route that reached state
+	routes    int
+}
+
+// orthogonalLine is segment along axis at coordinate v across axis, from lo to hi along axis.
+type orthogonalLine struct {
+	v, lo, hi int
+	vertices  []int
+}
+
+// bounds is range of box along axis.
+func (b box) bounds(axis int) (lo, hi int) {
+	if axis == 0 {
+		return b.minX, b.maxX
+	}
+	return b.minY, b.maxY
+}
+
+// time: O((V + E) * V) to make lines, O(L log L + I) to intersect them, where L is number of lines and I is number of intersections
+func newOrthogonalRouter(g Graph, margin int) *orthogonalRouter {
+	r := &orthogonalRouter{vertex: make(map[[2]int]int), boxes: make(map[uint64]box, len(g.Nodes))}
+
+	nodes := g.nodeIDs()
+	boxes := make([]box, len(nodes))
+	nodeBox := make(map[uint64]int, len(nodes))
+	for i, n := range nodes {
+		node := g.Nodes[n]
+		boxes[i] = box{
+			minX: node.XY[0] - margin,
+			minY: node.XY[1] - margin,
+			maxX: node.XY[0] + node.W + margin,
+			maxY: node.XY[1] + node.H + margin,
+		}
+		r.boxes[n] = boxes[i]
+		nodeBox[n] = i
+	}
+
+	// starts of lines, with box that line goes through
+	type source struct {
+		xy  [2]int
+		own int
+	}
+	var sources []source
+	for _, b := range boxes {
+		for _, xy := range [4][2]int{{b.minX, b.minY}, {b.maxX, b.minY}, {b.minX, b.maxY}, {b.maxX, b.maxY}} {
+			sources = append(sources, source{xy: xy, own: -1})
+		}
+	}
+	for _, e := range g.edgeIDs() {
+		edge := g.Edges[e]
+		for _, end := range [2]struct {
+			n    uint64
+			port string
+		}{{e[0], edge.FromPort}, {e[1], edge.ToPort}} {
+			own, ok := nodeBox[end.n]
+			if !ok {
+				own = -1
+			}
+			sources = append(sources, source{xy: g.Nodes[end.n].PortXY(end.port), own: own})
+		}
+	}
+	if len(sources) == 0 {
+		return r
+	}
+
+	limits := [2][2]int{{sources[0].xy[0], sources[0].xy[0]}, {sources[0].xy[1], sources[0].xy[1]}}
+	for _, s := range sources {
+		for axis := 0; axis < 2; axis++ {
+			limits[axis] = [2]int{minInt(limits[axis][0], s.xy[axis]), maxInt(limits[axis][1], s.xy[axis])}
+		}
+	}
+
+	// lines along each axis, until boxes that are across them
+	var lines [2][]orthogonalLine
+	for _, s := range sources {
+		for axis := 0; axis < 2; axis++ {
+			line := orthogonalLine{v: s.xy[1-axis], lo: limits[axis][0], hi: limits[axis][1]}
+			inside := false
+			for i, b := range boxes {
+				if i == s.own {
+					continue
+				}
+				if lo, hi := b.bounds(1 - axis); line.v <= lo || line.v >= hi {
+					continue
+				}
+				switch lo, hi := b.bounds(axis); {
+				case hi <= s.xy[axis]:
+					line.lo = maxInt(line.lo, hi)
+				case lo >= s.xy[axis]:
+					line.hi = minInt(line.hi, lo)
+				default:
+					inside = true
+				}
+			}
+			if !inside {
+				lines[axis] = append(lines[axis], line)
+			}
+		}
+	}
+	for axis := range lines {
+		lines[axis] = mergeOrthogonalLines(lines[axis])
+	}
+
+	// vertices at intersections, in order along lines, since lines are sorted
+	horizontal, vertical := lines[0], lines[1]
+	for i := range horizontal {
+		h := &horizontal[i]
+		k := sort.Search(len(vertical), func(k int) bool { return vertical[k].v >= h.lo })
+		for ; k < len(vertical) && vertical[k].v <= h.hi; k++ {
+			v := &vertical[k]
+			if h.v < v.lo || h.v > v.hi {
+				continue
+			}
+			xy := [2]int{v.v, h.v}
+			id, ok := r.vertex[xy]
+			if !ok {
+				id = len(r.points)
+				r.vertex[xy] = id
+				r.points = append(r.points, xy)
+			}
+			h.vertices = append(h.vertices, id)
+			v.vertices = append(v.vertices, id)
+		}
+	}
+
+	r.next = make([][4]int, len(r.points))
+	r.blocked = make([][4]int, len(r.points))
+	for i := range r.next {
+		r.next[i] = [4]int{-1, -1, -1, -1}
+	}
+	index := newBoxIndex(boxes)
+	for axis, lines := range lines {
+		for _, line := range lines {
+			for j := 1; j < len(line.vertices); j++ {
+				a, b := line.vertices[j-1], line.vertices[j]
+				if a == b {
+					continue
+				}
+				p, q := r.points[a], r.points[b]
+				blocked := 0
+				mid := [2]int{(p[0] + q[0]) / 2, (p[1] + q[1]) / 2}
+				for _, i := range index.boxesAt(index.cell(mid[0]), index.cell(mid[1])) {
+					if boxes[i].containsSegment(p, q) {
+						blocked++
+					}
+				}
+				d := 2 * axis
+				r.next[a][d], r.next[b][d+1] = b, a
+				r.blocked[a][d], r.blocked[b][d+1] = blocked, blocked
+			}
+		}
+	}
+
+	r.bends = make([]int, 5*len(r.points))
+	r.bendsSeen = make([]int, 5*len(r.points))
+	r.best = make([]orthogonalCost, 5*len(r.points))
+	r.parent = make([]int, 5*len(r.points))
+	r.seen = make([]int, 5*len(r.points))
+	return r
+}
+
+// mergeOrthogonalLines joins lines at same coordinate that overlap or touch, lines are sorted by coordinate and start.
+func mergeOrthogonalLines(lines []orthogonalLine) []orthogonalLine {
+	sort.Slice(lines, func(i, j int) bool {
+		if lines[i].v != lines[j].v {
+			return lines[i].v < lines[j].v
+		}
+		return lines[i].lo < lines[j].lo
+	})
+	var merged []orthogonalLine
+	for _, line := range lines {
+		if last := len(merged) - 1; last >= 0 && merged[last].v == line.v && line.lo <= merged[last].hi {
+			merged[last].hi = maxInt(merged[last].hi, line.hi)
+			continue
+		}
+		merged = append(merged, line)
+	}
+	return merged
+}
+
+// orthogonalEnd is start or end of edge.
+type orthogonalEnd struct {
+	xy  [2]int
+	box box
+	dir int // direction of move away from node, any direction when negative
+}
+
+func newOrthogonalEnd(r *orthogonalRouter, g Graph, n uint64, port string) orthogonalEnd {
+	node := g.Nodes[n]
+	end := orthogonalEnd{xy: node.PortXY(port), box: r.boxes[n], dir: -1}
+	if p, ok := node.Ports[port]; ok {
+		end.dir = sideDirection(p.Side)
+	}
+	return end
+}
+
+// isAway is when move from p to q in direction d goes away from end straight out of node.
+func (o orthogonalEnd) isAway(p, q [2]int, d int) bool {
+	if o.dir >= 0 && d != o.dir {
+		return false
+	}
+	axis := 0
+	if d >= 2 {
+		axis = 1
+	}
+	if p[1-axis] != o.xy[1-axis] {
+		return false
+	}
+	return (q[axis]-o.xy[axis])*orthogonalMoves[d][axis] > 0 && (p[axis]-o.xy[axis])*orthogonalMoves[d][axis] >= 0
+}
+
+// orthogonalState is vertex of visibility graph and direction of last move, start has direction 4.
+type orthogonalState struct {
+	vertex, dir int
+}
+
+func (s orthogonalState) id() int { return 5*s.vertex + s.dir }
+
+// orthogonalCost is number of bends and length, compared in this order.
+type orthogonalCost [2]int
+
+func (c orthogonalCost) less(o orthogonalCost) bool {
+	if c[0] != o[0] {
+		return c[0] < o[0]
+	}
+	return c[1] < o[1]
+}
+
+type orthogonalItem struct {
+	state orthogonalState
+	cost  orthogonalCost // cost so far
+	f     orthogonalCost // cost so far and estimate of remaining cost
+}
+
+type orthogonalHeap struct {
+	items  []orthogonalItem
+	points [][2]int
+}
+
+func (h orthogonalHeap) Len() int { return len(h.items) }
+
+func (h orthogonalHeap) Less(i, j int) bool {
+	if h.items[i].f != h.items[j].f {
+		return h.items[i].f.less(h.items[j].f)
+	}
+	// closer to target first
+	if h.items[i].cost != h.items[j].cost {
+		return h.items[j].cost.less(h.items[i].cost)
+	}
+	a, b := h.items[i].state, h.items[j].state
+	if p, q := h.points[a.vertex], h.points[b.vertex]; p != q {
+		if p[0] != q[0] {
+			return p[0] < q[0]
+		}
+		return p[1] < q[1]
+	}
+	return a.dir < b.dir
+}
+
+func (h orthogonalHeap) Swap(i, j int) { h.items[i], h.items[j] = h.items[j], h.items[i] }
+
+func (h *orthogonalHeap) Push(x interface{}) { h.items = append(h.items, x.(orthogonalItem)) }
+
+func (h *orthogonalHeap) Pop() interface{} {
+	x := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	return x
+}
+
+// route finds path with fewest bends and then shortest length, using A* search.
+func (r *orthogonalRouter) route(g Graph, e EdgeID) [][2]int {
+	edge := g.Edges[e]
+	from := newOrthogonalEnd(r, g, e[0], edge.FromPort)
+	to := newOrthogonalEnd(r, g, e[1], edge.ToPort)
+	if from.xy == to.xy || e[0] == e[1] {
+		return [][2]int{from.xy, to.xy}
+	}
+	startVertex, okFrom := r.vertex[from.xy]
+	target, okTo := r.vertex[to.xy]
+	if !okFrom || !okTo {
+		return [][2]int{from.xy, {to.xy[0], from.xy[1]}, to.xy}
+	}
+
+	allowed := func(v int, d int) bool {
+		blocked := r.blocked[v][d]
+		if blocked == 0 {
+			return true
+		}
+		p, q := r.points[v], r.points[r.next[v][d]]
+		if from.box.containsSegment(p, q) {
+			if !from.isAway(p, q, d) {
+				return false
+			}
+			blocked--
+		}
+		if to.box.containsSegment(p, q) {
+			// reversed move goes away from end
+			if !to.isAway(q, p, d^1) {
+				return false
+			}
+			blocked--
+		}
+		return blocked == 0
+	}
+
+	// bends to target are exact near target and are lower bound farther, so that search goes towards target,
+	// and only through states on paths with fewest bends when start is near target
+	r.routes++
+	start := orthogonalState{vertex: startVertex, dir: 4}
+	fewest, complete, ok := r.bendsToTarget(start, from, to, target, allowed)
+	if !ok {
+		return [][2]int{from.xy, {to.xy[0], from.xy[1]}, to.xy}
+	}
+	estimate := func(s orthogonalState) orthogonalCost {
+		p := r.points[s.vertex]
+		dx, dy := to.xy[0]-p[0], to.xy[1]-p[1]
+		length := absInt(dx) + absInt(dy)
+		if s.dir == 4 {
+			return orthogonalCost{0, length}
+		}
+		if r.bendsSeen[s.id()] == r.routes {
+			return orthogonalCost{r.bends[s.id()], length}
+		}
+		bends := 2
+		if towards := orthogonalMoves[s.dir][0]*dx > 0 || orthogonalMoves[s.dir][1]*dy > 0; towards || (dx != 0 && dy != 0) {
+			bends = 1
+		}
+		return orthogonalCost{maxInt(bends, complete+1), length}
+	}
+
+	// states are reached in this route when seen has number of this route
+	reach := func(s orthogonalState, cost orthogonalCost, parent int) {
+		r.seen[s.id()] = r.routes
+		r.best[s.id()] = cost
+		r.parent[s.id()] = parent
+	}
+
+	reach(start, orthogonalCost{0, 0}, -1)
+	que := &orthogonalHeap{items: []orthogonalItem{{state: start, f: estimate(start)}}, points: r.points}
+
+	for que.Len() > 0 {
+		item := heap.Pop(que).(orthogonalItem)
+		s := item.state
+		if r.best[s.id()] != item.cost {
+			// stale entry
+			continue
+		}
+
+		if s.vertex == target && (to.dir < 0 || s.dir == to.dir^1) {
+			return r.path(s)
+		}
+
+		for d := range orthogonalMoves {
+			if s.dir < 4 && d == s.dir^1 {
+				continue
+			}
+			if s == start && from.dir >= 0 && d != from.dir {
+				continue
+			}
+			v := r.next[s.vertex][d]
+			if v < 0 || !allowed(s.vertex, d) {
+				continue
+			}
+			next := orthogonalState{vertex: v, dir: d}
+			p, q := r.points[s.vertex], r.points[v]
+
+			cost := orthogonalCost{item.cost[0], item.cost[1] + absInt(q[0]-p[0]) + absInt(q[1]-p[1])}
+			if s.dir < 4 && d != s.dir {
+				cost[0]++
+			}
+			h := estimate(next)
+			if fewest >= 0 && cost[0]+h[0] > fewest {
+				continue
+			}
+			if r.seen[next.id()] == r.routes && !cost.less(r.best[next.id()]) {
+				continue
+			}
+			reach(next, cost, s.id())
+			heap.Push(que, orthogonalItem{state: next, cost: cost, f: orthogonalCost{cost[0] + h[0], cost[1] + h[1]}})
+		}
+	}
+
+	// no path, going around nodes is not possible
+	return [][2]int{from.xy, {to.xy[0], from.xy[1]}, to.xy}
+}
+
+// orthogonalBendsAhead is number of bends from target, up to which bends to target are found before search.
+const orthogonalBendsAhead = 1
+
+// bendsToTarget finds fewest bends from states to target with breadth first search from target backwards,
+// where move in same direction is at same level and turn is at next level.
+// Search stops at level orthogonalBendsAhead, or after level at which first move from start is found.
+// Returns fewest bends from start or -1 when start is not reached, last complete level,
+// and false when target can not be reached at all.
+func (r *orthogonalRouter) bendsToTarget(start orthogonalState, from, to orthogonalEnd, target int, allowed func(v, d int) bool) (fewest, complete int, ok bool) {
+	// first moves from start
+	first := make(map[int]bool, 4)
+	for d := range orthogonalMoves {
+		if v := r.next[start.vertex][d]; v >= 0 && (from.dir < 0 || d == from.dir) && allowed(start.vertex, d) {
+			first[orthogonalState{vertex: v, dir: d}.id()] = true
+		}
+	}
+	if len(first) == 0 {
+		return -1, 0, false
+	}
+
+	var level, next []orthogonalState
+	reach := func(s orthogonalState, bends int) bool {
+		if r.bendsSeen[s.id()] == r.routes && r.bends[s.id()] <= bends {
+			return false
+		}
+		r.bendsSeen[s.id()] = r.routes
+		r.bends[s.id()] = bends
+		return true
+	}
+	for d := range orthogonalMoves {
+		if s := (orthogonalState{vertex: target, dir: d}); (to.dir < 0 || d == to.dir^1) && reach(s, 0) {
+			level = append(level, s)
+		}
+	}
+
+	for bends := 0; len(level) > 0; bends++ {
+		found := false
+		for i := 0; i < len(level); i++ {
+			s := level[i]
+			if r.bends[s.id()] != bends {
+				// reached again with fewer bends
+				continue
+			}
+			found = found || first[s.id()]
+
+			// states before this one, that moved to it
+			v := r.next[s.vertex][s.dir^1]
+			if v < 0 || !allowed(v, s.dir) {
+				continue
+			}
+			for d := range orthogonalMoves {
+				if d == s.dir^1 || r.next[v][d^1] < 0 {
+					continue
+				}
+				prev := orthogonalState{vertex: v, dir: d}
+				if d == s.dir {
+					if reach(prev, bends) {
+						level = append(level, prev)
+					}
+				} else if bends < orthogonalBendsAhead && reach(prev, bends+1) {
+					next = append(next, prev)
+				}
+			}
+		}
+		if found {
+			return bends, bends, true
+		}
+		if bends == orthogonalBendsAhead {
+			return -1, bends, true
+		}
+		level, next = next, level[:0]
+	}
+	return -1, 0, false
+}
+
+// path is start, bends and end of path that ends in state.
+func (r *orthogonalRouter) path(s orthogonalState) [][2]int {
+	reversed := [][2]int{r.points[s.vertex]}
+	for {
+		id := r.parent[s.id()]
+		if id < 0 {
+			break
+		}
+		p := orthogonalState{vertex: id / 5, dir: id % 5}
+		if p.dir == 4 || p.dir != s.dir {
+			reversed = append(reversed, r.points[p.vertex])
+		}
+		s = p
+	}
+	return reversePoints(reversed)
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func absInt(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
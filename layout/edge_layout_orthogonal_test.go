@@ -0,0 +1,200 @@
+package layout_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/nikolaydubina/go-graph-layout/layout"
+)
+
+// checkOrthogonalPaths checks that edges have only horizontal and vertical segments,
+// start and end at ports and do not go through nodes, except first and last segments through own ends.
+func checkOrthogonalPaths(t *testing.T, g layout.Graph) {
+	t.Helper()
+	for e, edge := range g.Edges {
+		path := edge.Path
+		if len(path) < 2 || path[0] != g.Nodes[e[0]].PortXY(edge.FromPort) || path[len(path)-1] != g.Nodes[e[1]].PortXY(edge.ToPort) {
+			t.Errorf("edge %v has path %v that does not connect ports", e, path)
+			continue
+		}
+		for i := 1; i < len(path); i++ {
+			a, b := path[i-1], path[i]
+			if a[0] != b[0] && a[1] != b[1] {
+				t.Errorf("edge %v has diagonal segment %v %v", e, a, b)
+			}
+			for n, node := range g.Nodes {
+				if (n == e[0] || n == e[1]) && (i == 1 || i == len(path)-1) {
+					continue
+				}
+				if isSegmentInside(a, b, node) {
+					t.Errorf("edge %v segment %v %v goes through node %d %v", e, a, b, n, node)
+				}
+			}
+		}
+	}
+}
+
+func isSegmentInside(a, b [2]int, node layout.Node) bool {
+	minX, maxX := a[0], b[0]
+	if minX > maxX {
+		minX, maxX = maxX, minX
+	}
+	minY, maxY := a[1], b[1]
+	if minY > maxY {
+		minY, maxY = maxY, minY
+	}
+	return minX < node.XY[0]+node.W && maxX > node.XY[0] && minY < node.XY[1]+node.H && maxY > node.XY[1]
+}
+
+func numBends(path [][2]int) int {
+	n := 0
+	for i := 1; i+1 < len(path); i++ {
+		if (path[i-1][0] == path[i][0]) != (path[i][0] == path[i+1][0]) {
+			n++
+		}
+	}
+	return n
+}
+
+func TestOrthogonalEdgesLayout(t *testing.T) {
+	t.Run("around node", func(t *testing.T) {
+		g := layout.Graph{
+			Nodes: map[uint64]layout.Node{
+				1: {XY: [2]int{0, 0}, W: 20, H: 20},
+				2: {XY: [2]int{100, 0}, W: 20, H: 20},
+				3: {XY: [2]int{200, 0}, W: 20, H: 20},
+			},
+			Edges: map[layout.EdgeID]layout.Edge{{1, 3}: {}},
+		}
+		layout.OrthogonalEdgesLayout{Margin: 10, Spacing: 5}.UpdateGraphLayout(g)
+
+		checkOrthogonalPaths(t, g)
+		if n := numBends(g.Edges[layout.EdgeID{1, 3}].Path); n != 2 {
+			t.Errorf("expected 2 bends, got %d in %v", n, g.Edges[layout.EdgeID{1, 3}].Path)
+		}
+	})
+
+	t.Run("ports", func(t *testing.T) {
+		g := layout.Graph{
+			Nodes: map[uint64]layout.Node{
+				1: {XY: [2]int{0, 0}, W: 40, H: 20, Ports: map[string]layout.Port{"out": {Side: layout.SideRight, Offset: 10}}},
+				2: {XY: [2]int{100, 100}, W: 40, H: 20, Ports: map[string]layout.Port{"in": {Side: layout.SideTop, Offset: 20}}},
+			},
+			Edges: map[layout.EdgeID]layout.Edge{{1, 2}: {FromPort: "out", ToPort: "in"}},
+		}
+		layout.OrthogonalEdgesLayout{Margin: 10, Spacing: 5}.UpdateGraphLayout(g)
+
+		checkOrthogonalPaths(t, g)
+		if path := g.Edges[layout.EdgeID{1, 2}].Path; len(path) != 3 || path[1] != [2]int{120, 10} {
+			t.Errorf("expected one bend at {120, 10}, got %v", path)
+		}
+	})
+
+	t.Run("nudged between close nodes", func(t *testing.T) {
+		g := layout.Graph{
+			Nodes: map[uint64]layout.Node{
+				1: {XY: [2]int{0, 100}, W: 40, H: 100},
+				2: {XY: [2]int{65, 100}, W: 40, H: 100},
+				3: {XY: [2]int{0, 0}, W: 20, H: 20},
+				4: {XY: [2]int{40, 0}, W: 20, H: 20},
+				5: {XY: [2]int{80, 0}, W: 20, H: 20},
+				6: {XY: [2]int{0, 300}, W: 20, H: 20},
+				7: {XY: [2]int{40, 300}, W: 20, H: 20},
+				8: {XY: [2]int{80, 300}, W: 20, H: 20},
+			},
+			Edges: map[layout.EdgeID]layout.Edge{{3, 8}: {}, {4, 7}: {}, {5, 6}: {}, {3, 7}: {}, {5, 7}: {}, {4, 6}: {}},
+		}
+		layout.OrthogonalEdgesLayout{Margin: 10, Spacing: 30}.UpdateGraphLayout(g)
+
+		checkOrthogonalPaths(t, g)
+		checkNudged(t, g)
+	})
+
+	t.Run("after other layout", func(t *testing.T) {
+		_, g, err := parseJSONLGraph(brandeskopfJSONL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		l := layout.SequenceLayout{
+			Layouts: []layout.Layout{
+				newTestSugiyamaLayout(),
+				layout.OrthogonalEdgesLayout{Margin: 10, Spacing: 5},
+			},
+		}
+		l.UpdateGraphLayout(*g)
+		checkOrthogonalPaths(t, *g)
+		checkNudged(t, *g)
+	})
+
+	t.Run("layered layout", func(t *testing.T) {
+		_, g, err := parseJSONLGraph(ginJSONL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		l := newTestSugiyamaLayout()
+		l.EdgePathAssigner = layout.OrthogonalEdgesLayout{Margin: 10, Spacing: 5}.AssignEdgePaths
+		if err := l.UpdateGraphLayoutE(*g); err != nil {
+			t.Fatal(err)
+		}
+		checkOrthogonalPaths(t, *g)
+		checkNudged(t, *g)
+	})
+}
+
+// checkNudged checks that segments of different edges that are not first or last do not overlap on same line.
+func checkNudged(t *testing.T, g layout.Graph) {
+	t.Helper()
+	type segment struct {
+		e    layout.EdgeID
+		a, b [2]int
+	}
+	var segments []segment
+	for e, edge := range g.Edges {
+		for i := 1; i+2 < len(edge.Path); i++ {
+			segments = append(segments, segment{e: e, a: edge.Path[i], b: edge.Path[i+1]})
+		}
+	}
+	for i, s := range segments {
+		for _, o := range segments[i+1:] {
+			if s.e == o.e {
+				continue
+			}
+			for axis := 0; axis < 2; axis++ {
+				if s.a[axis] != s.b[axis] || o.a[axis] != o.b[axis] || s.a[axis] != o.a[axis] {
+					continue
+				}
+				along := 1 - axis
+				lo := maxInt(minInt(s.a[along], s.b[along]), minInt(o.a[along], o.b[along]))
+				hi := minInt(maxInt(s.a[along], s.b[along]), maxInt(o.a[along], o.b[along]))
+				if lo < hi {
+					t.Errorf("edges %v and %v overlap at %v %v and %v %v", s.e, o.e, s.a, s.b, o.a, o.b)
+				}
+			}
+		}
+	}
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func BenchmarkOrthogonalEdgesLayout(b *testing.B) {
+	for _, n := range []int{50, 100, 200} {
+		b.Run(fmt.Sprintf("nodes(%d)", n), func(b *testing.B) {
+			g := newTestRandomGraph(n, n+n/2)
+			for i := 0; i < b.N; i++ {
+				layout.OrthogonalEdgesLayout{Margin: 10, Spacing: 5}.UpdateGraphLayout(g)
+			}
+		})
+	}
+}
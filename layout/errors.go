@@ -0,0 +1,63 @@
+package layout
+
+import "fmt"
+
+// MissingNodeError is when edge references node that is not in graph.
+type MissingNodeError struct {
+	Edge EdgeID
+	Node uint64
+}
+
+func (e MissingNodeError) Error() string {
+	return fmt.Sprintf("edge(%v) references missing node(%d)", e.Edge, e.Node)
+}
+
+// CycleError is when graph has cycle, for example when cycle remover did not remove all cycles.
+type CycleError struct {
+	Nodes []uint64 // nodes that are in cycles or reachable only through cycles
+}
+
+func (e CycleError) Error() string {
+	return fmt.Sprintf("graph has cycle through nodes(%v)", e.Nodes)
+}
+
+// LayerDirectionError is when segment in layered graph does not go from upper layer to lower layer.
+type LayerDirectionError struct {
+	Segment   [2]uint64
+	FromLayer int
+	ToLayer   int
+}
+
+func (e LayerDirectionError) Error() string {
+	return fmt.Sprintf("edge(%v) is wrong direction, got from level(%d) to level(%d)", e.Segment, e.FromLayer, e.ToLayer)
+}
+
+// InconsistentEdgeError is when edge in layered graph does not match edge in graph.
+type InconsistentEdgeError struct {
+	Edge   EdgeID
+	Reason string
+}
+
+func (e InconsistentEdgeError) Error() string {
+	return fmt.Sprintf("edge(%v) is inconsistent: %s", e.Edge, e.Reason)
+}
+
+// ClusterError is when cluster references missing nodes or clusters, or clusters are not nested properly.
+type ClusterError struct {
+	Cluster uint64
+	Reason  string
+}
+
+func (e ClusterError) Error() string {
+	return fmt.Sprintf("cluster(%d) is invalid: %s", e.Cluster, e.Reason)
+}
+
+// RankError is when rank constraint references missing nodes, or conflicts with other constraints or edges.
+type RankError struct {
+	Rank   int // index of constraint in Graph.Ranks
+	Reason string
+}
+
+func (e RankError) Error() string {
+	return fmt.Sprintf("rank(%d) is invalid: %s", e.Rank, e.Reason)
+}
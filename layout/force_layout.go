@@ -1,6 +1,10 @@
 package layout
 
-import "math"
+import (
+	"context"
+	"math"
+	"time"
+)
 
 // Force computes forces for Nodes.
 type Force interface {
@@ -13,10 +17,34 @@ type ForceGraphLayout struct {
 	MaxSteps int     // limit of iterations
 	Epsilon  float64 // minimal force
 	Forces   []Force
+	Observer Observer // receives energy after each step
+}
+
+// UpdateGraphLayoutContext stops simulation when context is done, keeping positions with lowest energy found so far.
+func (l ForceGraphLayout) UpdateGraphLayoutContext(ctx context.Context, g Graph) error {
+	if err := g.Validate(); err != nil {
+		return err
+	}
+	l.updateGraphLayout(ctx, g)
+	return nil
 }
 
 func (l ForceGraphLayout) UpdateGraphLayout(g Graph) {
-	for step := 0; step < l.MaxSteps; step++ {
+	l.updateGraphLayout(context.Background(), g)
+}
+
+// UpdateGraphLayoutE validates graph and updates its layout until completion.
+func (l ForceGraphLayout) UpdateGraphLayoutE(g Graph) error {
+	return l.UpdateGraphLayoutContext(context.Background(), g)
+}
+
+func (l ForceGraphLayout) updateGraphLayout(ctx context.Context, g Graph) {
+	// positions with lowest energy, that are restored when context is done
+	bestEnergy := math.Inf(1)
+	best := make(map[uint64][2]int, len(g.Nodes))
+
+	start := time.Now()
+	for step := 0; step < l.MaxSteps && ctx.Err() == nil; step++ {
 		f := make(map[uint64][2]float64, len(g.Nodes))
 
 		// accumulate all forces
@@ -25,11 +53,21 @@ func (l ForceGraphLayout) UpdateGraphLayout(g Graph) {
 		}
 
 		// delete tiny forces
-		for i := range g.Nodes {
+		energy := 0.0
+		for _, i := range g.nodeIDs() {
+			energy += f[i][0]*f[i][0] + f[i][1]*f[i][1]
 			if math.Hypot(f[i][0], f[i][1]) < l.Epsilon {
 				delete(f, i)
 			}
 		}
+		observe(l.Observer, Event{Phase: PhaseForceStep, Step: step, Energy: energy, Elapsed: time.Since(start)})
+
+		if energy < bestEnergy {
+			bestEnergy = energy
+			for n, node := range g.Nodes {
+				best[n] = node.XY
+			}
+		}
 
 		// early stop if no forces
 		if len(f) == 0 {
@@ -37,14 +75,18 @@ func (l ForceGraphLayout) UpdateGraphLayout(g Graph) {
 		}
 
 		// move by delta
-		for i := range g.Nodes {
-			x := g.Nodes[i].XY[0] + int((f[i][0] * l.Delta))
-			y := g.Nodes[i].XY[1] + int((f[i][1] * l.Delta))
-			g.Nodes[i] = Node{
-				XY: [2]int{x, y},
-				W:  g.Nodes[i].W,
-				H:  g.Nodes[i].H,
-			}
+		for _, i := range g.nodeIDs() {
+			node := g.Nodes[i]
+			node.XY = [2]int{node.XY[0] + int((f[i][0] * l.Delta)), node.XY[1] + int((f[i][1] * l.Delta))}
+			g.Nodes[i] = node
+		}
+	}
+
+	if ctx.Err() != nil {
+		for n, xy := range best {
+			node := g.Nodes[n]
+			node.XY = xy
+			g.Nodes[n] = node
 		}
 	}
 }
@@ -57,21 +99,16 @@ type SpringForce struct {
 }
 
 func (l SpringForce) UpdateForce(g Graph, f map[uint64][2]float64) {
-	for i := range g.Nodes {
-		var js []uint64
-
+	nodes := g.nodeIDs()
+	var targets map[uint64][]uint64
+	if l.EdgesOnly {
+		targets = edgeTargets(g)
+	}
+	for _, i := range nodes {
+		// node itself is skipped by distance
+		js := nodes
 		if l.EdgesOnly {
-			for e := range g.Edges {
-				if e[0] == i {
-					js = append(js, e[1])
-				}
-			}
-		} else {
-			for j := range g.Nodes {
-				if i != j {
-					js = append(js, j)
-				}
-			}
+			js = targets[i]
 		}
 
 		xi := float64(g.Nodes[i].XY[0])
@@ -103,38 +140,46 @@ type GravityForce struct {
 }
 
 func (l GravityForce) UpdateForce(g Graph, f map[uint64][2]float64) {
-	for i := range g.Nodes {
-		var js []uint64
+	nodes := g.nodeIDs()
+	var targets map[uint64][]uint64
+	if l.EdgesOnly {
+		targets = edgeTargets(g)
+	}
+	for _, i := range nodes {
+		// node itself is skipped by distance
+		js := nodes
 		if l.EdgesOnly {
-			for e := range g.Edges {
-				if e[0] == i {
-					js = append(js, e[1])
-				}
-			}
-		} else {
-			for j := range g.Nodes {
-				if i != j {
-					js = append(js, j)
-				}
-			}
+			js = targets[i]
 		}
 
 		xi := float64(g.Nodes[i].XY[0])
 		yi := float64(g.Nodes[i].XY[1])
 
 		for _, j := range js {
-			xj := float64(g.Nodes[j].XY[0])
-			yj := float64(g.Nodes[j].XY[1])
+			f[i] = gravity(xi, yi, float64(g.Nodes[j].XY[0]), float64(g.Nodes[j].XY[1]), l.K, f[i])
+		}
+	}
+}
 
-			d := math.Hypot(xi-xj, yi-yj)
+// gravity adds to f force of GravityForce on point i from point j, points closer than one do not act.
+func gravity(xi, yi, xj, yj, k float64, f [2]float64) [2]float64 {
+	d := math.Hypot(xi-xj, yi-yj)
+	if d <= 1 {
+		return f
+	}
+	af := k / d
+	return [2]float64{
+		f[0] + (af * (xj - xi) / d),
+		f[1] + (af * (yj - yi) / d),
+	}
+}
 
-			if d > 1 {
-				af := l.K / d
-				f[i] = [2]float64{
-					f[i][0] + (af * (xj - xi) / d),
-					f[i][1] + (af * (yj - yi) / d),
-				}
-			}
-		}
+// edgeTargets are targets of edges of each node, in order of edges.
+// time: O(E log E)
+func edgeTargets(g Graph) map[uint64][]uint64 {
+	targets := make(map[uint64][]uint64, len(g.Nodes))
+	for _, e := range g.edgeIDs() {
+		targets[e[0]] = append(targets[e[0]], e[1])
 	}
+	return targets
 }
@@ -0,0 +1,115 @@
+package layout
+
+import "math"
+
+// BarnesHutForce is GravityForce between all nodes that is approximated by Barnes-Hut quadtree.
+// Far away groups of nodes act as single node in their center of mass.
+// time: O(N log N) per step, instead of O(N^2) for GravityForce
+type BarnesHutForce struct {
+	K     float64 // positive K for attraction, same as in GravityForce
+	Theta float64 // group is far away when its size is less than Theta times distance to it, zero is exact, usually 0.5 to 1
+}
+
+func (l BarnesHutForce) UpdateForce(g Graph, f map[uint64][2]float64) {
+	nodes := g.nodeIDs()
+	if len(nodes) == 0 {
+		return
+	}
+	pos := make(map[uint64][2]float64, len(nodes))
+	for _, i := range nodes {
+		pos[i] = [2]float64{float64(g.Nodes[i].XY[0]), float64(g.Nodes[i].XY[1])}
+	}
+	t := newQuadTree(pos, nodes)
+
+	for _, i := range nodes {
+		f[i] = t.force(pos, 0, i, l.K, l.Theta, f[i])
+	}
+}
+
+// quadCell is square of quadtree with number of nodes in it and their center of mass.
+type quadCell struct {
+	size     float64
+	mass     float64
+	center   [2]float64
+	children []int    // indexes of non empty quarters, empty for leaves
+	nodes    []uint64 // nodes of leaf
+}
+
+// quadTree is cells of quadtree, first cell is root.
+type quadTree []quadCell
+
+// newQuadTree makes quadtree of nodes, cells are split until they have single node or are smaller than one or not finite.
+func newQuadTree(pos map[uint64][2]float64, nodes []uint64) quadTree {
+	minXY, maxXY := pos[nodes[0]], pos[nodes[0]]
+	for _, n := range nodes {
+		for k := 0; k < 2; k++ {
+			minXY[k] = math.Min(minXY[k], pos[n][k])
+			maxXY[k] = math.Max(maxXY[k], pos[n][k])
+		}
+	}
+	size := math.Max(maxXY[0]-minXY[0], maxXY[1]-minXY[1]) + 1
+
+	var t quadTree
+	t.add(pos, nodes, minXY, size)
+	return t
+}
+
+// add adds cell for nodes in square and its children, returns index of cell.
+func (t *quadTree) add(pos map[uint64][2]float64, nodes []uint64, xy [2]float64, size float64) int {
+	c := quadCell{size: size, mass: float64(len(nodes))}
+	for _, n := range nodes {
+		c.center[0] += pos[n][0] / c.mass
+		c.center[1] += pos[n][1] / c.mass
+	}
+	idx := len(*t)
+	*t = append(*t, c)
+
+	if len(nodes) == 1 || !(size >= 1 && size < math.Inf(1)) {
+		(*t)[idx].nodes = nodes
+		return idx
+	}
+
+	half := size / 2
+	var quarters [4][]uint64
+	for _, n := range nodes {
+		q := 0
+		if pos[n][0] >= xy[0]+half {
+			q++
+		}
+		if pos[n][1] >= xy[1]+half {
+			q += 2
+		}
+		quarters[q] = append(quarters[q], n)
+	}
+	for q, qnodes := range quarters {
+		if len(qnodes) == 0 {
+			continue
+		}
+		qxy := [2]float64{xy[0] + half*float64(q%2), xy[1] + half*float64(q/2)}
+		child := t.add(pos, qnodes, qxy, half)
+		(*t)[idx].children = append((*t)[idx].children, child)
+	}
+	return idx
+}
+
+// force adds to f force on node i from nodes in cell, same as in GravityForce.
+func (t quadTree) force(pos map[uint64][2]float64, cell int, i uint64, k, theta float64, f [2]float64) [2]float64 {
+	c := t[cell]
+	xi, yi := pos[i][0], pos[i][1]
+
+	if len(c.children) == 0 {
+		for _, j := range c.nodes {
+			f = gravity(xi, yi, pos[j][0], pos[j][1], k, f)
+		}
+		return f
+	}
+
+	if d := math.Hypot(c.center[0]-xi, c.center[1]-yi); c.size < theta*d {
+		return gravity(xi, yi, c.center[0], c.center[1], k*c.mass, f)
+	}
+
+	for _, child := range c.children {
+		f = t.force(pos, child, i, k, theta, f)
+	}
+	return f
+}
@@ -0,0 +1,93 @@
+package layout_test
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/nikolaydubina/go-graph-layout/layout"
+)
+
+func newTestRandomGraph(numNodes, numEdges int) layout.Graph {
+	r := rand.New(rand.NewSource(1))
+	g := layout.Graph{
+		Nodes: make(map[uint64]layout.Node, numNodes),
+		Edges: make(map[layout.EdgeID]layout.Edge, numEdges),
+	}
+	for i := 0; i < numNodes; i++ {
+		g.Nodes[uint64(i)] = layout.Node{XY: [2]int{r.Intn(10 * numNodes), r.Intn(10 * numNodes)}, W: 10, H: 10}
+	}
+	for i := 0; i < numEdges; i++ {
+		g.Edges[layout.EdgeID{uint64(r.Intn(numNodes)), uint64(r.Intn(numNodes))}] = layout.Edge{}
+	}
+	return g
+}
+
+func TestBarnesHutForce(t *testing.T) {
+	g := newTestRandomGraph(300, 0)
+	// coincident nodes
+	g.Nodes[300], g.Nodes[301] = g.Nodes[0], g.Nodes[0]
+
+	exact := map[uint64][2]float64{}
+	layout.GravityForce{K: -50}.UpdateForce(g, exact)
+
+	for _, tc := range []struct {
+		theta  float64
+		maxErr float64 // relative to total magnitude of forces
+	}{
+		{theta: 0, maxErr: 1e-9},
+		{theta: 0.5, maxErr: 0.05},
+	} {
+		f := map[uint64][2]float64{}
+		layout.BarnesHutForce{K: -50, Theta: tc.theta}.UpdateForce(g, f)
+		errSum, sum := 0.0, 0.0
+		for n, v := range exact {
+			errSum += math.Hypot(f[n][0]-v[0], f[n][1]-v[1])
+			sum += math.Hypot(v[0], v[1])
+		}
+		if errSum > tc.maxErr*sum {
+			t.Errorf("theta(%v): expected error below %v, got %v", tc.theta, tc.maxErr, errSum/sum)
+		}
+	}
+}
+
+func newTestLargeGraphForceLayout() layout.ForceGraphLayout {
+	return layout.ForceGraphLayout{
+		Delta:   1,
+		Epsilon: 1.5,
+		Forces: []layout.Force{
+			layout.BarnesHutForce{K: -50, Theta: 1},
+			layout.SpringForce{K: 0.2, L: 200, EdgesOnly: true},
+			layout.GravityForce{K: 1, EdgesOnly: true},
+		},
+	}
+}
+
+func TestForceGraphLayoutLargeGraphConverges(t *testing.T) {
+	g := newTestRandomGraph(500, 750)
+	var energy []float64
+	l := newTestLargeGraphForceLayout()
+	l.MaxSteps = 100
+	l.Observer = layout.ObserverFunc(func(e layout.Event) { energy = append(energy, e.Energy) })
+	l.UpdateGraphLayout(g)
+
+	if len(energy) != l.MaxSteps {
+		t.Fatalf("expected %d steps, got %d", l.MaxSteps, len(energy))
+	}
+	for i := 1; i < len(energy); i++ {
+		if energy[i] > energy[i-1] {
+			t.Errorf("energy increased at step %d from %f to %f", i, energy[i-1], energy[i])
+		}
+	}
+	if last := energy[len(energy)-1]; last > energy[0]/1e4 {
+		t.Errorf("expected energy to decrease from %f by 1e4 times, got %f", energy[0], last)
+	}
+}
+
+func BenchmarkForceGraphLayoutLargeGraph(b *testing.B) {
+	l := newTestLargeGraphForceLayout()
+	l.MaxSteps = 20
+	for i := 0; i < b.N; i++ {
+		l.UpdateGraphLayout(newTestRandomGraph(10000, 15000))
+	}
+}
@@ -0,0 +1,169 @@
+package layout
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// CoolingSchedule is how maximum displacement of nodes decreases with steps.
+type CoolingSchedule uint8
+
+const (
+	CoolingLinear      CoolingSchedule = iota // decreases to zero at last step
+	CoolingExponential                        // multiplied by rate each step
+	CoolingAdaptive                           // multiplied by rate when energy grows, divided by rate after five steps of decreasing energy, as in Hu
+)
+
+// defaultEdgeLength is natural edge length when nodes do not have sizes.
+const defaultEdgeLength = 100
+
+// FruchtermanReingoldLayout moves nodes by attraction of connected nodes and repulsion of all nodes,
+// with displacement of nodes limited by temperature that cools down with steps.
+// Nodes are moved by their centers, nodes in same position are spread around it first.
+type FruchtermanReingoldLayout struct {
+	EdgeLength  float64         // natural distance between centers of connected nodes, zero is twice average diagonal of nodes
+	Cooling     CoolingSchedule // how temperature changes
+	Temperature float64         // maximum displacement of node at first step, zero is edge length
+	CoolingRate float64         // for exponential and adaptive cooling, zero is 0.9
+	MaxSteps    int             // limit of iterations
+	Epsilon     float64         // stop when all nodes move less than this
+	Theta       float64         // approximation of repulsion as in BarnesHutForce, zero is exact
+	Observer    Observer        // receives energy after each step
+}
+
+// UpdateGraphLayoutContext stops simulation when context is done, keeping current positions.
+func (l FruchtermanReingoldLayout) UpdateGraphLayoutContext(ctx context.Context, g Graph) error {
+	if err := g.Validate(); err != nil {
+		return err
+	}
+	l.updateGraphLayout(ctx, g)
+	return nil
+}
+
+func (l FruchtermanReingoldLayout) UpdateGraphLayout(g Graph) {
+	l.updateGraphLayout(context.Background(), g)
+}
+
+// UpdateGraphLayoutE validates graph and updates its layout until completion.
+func (l FruchtermanReingoldLayout) UpdateGraphLayoutE(g Graph) error {
+	return l.UpdateGraphLayoutContext(context.Background(), g)
+}
+
+func (l FruchtermanReingoldLayout) updateGraphLayout(ctx context.Context, g Graph) {
+	nodes := g.nodeIDs()
+	if len(nodes) == 0 {
+		return
+	}
+	k := l.EdgeLength
+	if k <= 0 {
+		k = naturalEdgeLength(g)
+	}
+	t0 := l.Temperature
+	if t0 <= 0 {
+		t0 = k
+	}
+	rate := l.CoolingRate
+	if rate <= 0 || rate >= 1 {
+		rate = 0.9
+	}
+	targets := edgeTargets(g)
+	pos := spreadCenters(g, nodes, k)
+
+	start := time.Now()
+	t, energy0, progress := t0, math.Inf(1), 0
+	for step := 0; step < l.MaxSteps && ctx.Err() == nil; step++ {
+		// repulsion k^2/d is same as gravity
+		tree := newQuadTree(pos, nodes)
+		f := make(map[uint64][2]float64, len(nodes))
+		for _, i := range nodes {
+			f[i] = tree.force(pos, 0, i, -k*k, l.Theta, f[i])
+		}
+
+		// attraction d^2/k on both ends of edges
+		for _, i := range nodes {
+			for _, j := range targets[i] {
+				dx, dy := pos[j][0]-pos[i][0], pos[j][1]-pos[i][1]
+				d := math.Hypot(dx, dy)
+				if i == j || d == 0 {
+					continue
+				}
+				af := d / k
+				f[i] = [2]float64{f[i][0] + af*dx, f[i][1] + af*dy}
+				f[j] = [2]float64{f[j][0] - af*dx, f[j][1] - af*dy}
+			}
+		}
+
+		// move by force, but not further than temperature
+		energy, maxMove := 0.0, 0.0
+		for _, i := range nodes {
+			d := math.Hypot(f[i][0], f[i][1])
+			energy += d * d
+			if d == 0 {
+				continue
+			}
+			move := math.Min(d, t)
+			maxMove = math.Max(maxMove, move)
+			pos[i] = [2]float64{pos[i][0] + f[i][0]/d*move, pos[i][1] + f[i][1]/d*move}
+		}
+		observe(l.Observer, Event{Phase: PhaseForceStep, Step: step, Energy: energy, Elapsed: time.Since(start)})
+
+		if maxMove < l.Epsilon {
+			break
+		}
+
+		switch l.Cooling {
+		case CoolingLinear:
+			t = t0 * float64(l.MaxSteps-step-1) / float64(l.MaxSteps)
+		case CoolingExponential:
+			t *= rate
+		case CoolingAdaptive:
+			if energy < energy0 {
+				progress++
+				if progress >= 5 {
+					progress = 0
+					t /= rate
+				}
+			} else {
+				progress = 0
+				t *= rate
+			}
+		}
+		energy0 = energy
+	}
+
+	for _, i := range nodes {
+		node := g.Nodes[i]
+		node.XY = [2]int{int(math.Round(pos[i][0])) - node.W/2, int(math.Round(pos[i][1])) - node.H/2}
+		g.Nodes[i] = node
+	}
+}
+
+// naturalEdgeLength is twice average diagonal of nodes, so that connected nodes have space between them.
+func naturalEdgeLength(g Graph) float64 {
+	sum := 0.0
+	for _, i := range g.nodeIDs() {
+		sum += math.Hypot(float64(g.Nodes[i].W), float64(g.Nodes[i].H))
+	}
+	if sum == 0 {
+		return defaultEdgeLength
+	}
+	return 2 * sum / float64(len(g.Nodes))
+}
+
+// spreadCenters are centers of nodes, where nodes in same position as previous nodes are moved around it
+// by sunflower spiral with step of given distance.
+func spreadCenters(g Graph, nodes []uint64, step float64) map[uint64][2]float64 {
+	goldenAngle := math.Pi * (3 - math.Sqrt(5))
+
+	pos := make(map[uint64][2]float64, len(nodes))
+	count := make(map[[2]int]int, len(nodes))
+	for _, i := range nodes {
+		c := g.Nodes[i].CenterXY()
+		n := count[c]
+		count[c]++
+		r, a := step*math.Sqrt(float64(n)), goldenAngle*float64(n)
+		pos[i] = [2]float64{float64(c[0]) + r*math.Cos(a), float64(c[1]) + r*math.Sin(a)}
+	}
+	return pos
+}
@@ -0,0 +1,77 @@
+package layout_test
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/nikolaydubina/go-graph-layout/layout"
+)
+
+func TestFruchtermanReingoldLayout(t *testing.T) {
+	// ring of nodes, all in same position
+	newRing := func() layout.Graph {
+		g := layout.Graph{Nodes: map[uint64]layout.Node{}, Edges: map[layout.EdgeID]layout.Edge{}}
+		for i := uint64(0); i < 12; i++ {
+			g.Nodes[i] = layout.Node{W: 30, H: 40}
+			g.Edges[layout.EdgeID{i, (i + 1) % 12}] = layout.Edge{}
+		}
+		return g
+	}
+
+	for _, cooling := range []layout.CoolingSchedule{layout.CoolingLinear, layout.CoolingExponential, layout.CoolingAdaptive} {
+		t.Run(fmt.Sprintf("cooling(%d)", cooling), func(t *testing.T) {
+			g := newRing()
+			steps, maxEnergy := 0, 0.0
+			l := layout.FruchtermanReingoldLayout{
+				Cooling:  cooling,
+				MaxSteps: 1000,
+				Epsilon:  0.1,
+				Observer: layout.ObserverFunc(func(e layout.Event) {
+					steps++
+					maxEnergy = math.Max(maxEnergy, e.Energy)
+				}),
+			}
+			l.UpdateGraphLayout(g)
+
+			// linear cooling stops moving nodes only at last step
+			if cooling != layout.CoolingLinear && steps >= l.MaxSteps {
+				t.Errorf("expected to converge before max steps")
+			}
+
+			// natural edge length is twice diagonal of nodes, which is 100
+			for e := range g.Edges {
+				a, b := g.Nodes[e[0]].CenterXY(), g.Nodes[e[1]].CenterXY()
+				if d := math.Hypot(float64(a[0]-b[0]), float64(a[1]-b[1])); d < 50 || d > 200 {
+					t.Errorf("edge %v has length %f", e, d)
+				}
+			}
+		})
+	}
+
+	t.Run("deterministic", func(t *testing.T) {
+		a, b := newRing(), newRing()
+		l := layout.FruchtermanReingoldLayout{Cooling: layout.CoolingAdaptive, MaxSteps: 100, Theta: 0.5}
+		l.UpdateGraphLayout(a)
+		l.UpdateGraphLayout(b)
+		for n := range a.Nodes {
+			if a.Nodes[n].XY != b.Nodes[n].XY {
+				t.Errorf("node %d: %v != %v", n, a.Nodes[n].XY, b.Nodes[n].XY)
+			}
+		}
+	})
+
+	t.Run("displacement is limited by temperature", func(t *testing.T) {
+		g := newRing()
+		l := layout.FruchtermanReingoldLayout{EdgeLength: 100, Temperature: 1, Cooling: layout.CoolingExponential, MaxSteps: 1}
+		l.UpdateGraphLayout(g)
+		// spread on sunflower spiral around center, then moved by at most temperature, rounded
+		for n, node := range g.Nodes {
+			r, a := 100*math.Sqrt(float64(n)), math.Pi*(3-math.Sqrt(5))*float64(n)
+			c := node.CenterXY()
+			if d := math.Hypot(float64(c[0])-15-r*math.Cos(a), float64(c[1])-20-r*math.Sin(a)); d > 2 {
+				t.Errorf("node %d moved too far to %v", n, c)
+			}
+		}
+	})
+}
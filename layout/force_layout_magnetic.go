@@ -0,0 +1,80 @@
+package layout
+
+import "math"
+
+// MagneticField is shape of field of MagneticForce.
+type MagneticField uint8
+
+const (
+	MagneticParallel   MagneticField = iota // same direction everywhere
+	MagneticRadial                          // away from center
+	MagneticConcentric                      // along circles around center, clockwise on screen
+)
+
+// MagneticForce rotates directed edges to direction of magnetic field, as in magnetic-spring model of Sugiyama and Misue.
+// Ends of edge are pushed perpendicular to edge in opposite directions with force K * length^Alpha * angle^Beta,
+// where angle is between edge and field in middle of edge.
+// Used with SpringForce and GravityForce to make force layouts of directed graphs that point in one direction.
+type MagneticForce struct {
+	K         float64       // strength, has to be positive
+	Field     MagneticField // shape of field
+	Direction [2]float64    // direction of parallel field, zero is down {0, 1}
+	Center    [2]float64    // center of radial and concentric fields
+	Alpha     float64       // power of length of edge, zero is same as one
+	Beta      float64       // power of angle between edge and field, zero is same as one
+}
+
+func (l MagneticForce) UpdateForce(g Graph, f map[uint64][2]float64) {
+	alpha, beta := l.Alpha, l.Beta
+	if alpha == 0 {
+		alpha = 1
+	}
+	if beta == 0 {
+		beta = 1
+	}
+
+	for _, e := range g.edgeIDs() {
+		if e.isSelfLoop() {
+			continue
+		}
+		from, to := g.Nodes[e[0]].XY, g.Nodes[e[1]].XY
+		ex, ey := float64(to[0]-from[0]), float64(to[1]-from[1])
+		d := math.Hypot(ex, ey)
+		if d <= 1 {
+			continue
+		}
+
+		m := l.direction([2]float64{float64(from[0]+to[0]) / 2, float64(from[1]+to[1]) / 2})
+		if m == [2]float64{0, 0} {
+			continue
+		}
+
+		// signed angle from edge to field, edge is rotated towards field
+		angle := math.Atan2(ex*m[1]-ey*m[0], ex*m[0]+ey*m[1])
+		mf := l.K * math.Pow(d, alpha) * math.Pow(math.Abs(angle), beta)
+		if angle < 0 {
+			mf = -mf
+		}
+
+		// perpendicular to edge, in direction of rotation for target
+		px, py := -ey/d*mf, ex/d*mf
+		f[e[1]] = [2]float64{f[e[1]][0] + px, f[e[1]][1] + py}
+		f[e[0]] = [2]float64{f[e[0]][0] - px, f[e[0]][1] - py}
+	}
+}
+
+// direction is direction of field at point, zero when field has no direction at point.
+func (l MagneticForce) direction(p [2]float64) [2]float64 {
+	switch l.Field {
+	case MagneticRadial:
+		return [2]float64{p[0] - l.Center[0], p[1] - l.Center[1]}
+	case MagneticConcentric:
+		// on screen y axis is down, so rotation by +90 degrees is clockwise
+		return [2]float64{-(p[1] - l.Center[1]), p[0] - l.Center[0]}
+	default:
+		if l.Direction == [2]float64{0, 0} {
+			return [2]float64{0, 1}
+		}
+		return l.Direction
+	}
+}
@@ -0,0 +1,77 @@
+package layout_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/nikolaydubina/go-graph-layout/layout"
+)
+
+func TestMagneticForce(t *testing.T) {
+	t.Run("direction of force", func(t *testing.T) {
+		// edge goes right, from {100, 0} to {200, 0}
+		g := layout.Graph{
+			Nodes: map[uint64]layout.Node{1: {XY: [2]int{100, 0}}, 2: {XY: [2]int{200, 0}}},
+			Edges: map[layout.EdgeID]layout.Edge{{1, 2}: {}},
+		}
+		tests := []struct {
+			force layout.MagneticForce
+			down  bool // target is pushed down and source is pushed up
+		}{
+			{force: layout.MagneticForce{K: 1}, down: true},
+			{force: layout.MagneticForce{K: 1, Direction: [2]float64{1, -1}}, down: false},
+			{force: layout.MagneticForce{K: 1, Field: layout.MagneticRadial, Center: [2]float64{150, 100}}, down: false},
+			{force: layout.MagneticForce{K: 1, Field: layout.MagneticConcentric}, down: true},
+			{force: layout.MagneticForce{K: 1, Field: layout.MagneticConcentric, Center: [2]float64{200, 100}}, down: false},
+		}
+		for _, tc := range tests {
+			f := map[uint64][2]float64{}
+			tc.force.UpdateForce(g, f)
+			if (f[2][1] > 0) != tc.down || (f[1][1] < 0) != tc.down || f[1][1] != -f[2][1] {
+				t.Errorf("%+v: expected down(%v), got %v", tc.force, tc.down, f)
+			}
+		}
+	})
+
+	t.Run("aligned edge", func(t *testing.T) {
+		g := layout.Graph{
+			Nodes: map[uint64]layout.Node{1: {XY: [2]int{0, 0}}, 2: {XY: [2]int{0, 100}}},
+			Edges: map[layout.EdgeID]layout.Edge{{1, 2}: {}},
+		}
+		f := map[uint64][2]float64{}
+		layout.MagneticForce{K: 1}.UpdateForce(g, f)
+		if f[1] != [2]float64{} || f[2] != [2]float64{} {
+			t.Errorf("expected no force, got %v", f)
+		}
+	})
+
+	t.Run("layout", func(t *testing.T) {
+		// edges point up and sideways
+		g := layout.Graph{
+			Nodes: map[uint64]layout.Node{
+				1: {XY: [2]int{0, 300}},
+				2: {XY: [2]int{10, 200}},
+				3: {XY: [2]int{-200, 100}},
+				4: {XY: [2]int{200, 150}},
+			},
+			Edges: map[layout.EdgeID]layout.Edge{{1, 2}: {}, {2, 3}: {}, {2, 4}: {}},
+		}
+		l := layout.ForceGraphLayout{
+			Delta:    1,
+			MaxSteps: 2000,
+			Epsilon:  1.5,
+			Forces: []layout.Force{
+				layout.GravityForce{K: -500},
+				layout.SpringForce{K: 0.05, L: 100, EdgesOnly: true},
+				layout.MagneticForce{K: 0.2},
+			},
+		}
+		l.UpdateGraphLayout(g)
+
+		for e := range g.Edges {
+			if from, to := g.Nodes[e[0]].XY, g.Nodes[e[1]].XY; from[1] >= to[1] {
+				t.Errorf("edge %v does not go down: %v", e, fmt.Sprint(from, to))
+			}
+		}
+	})
+}
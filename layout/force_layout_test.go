@@ -0,0 +1,99 @@
+package layout_test
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/nikolaydubina/go-graph-layout/layout"
+)
+
+func TestForceGraphLayoutContext(t *testing.T) {
+	g := layout.Graph{
+		Nodes: map[uint64]layout.Node{},
+		Edges: map[layout.EdgeID]layout.Edge{},
+	}
+	for i := uint64(0); i < 100; i++ {
+		g.Nodes[i] = layout.Node{XY: [2]int{int(i%10) * 10, int(i/10) * 10}}
+		if i > 0 {
+			g.Edges[layout.EdgeID{i - 1, i}] = layout.Edge{}
+		}
+	}
+
+	l := layout.ForceGraphLayout{
+		Delta:    1,
+		MaxSteps: 1000000000,
+		Forces: []layout.Force{
+			layout.GravityForce{K: -50},
+			layout.SpringForce{K: 0.2, L: 200, EdgesOnly: true},
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	done := make(chan error)
+	go func() { done <- l.UpdateGraphLayoutContext(ctx, g) }()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("layout did not stop after context deadline")
+	}
+}
+
+func TestForceGraphLayoutContextKeepsLowestEnergy(t *testing.T) {
+	g := layout.Graph{
+		Nodes: map[uint64]layout.Node{},
+		Edges: map[layout.EdgeID]layout.Edge{},
+	}
+	for i := uint64(0); i < 20; i++ {
+		g.Nodes[i] = layout.Node{XY: [2]int{int(i%5) * 10, int(i/5) * 10}}
+		if i > 0 {
+			g.Edges[layout.EdgeID{i - 1, i}] = layout.Edge{}
+		}
+	}
+	forces := []layout.Force{
+		layout.GravityForce{K: -50},
+		layout.SpringForce{K: 0.2, L: 200, EdgesOnly: true},
+	}
+	energy := func() float64 {
+		f := map[uint64][2]float64{}
+		for _, force := range forces {
+			force.UpdateForce(g, f)
+		}
+		e := 0.0
+		for _, v := range f {
+			e += v[0]*v[0] + v[1]*v[1]
+		}
+		return e
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// large steps make energy go up and down
+	minEnergy := math.Inf(1)
+	l := layout.ForceGraphLayout{
+		Delta:    3,
+		MaxSteps: 1000,
+		Forces:   forces,
+		Observer: layout.ObserverFunc(func(e layout.Event) {
+			minEnergy = math.Min(minEnergy, e.Energy)
+			if e.Step == 50 {
+				cancel()
+			}
+		}),
+	}
+	if err := l.UpdateGraphLayoutContext(ctx, g); err != nil {
+		t.Fatal(err)
+	}
+
+	if e := energy(); math.Abs(e-minEnergy) > 1e-9*minEnergy {
+		t.Errorf("expected lowest energy %f, got %f", minEnergy, e)
+	}
+}
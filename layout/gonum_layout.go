@@ -1,11 +1,17 @@
 package layout
 
 import (
-	"log"
+	"context"
 	"math"
+	"sort"
+	"time"
 
+	"golang.org/x/exp/rand"
+	gngraph "gonum.org/v1/gonum/graph"
+	gniterator "gonum.org/v1/gonum/graph/iterator"
 	gnlayout "gonum.org/v1/gonum/graph/layout"
 	gnsimple "gonum.org/v1/gonum/graph/simple"
+	gnbarneshut "gonum.org/v1/gonum/spatial/barneshut"
 	gnr2 "gonum.org/v1/gonum/spatial/r2"
 )
 
@@ -13,18 +19,90 @@ func gonumNodeID(id uint64) int64 {
 	return int64(float64(id))
 }
 
-func toGonumGraph(g Graph) *gnsimple.UndirectedGraph {
-	gn := gnsimple.NewUndirectedGraph()
-	for e := range g.Edges {
-		gn.SetEdge(gn.NewEdge(gnsimple.Node(gonumNodeID(e[0])), gnsimple.Node(gonumNodeID(e[1]))))
+// gonumGraph is undirected gonum graph that iterates nodes in order of ids.
+// This makes gonum layouts deterministic, unlike gonum graphs backed by maps.
+type gonumGraph struct {
+	nodes     []gngraph.Node
+	neighbors map[int64][]gngraph.Node
+}
+
+func toGonumGraph(g Graph) gonumGraph {
+	gn := gonumGraph{neighbors: map[int64][]gngraph.Node{}}
+	for _, e := range g.edgeIDs() {
+		u, v := gonumNodeID(e[0]), gonumNodeID(e[1])
+		if u == v || gn.HasEdgeBetween(u, v) {
+			continue
+		}
+		for _, id := range []int64{u, v} {
+			if _, ok := gn.neighbors[id]; !ok {
+				gn.nodes = append(gn.nodes, gnsimple.Node(id))
+				gn.neighbors[id] = nil
+			}
+		}
+		gn.neighbors[u] = append(gn.neighbors[u], gnsimple.Node(v))
+		gn.neighbors[v] = append(gn.neighbors[v], gnsimple.Node(u))
 	}
+	sort.Slice(gn.nodes, func(i, j int) bool { return gn.nodes[i].ID() < gn.nodes[j].ID() })
 	return gn
 }
 
+func (g gonumGraph) Node(id int64) gngraph.Node {
+	if _, ok := g.neighbors[id]; !ok {
+		return nil
+	}
+	return gnsimple.Node(id)
+}
+
+func (g gonumGraph) Nodes() gngraph.Nodes { return gniterator.NewOrderedNodes(g.nodes) }
+
+func (g gonumGraph) From(id int64) gngraph.Nodes {
+	return gniterator.NewOrderedNodes(g.neighbors[id])
+}
+
+func (g gonumGraph) HasEdgeBetween(xid, yid int64) bool {
+	for _, n := range g.neighbors[xid] {
+		if n.ID() == yid {
+			return true
+		}
+	}
+	return false
+}
+
+func (g gonumGraph) Edge(uid, vid int64) gngraph.Edge { return g.EdgeBetween(uid, vid) }
+
+func (g gonumGraph) EdgeBetween(xid, yid int64) gngraph.Edge {
+	if !g.HasEdgeBetween(xid, yid) {
+		return nil
+	}
+	return gnsimple.Edge{F: gnsimple.Node(xid), T: gnsimple.Node(yid)}
+}
+
 type gnLayoutGetter interface {
 	Coord2(id int64) gnr2.Vec
 }
 
+// gonumCoords is copy of positions of nodes of gonum layout.
+type gonumCoords map[int64]gnr2.Vec
+
+func newGonumCoords(gn gonumGraph, gnLayout gnLayoutGetter) gonumCoords {
+	coords := make(gonumCoords, len(gn.nodes))
+	for _, n := range gn.nodes {
+		coords[n.ID()] = gnLayout.Coord2(n.ID())
+	}
+	return coords
+}
+
+func (c gonumCoords) Coord2(id int64) gnr2.Vec { return c[id] }
+
+// gonumParticle is node of gonum layout with unit mass.
+type gonumParticle struct {
+	id  int64
+	pos gnr2.Vec
+}
+
+func (p gonumParticle) Coord2() gnr2.Vec { return p.pos }
+func (p gonumParticle) Mass() float64    { return 1 }
+
 // will make dimension such that all nodes data fits into square of the same area
 // this is for pretty layouts.
 func getSquareLayoutSize(g Graph) float64 {
@@ -50,7 +128,6 @@ func updateGraphByGonumLayout(g Graph, gnLayout gnLayoutGetter, scaleX float64,
 	// get width and height of our expected layout
 	w := getSquareLayoutSize(g) * scaleX
 	h := w * scaleY
-	log.Printf("update gonum layout: gonum layout(%f x %f) our layout (%f x %f)", gnw, gnh, w, h)
 
 	// update our coodinates and scale
 	for nodeID := range g.Nodes {
@@ -59,11 +136,9 @@ func updateGraphByGonumLayout(g Graph, gnLayout gnLayoutGetter, scaleX float64,
 		x := gnNode.X * w / gnw
 		y := gnNode.Y * h / gnh
 
-		g.Nodes[nodeID] = Node{
-			XY: [2]int{int(x), int(y)},
-			W:  g.Nodes[nodeID].W,
-			H:  g.Nodes[nodeID].H,
-		}
+		node := g.Nodes[nodeID]
+		node.XY = [2]int{int(x), int(y)}
+		g.Nodes[nodeID] = node
 	}
 }
 
@@ -75,9 +150,29 @@ type EadesGonumLayout struct {
 	Theta     float64
 	ScaleX    float64
 	ScaleY    float64
+	Seed      uint64 // initial positions of nodes are random with this seed
+	Observer  Observer
+}
+
+// UpdateGraphLayoutContext stops updates when context is done, keeping positions with lowest energy found so far.
+func (l EadesGonumLayout) UpdateGraphLayoutContext(ctx context.Context, g Graph) error {
+	if err := g.Validate(); err != nil {
+		return err
+	}
+	l.updateGraphLayout(ctx, g)
+	return nil
 }
 
 func (l EadesGonumLayout) UpdateGraphLayout(g Graph) {
+	l.updateGraphLayout(context.Background(), g)
+}
+
+// UpdateGraphLayoutE validates graph and updates its layout until completion.
+func (l EadesGonumLayout) UpdateGraphLayoutE(g Graph) error {
+	return l.UpdateGraphLayoutContext(context.Background(), g)
+}
+
+func (l EadesGonumLayout) updateGraphLayout(ctx context.Context, g Graph) {
 	gn := toGonumGraph(g)
 
 	eades := gnlayout.EadesR2{
@@ -85,24 +180,98 @@ func (l EadesGonumLayout) UpdateGraphLayout(g Graph) {
 		Repulsion: l.Repulsion,
 		Rate:      l.Rate,
 		Theta:     l.Theta,
+		Src:       rand.NewSource(l.Seed),
 	}
 	optimizer := gnlayout.NewOptimizerR2(gn, eades.Update)
-	for optimizer.Update() {
+
+	// positions with lowest energy, that are restored when context is done
+	bestEnergy := math.Inf(1)
+	var best gonumCoords
+
+	start := time.Now()
+	for step := 0; ctx.Err() == nil && optimizer.Update(); step++ {
+		coords := newGonumCoords(gn, optimizer)
+		energy := l.energy(gn, coords)
+		observe(l.Observer, Event{Phase: PhaseGonumUpdate, Step: step, Energy: energy, Elapsed: time.Since(start)})
+
+		if energy < bestEnergy {
+			bestEnergy = energy
+			best = coords
+		}
 	}
 
+	if ctx.Err() != nil && best != nil {
+		updateGraphByGonumLayout(g, best, l.ScaleX, l.ScaleY)
+		return
+	}
 	updateGraphByGonumLayout(g, optimizer, l.ScaleX, l.ScaleY)
 }
 
+// energy is sum of squared forces on nodes, same as forces of Eades updates in gonum.
+// time: O(N log(N) + E)
+func (l EadesGonumLayout) energy(gn gonumGraph, coords gonumCoords) float64 {
+	particles := make([]gnbarneshut.Particle2, len(gn.nodes))
+	for i, n := range gn.nodes {
+		particles[i] = gonumParticle{id: n.ID(), pos: coords[n.ID()]}
+	}
+	plane, err := gnbarneshut.NewPlane(particles)
+	if err != nil {
+		return math.Inf(1)
+	}
+
+	forces := make(map[int64]gnr2.Vec, len(particles))
+	for _, p := range particles {
+		forces[p.(gonumParticle).id] = gnr2.Scale(-l.Repulsion, plane.ForceOn(p, l.Theta, gnbarneshut.Gravity2))
+	}
+	for _, n := range gn.nodes {
+		for _, m := range gn.neighbors[n.ID()] {
+			if n.ID() > m.ID() {
+				continue
+			}
+			v := gnr2.Sub(coords[m.ID()], coords[n.ID()])
+			f := gnr2.Scale(math.Log(math.Hypot(v.X, v.Y)), v)
+			forces[n.ID()] = gnr2.Add(forces[n.ID()], f)
+			forces[m.ID()] = gnr2.Sub(forces[m.ID()], f)
+		}
+	}
+
+	energy := 0.0
+	for _, f := range forces {
+		energy += f.X*f.X + f.Y*f.Y
+	}
+	return energy
+}
+
 type IsomapR2GonumLayout struct {
-	Scale  float64
-	ScaleX float64
-	ScaleY float64
+	Scale    float64
+	ScaleX   float64
+	ScaleY   float64
+	Observer Observer
+}
+
+// UpdateGraphLayoutContext keeps current positions when context is done before layout.
+// Isomap is one update of shortest paths between all nodes and scaling of them, that can not be stopped in the middle.
+func (l IsomapR2GonumLayout) UpdateGraphLayoutContext(ctx context.Context, g Graph) error {
+	if err := g.Validate(); err != nil {
+		return err
+	}
+	if ctx.Err() == nil {
+		l.UpdateGraphLayout(g)
+	}
+	return nil
 }
 
 func (l IsomapR2GonumLayout) UpdateGraphLayout(g Graph) {
 	gn := toGonumGraph(g)
 	optimizer := gnlayout.NewOptimizerR2(gn, gnlayout.IsomapR2{}.Update)
-	for optimizer.Update() {
+	start := time.Now()
+	for step := 0; optimizer.Update(); step++ {
+		observe(l.Observer, Event{Phase: PhaseGonumUpdate, Step: step, Elapsed: time.Since(start)})
 	}
 	updateGraphByGonumLayout(g, optimizer, l.ScaleX, l.ScaleY)
 }
+
+// UpdateGraphLayoutE validates graph and updates its layout.
+func (l IsomapR2GonumLayout) UpdateGraphLayoutE(g Graph) error {
+	return l.UpdateGraphLayoutContext(context.Background(), g)
+}
@@ -0,0 +1,70 @@
+package layout_test
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/nikolaydubina/go-graph-layout/layout"
+)
+
+func TestEadesGonumLayoutContext(t *testing.T) {
+	l := layout.EadesGonumLayout{
+		Repulsion: 1,
+		Rate:      0.01,
+		Updates:   100,
+		Theta:     0.2,
+		ScaleX:    0.5,
+		ScaleY:    0.5,
+		Seed:      1,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// random initial positions make energy go up after first steps
+	minEnergy, minStep, cancelStep := math.Inf(1), -1, 2
+	cancelled := l
+	cancelled.Observer = layout.ObserverFunc(func(e layout.Event) {
+		if e.Energy < minEnergy {
+			minEnergy, minStep = e.Energy, e.Step
+		}
+		if e.Step == cancelStep {
+			cancel()
+		}
+	})
+	g := newTestRandomGraph(20, 30)
+	if err := cancelled.UpdateGraphLayoutContext(ctx, g); err != nil {
+		t.Fatal(err)
+	}
+	if minStep == cancelStep {
+		t.Fatalf("expected lowest energy before last step, got at step %d", minStep)
+	}
+
+	// same updates up to step with lowest energy
+	expected := newTestRandomGraph(20, 30)
+	l.Updates = minStep + 1
+	l.UpdateGraphLayout(expected)
+
+	for n, node := range expected.Nodes {
+		if g.Nodes[n].XY != node.XY {
+			t.Errorf("node %d: expected position with lowest energy %v, got %v", n, node.XY, g.Nodes[n].XY)
+		}
+	}
+}
+
+func TestIsomapR2GonumLayoutContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	g := newTestRandomGraph(10, 15)
+	expected := newTestRandomGraph(10, 15)
+	if err := (layout.IsomapR2GonumLayout{ScaleX: 0.5, ScaleY: 0.5}).UpdateGraphLayoutContext(ctx, g); err != nil {
+		t.Fatal(err)
+	}
+	for n, node := range expected.Nodes {
+		if g.Nodes[n].XY != node.XY {
+			t.Errorf("node %d: expected position kept %v, got %v", n, node.XY, g.Nodes[n].XY)
+		}
+	}
+}
@@ -1,16 +1,25 @@
 package layout
 
+import (
+	"math"
+	"sort"
+)
+
 // Graph tells how to position nodes and paths for edges
 type Graph struct {
-	Edges map[[2]uint64]Edge
-	Nodes map[uint64]Node
+	Edges    map[EdgeID]Edge
+	Nodes    map[uint64]Node
+	Clusters map[uint64]Cluster // groups of nodes drawn in boxes, can be nil
+	Ranks    []RankConstraint   // nodes in same, top or bottom layers in layered layouts
 }
 
 // Node is how to position node and its dimensions
 type Node struct {
-	XY [2]int // smallest x,y corner
-	W  int
-	H  int
+	XY    [2]int // smallest x,y corner
+	W     int
+	H     int
+	Ports map[string]Port // named points on sides of node where edges attach
+	Shape Shape           // outline of node within its box, edges are clipped to it
 }
 
 func (n Node) CenterXY() [2]int {
@@ -19,34 +28,168 @@ func (n Node) CenterXY() [2]int {
 	return [2]int{x, y}
 }
 
+// PortXY is where edge attaches to node at given port.
+// Center of node when port is empty or not found.
+func (n Node) PortXY(port string) [2]int {
+	c := n.CenterXY()
+	d := n.portOffset(port)
+	return [2]int{c[0] + d[0], c[1] + d[1]}
+}
+
+// portOffset is position of port relative to center of node.
+func (n Node) portOffset(port string) [2]int {
+	p, ok := n.Ports[port]
+	if !ok {
+		return [2]int{0, 0}
+	}
+	switch p.Side {
+	case SideTop:
+		return [2]int{p.Offset - n.W/2, -n.H / 2}
+	case SideBottom:
+		return [2]int{p.Offset - n.W/2, n.H - n.H/2}
+	case SideLeft:
+		return [2]int{-n.W / 2, p.Offset - n.H/2}
+	case SideRight:
+		return [2]int{n.W - n.W/2, p.Offset - n.H/2}
+	}
+	return [2]int{0, 0}
+}
+
+// portFraction is position of port along axis relative to center of node, as fraction of size of node.
+// It is in range [-0.5, 0.5], zero is center.
+func (n Node) portFraction(port string, axis int) float64 {
+	size := n.W
+	if axis == 1 {
+		size = n.H
+	}
+	if size == 0 {
+		return 0
+	}
+	return float64(n.portOffset(port)[axis]) / float64(size)
+}
+
+// Side is side of node box.
+type Side uint8
+
+const (
+	SideTop Side = iota
+	SideBottom
+	SideLeft
+	SideRight
+)
+
+// Shape is outline of node inscribed in its box.
+type Shape uint8
+
+const (
+	ShapeBox Shape = iota
+	ShapeEllipse
+	ShapeDiamond
+)
+
+// containsXY is when point is strictly inside of shape of node.
+func (n Node) containsXY(p [2]float64) bool {
+	rx, ry := float64(n.W)/2, float64(n.H)/2
+	if rx <= 0 || ry <= 0 {
+		return false
+	}
+	dx := math.Abs(p[0]-float64(n.XY[0])-rx) / rx
+	dy := math.Abs(p[1]-float64(n.XY[1])-ry) / ry
+	switch n.Shape {
+	case ShapeEllipse:
+		return dx*dx+dy*dy < 1
+	case ShapeDiamond:
+		return dx+dy < 1
+	default:
+		return dx < 1 && dy < 1
+	}
+}
+
+// Port is point on side of node box where edges attach.
+type Port struct {
+	Side   Side
+	Offset int // distance from left corner for top and bottom sides, distance from top corner for left and right sides
+}
+
+// EdgeID is {source node, target node, number of edge}.
+// Parallel edges between same nodes have different numbers, single edge usually has number zero.
+type EdgeID [3]uint64
+
+// isSelfLoop is when edge starts and ends at same node.
+func (e EdgeID) isSelfLoop() bool { return e[0] == e[1] }
+
 // Edge is path of points that edge goes through
 type Edge struct {
-	Path [][2]int // [start: {x,y}, ... finish: {x,y}]
+	Path     [][2]int // [start: {x,y}, ... finish: {x,y}]
+	Curve    [][2]int // piecewise cubic Bezier curve [start, {control, control, end}, ...], edge is drawn as Path when empty
+	Weight   int      // how important is to keep edge short in layers, zero is same as one
+	MinLen   int      // minimal number of layers edge spans, zero is same as one
+	Length   float64  // length of edge in StressMajorizationLayout as multiple of its edge length, zero is same as one
+	FromPort string   // port of source node where edge starts, center of node when empty
+	ToPort   string   // port of target node where edge ends, center of node when empty
+	Label    Label    // box of text of edge, edge has no label when box is empty
+}
+
+// Label is box of text of edge, its position is set by layouts.
+type Label struct {
+	XY [2]int // smallest x,y corner
+	W  int
+	H  int
 }
 
+func (l Label) isEmpty() bool { return l.W <= 0 || l.H <= 0 }
+
 func (g Graph) Copy() Graph {
 	ng := Graph{
 		Nodes: make(map[uint64]Node, len(g.Nodes)),
-		Edges: make(map[[2]uint64]Edge, len(g.Edges)),
+		Edges: make(map[EdgeID]Edge, len(g.Edges)),
 	}
 	for id, n := range g.Nodes {
+		if n.Ports != nil {
+			ports := make(map[string]Port, len(n.Ports))
+			for k, p := range n.Ports {
+				ports[k] = p
+			}
+			n.Ports = ports
+		}
 		ng.Nodes[id] = n
 	}
 	for id, e := range g.Edges {
-		ng.Edges[id] = Edge{Path: make([][2]int, len(e.Path))}
-		copy(ng.Edges[id].Path, e.Path)
+		ne := e
+		ne.Path = make([][2]int, len(e.Path))
+		copy(ne.Path, e.Path)
+		if e.Curve != nil {
+			ne.Curve = make([][2]int, len(e.Curve))
+			copy(ne.Curve, e.Curve)
+		}
+		ng.Edges[id] = ne
+	}
+	if g.Clusters != nil {
+		ng.Clusters = make(map[uint64]Cluster, len(g.Clusters))
+		for id, c := range g.Clusters {
+			c.Nodes = append([]uint64(nil), c.Nodes...)
+			c.Clusters = append([]uint64(nil), c.Clusters...)
+			ng.Clusters[id] = c
+		}
+	}
+	for _, r := range g.Ranks {
+		r.Nodes = append([]uint64(nil), r.Nodes...)
+		ng.Ranks = append(ng.Ranks, r)
 	}
 	return ng
 }
 
+// Roots are nodes without incoming edges, self loops are not counted.
 func (g Graph) Roots() []uint64 {
 	hasParent := make(map[uint64]bool, len(g.Nodes))
 	for e := range g.Edges {
-		hasParent[e[1]] = true
+		if !e.isSelfLoop() {
+			hasParent[e[1]] = true
+		}
 	}
 
 	var roots []uint64
-	for n := range g.Nodes {
+	for _, n := range g.nodeIDs() {
 		if !hasParent[n] {
 			roots = append(roots, n)
 		}
@@ -54,6 +197,92 @@ func (g Graph) Roots() []uint64 {
 	return roots
 }
 
+// Validate checks that all edges reference nodes in graph, that clusters are nested properly and that rank constraints have nodes in graph.
+func (g Graph) Validate() error {
+	for _, e := range g.edgeIDs() {
+		for _, n := range e[:2] {
+			if _, ok := g.Nodes[n]; !ok {
+				return MissingNodeError{Edge: e, Node: n}
+			}
+		}
+	}
+	if err := g.validateClusters(); err != nil {
+		return err
+	}
+	return g.validateRanks()
+}
+
+// cycleNodes are nodes that are in cycles or reachable only through cycles.
+// Empty when graph does not have cycles.
+func (g Graph) cycleNodes() []uint64 {
+	indegree := make(map[uint64]int, len(g.Nodes))
+	children := make(map[uint64][]uint64, len(g.Nodes))
+	for e := range g.Edges {
+		indegree[e[1]]++
+		children[e[0]] = append(children[e[0]], e[1])
+	}
+
+	que := g.Roots()
+	visited := make(map[uint64]bool, len(g.Nodes))
+	for ; len(que) > 0; que = que[1:] {
+		visited[que[0]] = true
+		for _, c := range children[que[0]] {
+			indegree[c]--
+			if indegree[c] == 0 {
+				que = append(que, c)
+			}
+		}
+	}
+
+	var nodes []uint64
+	for _, n := range g.nodeIDs() {
+		if !visited[n] {
+			nodes = append(nodes, n)
+		}
+	}
+	return nodes
+}
+
+// nodeIDs are nodes sorted by id, useful for deterministic iteration.
+func (g Graph) nodeIDs() []uint64 {
+	ids := make([]uint64, 0, len(g.Nodes))
+	for id := range g.Nodes {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// edgeIDs are edges sorted by source, then by target and then by number, useful for deterministic iteration.
+func (g Graph) edgeIDs() []EdgeID {
+	ids := make([]EdgeID, 0, len(g.Edges))
+	for e := range g.Edges {
+		ids = append(ids, e)
+	}
+	sortEdgeIDs(ids)
+	return ids
+}
+
+func sortEdgeIDs(edges []EdgeID) {
+	sort.Slice(edges, func(i, j int) bool {
+		for k := range edges[i] {
+			if edges[i][k] != edges[j][k] {
+				return edges[i][k] < edges[j][k]
+			}
+		}
+		return false
+	})
+}
+
+func sortEdges(edges [][2]uint64) {
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i][0] != edges[j][0] {
+			return edges[i][0] < edges[j][0]
+		}
+		return edges[i][1] < edges[j][1]
+	})
+}
+
 func (g Graph) TotalNodesWidth() int {
 	w := 0
 	for _, node := range g.Nodes {
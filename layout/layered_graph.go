@@ -10,15 +10,81 @@ import (
 // Short edge is between nodes in Layers next to each other.
 // Long edge is between nodes in 1+ Layers between each other.
 // Segment is either a short edge or a long edge.
+// Flat edge is between nodes in same layer, it is not segment.
 // Top layer has lowest layer number.
 type LayeredGraph struct {
-	Segments map[[2]uint64]bool     // segment is an edge in layered graph, can be real edge or piece of fake edge
-	Dummy    map[uint64]bool        // fake nodes
-	NodeYX   map[uint64][2]int      // node -> {layer, ordering in layer}
-	Edges    map[[2]uint64][]uint64 // real long/short edge -> {real, fake, fake, fake, real} nodes
+	Segments map[[2]uint64]bool       // segment is an edge in layered graph, can be real edge or piece of fake edge
+	Dummy    map[uint64]bool          // fake nodes
+	NodeYX   map[uint64][2]int        // node -> {layer, ordering in layer}
+	Edges    map[EdgeID][]uint64      // real long/short edge -> {real, fake, fake, fake, real} nodes, self loops are not in layered graph
+	Ports    map[[2]uint64][2]float64 // segment -> position of {start, end} along layer relative to center of node, as fraction of node size, missing is center
+	Labels   map[uint64]EdgeID        // fake node -> edge which label is placed next to fake node
+	Borders  map[uint64]ClusterBorder // fake node -> border of cluster in layer of fake node
+
+	RankDirection RankDirection // direction of layers in final layout, coordinates are assigned as if layers go top to bottom
+
+	index *layeredIndex // shared by copies, nil when graph is not made by constructors and not reindexed
+}
+
+// layeredIndex is neighbors and layers of layered graph, so that lookups do not scan all segments and nodes.
+type layeredIndex struct {
+	adj          segmentAdjacency
+	upper, lower map[uint64][]uint64 // neighbors in adjacent layers sorted by order in layer
+	layers       [][]uint64
+}
+
+// Reindex rebuilds neighbors and layers after Segments or NodeYX are changed.
+// Layered graphs made by NewLayeredGraph are indexed, ordering phase reindexes graph after it changes ordering.
+// Copies of graph share index.
+// time: O(V log V + E log E)
+func (g *LayeredGraph) Reindex() {
+	if g.index == nil {
+		g.index = &layeredIndex{}
+	}
+	adj := newSegmentAdjacency(g.Segments)
+	*g.index = layeredIndex{
+		adj:    adj,
+		upper:  make(map[uint64][]uint64, len(adj.up)),
+		lower:  make(map[uint64][]uint64, len(adj.down)),
+		layers: g.makeLayers(),
+	}
+	for n, adjacent := range adj.up {
+		g.index.upper[n] = g.neighbors(n, adjacent, -1)
+	}
+	for n, adjacent := range adj.down {
+		g.index.lower[n] = g.neighbors(n, adjacent, 1)
+	}
 }
 
+// before is when node a is before node b in layer.
+func (g LayeredGraph) before(a, b uint64) bool {
+	if g.NodeYX[a][1] != g.NodeYX[b][1] {
+		return g.NodeYX[a][1] < g.NodeYX[b][1]
+	}
+	return a < b
+}
+
+// adjacency is segments with neighbors of nodes.
+// It is made from segments when graph is not indexed.
+// time: O(1) when indexed, O(E) otherwise
+func (g LayeredGraph) adjacency() segmentAdjacency {
+	if g.index != nil {
+		return g.index.adj
+	}
+	return newSegmentAdjacency(g.Segments)
+}
+
+// Layers are nodes in each layer sorted by order in layer, ties by id for determinism.
+// Returned layers can be changed by caller.
+// time: O(V) when indexed, O(V log V) otherwise
 func (g LayeredGraph) Layers() [][]uint64 {
+	if g.index != nil {
+		return newLayersFrom(g.index.layers)
+	}
+	return g.makeLayers()
+}
+
+func (g LayeredGraph) makeLayers() [][]uint64 {
 	maxY := 0
 	for _, yx := range g.NodeYX {
 		if yx[0] > maxY {
@@ -27,27 +93,155 @@ func (g LayeredGraph) Layers() [][]uint64 {
 	}
 
 	layers := make([][]uint64, maxY+1)
-	for y := 0; y < len(layers); y++ {
-		// collect to layer
-		for node, yx := range g.NodeYX {
-			if yx[0] == y {
-				layers[y] = append(layers[y], node)
-			}
-		}
-
-		// sort within layer
-		sort.Slice(layers[y], func(i, j int) bool { return g.NodeYX[layers[y][i]][1] < g.NodeYX[layers[y][j]][1] })
+	for node, yx := range g.NodeYX {
+		layers[yx[0]] = append(layers[yx[0]], node)
+	}
+	for _, layer := range layers {
+		sort.Slice(layer, func(i, j int) bool { return g.before(layer[i], layer[j]) })
 	}
-
 	return layers
 }
 
+// Validate checks that edges are split into segments and all segments go from upper layer to lower layer.
+// Flat edges are not checked for direction.
 func (g LayeredGraph) Validate() error {
+	edges := make([]EdgeID, 0, len(g.Edges))
+	for e := range g.Edges {
+		edges = append(edges, e)
+	}
+	sortEdgeIDs(edges)
+	for _, e := range edges {
+		nodes := g.Edges[e]
+		if len(nodes) < 2 {
+			return InconsistentEdgeError{Edge: e, Reason: fmt.Sprintf("has nodes(%v) but at least 2 expected", nodes)}
+		}
+		if nodes[0] != e[0] || nodes[len(nodes)-1] != e[1] {
+			return InconsistentEdgeError{Edge: e, Reason: fmt.Sprintf("has nodes(%v) that do not start and end with edge nodes", nodes)}
+		}
+	}
+
+	segments := make([][2]uint64, 0, len(g.Segments))
 	for e := range g.Segments {
+		segments = append(segments, e)
+	}
+	sortEdges(segments)
+	for _, e := range segments {
+		for _, n := range e {
+			if _, ok := g.NodeYX[n]; !ok {
+				return MissingNodeError{Edge: EdgeID{e[0], e[1]}, Node: n}
+			}
+		}
 		from := g.NodeYX[e[0]][0]
 		to := g.NodeYX[e[1]][0]
 		if from >= to {
-			return fmt.Errorf("edge(%v) is wrong direction, got from level(%d) to level(%d)", e, from, to)
+			return LayerDirectionError{Segment: e, FromLayer: from, ToLayer: to}
+		}
+	}
+	return nil
+}
+
+// segmentPorts are positions of ports of real nodes along layers for first and last segments of edges.
+// Axis is coordinate along layer in final layout.
+// Nil when edges do not have ports.
+func segmentPorts(g Graph, lg LayeredGraph, axis int) map[[2]uint64][2]float64 {
+	var ports map[[2]uint64][2]float64
+	set := func(segment [2]uint64, end int, v float64) {
+		if ports == nil {
+			ports = make(map[[2]uint64][2]float64)
+		}
+		p := ports[segment]
+		p[end] = v
+		ports[segment] = p
+	}
+
+	for e, nodes := range lg.Edges {
+		edge := g.Edges[e]
+		if _, ok := g.Nodes[e[0]].Ports[edge.FromPort]; ok {
+			set([2]uint64{nodes[0], nodes[1]}, 0, g.Nodes[e[0]].portFraction(edge.FromPort, axis))
+		}
+		if _, ok := g.Nodes[e[1]].Ports[edge.ToPort]; ok {
+			set([2]uint64{nodes[len(nodes)-2], nodes[len(nodes)-1]}, 1, g.Nodes[e[1]].portFraction(edge.ToPort, axis))
+		}
+	}
+	return ports
+}
+
+// labelLayers makes space for labels of edges in layered graph.
+// Layer is inserted between layers that have labeled edge going from one to other,
+// so that every labeled edge that is not flat has fake node, and label of edge is put next to its middle fake node.
+// Layered graph is not changed when edges do not have labels.
+func labelLayers(g Graph, lg LayeredGraph) LayeredGraph {
+	var labeled []EdgeID
+	for _, e := range g.edgeIDs() {
+		if !g.Edges[e].Label.isEmpty() {
+			labeled = append(labeled, e)
+		}
+	}
+	if len(labeled) == 0 {
+		return lg
+	}
+
+	numLayers := 0
+	for _, yx := range lg.NodeYX {
+		if yx[0]+1 > numLayers {
+			numLayers = yx[0] + 1
+		}
+	}
+	// inserted[l] is when new layer is before layer l
+	inserted := make([]bool, numLayers)
+	for _, e := range labeled {
+		if from, to := lg.NodeYX[e[0]][0], lg.NodeYX[e[1]][0]; to == from+1 {
+			inserted[to] = true
+		}
+	}
+	shift := make([]int, numLayers)
+	for l := range shift {
+		if l > 0 {
+			shift[l] = shift[l-1]
+		}
+		if inserted[l] {
+			shift[l]++
+		}
+	}
+
+	nodeYX := make(map[uint64][2]int, len(g.Nodes))
+	for n := range g.Nodes {
+		layer := lg.NodeYX[n][0]
+		nodeYX[n] = [2]int{layer + shift[layer], 0}
+	}
+	spread := newLayeredGraph(g, nodeYX)
+
+	spread.Labels = make(map[uint64]EdgeID, len(labeled))
+	for _, e := range labeled {
+		// labels of flat edges are placed with their routes
+		if nodes := spread.Edges[e]; len(nodes) > 2 {
+			spread.Labels[nodes[len(nodes)/2]] = e
+		}
+	}
+	return spread
+}
+
+// labelSize is size of box of fake node with label,
+// so that edge goes through center of box and label is after it along layer.
+func (g LayeredGraph) labelSize(graph Graph, node uint64) (w, h int, ok bool) {
+	e, ok := g.Labels[node]
+	if !ok {
+		return 0, 0, false
+	}
+	label := graph.Edges[e].Label
+	return 2 * label.W, label.H, true
+}
+
+// validateEdgesMatch checks that layered graph has exactly same edges as graph, except self loops.
+func validateEdgesMatch(g Graph, lg LayeredGraph) error {
+	for _, e := range g.edgeIDs() {
+		if _, ok := lg.Edges[e]; !ok && !e.isSelfLoop() {
+			return InconsistentEdgeError{Edge: e, Reason: "is not found in the layered graph"}
+		}
+	}
+	for e := range lg.Edges {
+		if _, ok := g.Edges[e]; !ok {
+			return InconsistentEdgeError{Edge: e, Reason: "layered graph edge is not found in the original graph"}
 		}
 	}
 	return nil
@@ -81,29 +275,45 @@ func (g LayeredGraph) IsInnerSegment(segment [2]uint64) bool {
 	return g.Dummy[segment[0]] && g.Dummy[segment[1]]
 }
 
-// UpperNeighbors are nodes in upper layer that are connected to given node.
+// UpperNeighbors are nodes in upper layer that are connected to given node, sorted by order in layer.
+// time: O(deg) when indexed, O(E + deg log deg) otherwise
 func (g LayeredGraph) UpperNeighbors(node uint64) []uint64 {
-	var nodes []uint64
+	if g.index != nil {
+		return append([]uint64(nil), g.index.upper[node]...)
+	}
+	var adjacent []uint64
 	for e := range g.Segments {
 		if e[1] == node {
-			if g.NodeYX[e[0]][0] == (g.NodeYX[e[1]][0] - 1) {
-				nodes = append(nodes, e[0])
-			}
+			adjacent = append(adjacent, e[0])
 		}
 	}
-	return nodes
+	return g.neighbors(node, adjacent, -1)
 }
 
-// LowerNeighbors are nodes in lower layer that are connected to given node.
+// LowerNeighbors are nodes in lower layer that are connected to given node, sorted by order in layer.
+// time: O(deg) when indexed, O(E + deg log deg) otherwise
 func (g LayeredGraph) LowerNeighbors(node uint64) []uint64 {
-	var nodes []uint64
+	if g.index != nil {
+		return append([]uint64(nil), g.index.lower[node]...)
+	}
+	var adjacent []uint64
 	for e := range g.Segments {
 		if e[0] == node {
-			if g.NodeYX[e[0]][0] == (g.NodeYX[e[1]][0] - 1) {
-				nodes = append(nodes, e[0])
-			}
+			adjacent = append(adjacent, e[1])
+		}
+	}
+	return g.neighbors(node, adjacent, 1)
+}
+
+// neighbors are adjacent nodes that are in next layer in direction, sorted by order in layer.
+func (g LayeredGraph) neighbors(node uint64, adjacent []uint64, direction int) []uint64 {
+	var nodes []uint64
+	for _, n := range adjacent {
+		if g.NodeYX[n][0] == g.NodeYX[node][0]+direction {
+			nodes = append(nodes, n)
 		}
 	}
+	sort.Slice(nodes, func(i, j int) bool { return g.before(nodes[i], nodes[j]) })
 	return nodes
 }
 
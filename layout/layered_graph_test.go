@@ -0,0 +1,67 @@
+package layout_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/nikolaydubina/go-graph-layout/layout"
+)
+
+func TestLayeredGraphNeighbors(t *testing.T) {
+	g := layout.Graph{
+		Nodes: map[uint64]layout.Node{1: {}, 2: {}, 3: {}, 4: {}},
+		Edges: map[layout.EdgeID]layout.Edge{{1, 2}: {}, {1, 3}: {}, {2, 4}: {}, {3, 4}: {}},
+	}
+	lg := layout.NewLayeredGraph(g)
+	lg.NodeYX[2], lg.NodeYX[3] = [2]int{1, 1}, [2]int{1, 0}
+	lg.Reindex()
+
+	check := func(name string, got []uint64, expected string) {
+		t.Helper()
+		if fmt.Sprint(got) != expected {
+			t.Errorf("%s: expected %s, got %v", name, expected, got)
+		}
+	}
+
+	check("lower", lg.LowerNeighbors(1), "[3 2]")
+	check("upper", lg.UpperNeighbors(4), "[3 2]")
+	check("upper of root", lg.UpperNeighbors(1), "[]")
+	check("layers", lg.Layers()[1], "[3 2]")
+
+	t.Run("moved nodes", func(t *testing.T) {
+		lg.NodeYX[2], lg.NodeYX[3] = [2]int{1, 0}, [2]int{1, 1}
+		lg.Reindex()
+		check("lower", lg.LowerNeighbors(1), "[2 3]")
+		check("layers", lg.Layers()[1], "[2 3]")
+	})
+
+	t.Run("added nodes", func(t *testing.T) {
+		lg.NodeYX[5] = [2]int{1, 2}
+		lg.Segments[[2]uint64{1, 5}] = true
+		lg.Reindex()
+		check("lower", lg.LowerNeighbors(1), "[2 3 5]")
+		check("layers", lg.Layers()[1], "[2 3 5]")
+	})
+
+	t.Run("replaced segments", func(t *testing.T) {
+		lg.NodeYX[6] = [2]int{1, 3}
+		delete(lg.Segments, [2]uint64{1, 5})
+		lg.Segments[[2]uint64{1, 6}] = true
+		lg.Reindex()
+		check("lower", lg.LowerNeighbors(1), "[2 3 6]")
+		check("upper", lg.UpperNeighbors(5), "[]")
+	})
+
+	t.Run("not indexed", func(t *testing.T) {
+		literal := layout.LayeredGraph{NodeYX: lg.NodeYX, Segments: lg.Segments}
+		check("lower", literal.LowerNeighbors(1), "[2 3 6]")
+		check("layers", literal.Layers()[1], "[2 3 5 6]")
+	})
+
+	t.Run("changed results are not indexed", func(t *testing.T) {
+		lg.LowerNeighbors(1)[0] = 100
+		lg.Layers()[1][0] = 100
+		check("lower", lg.LowerNeighbors(1), "[2 3 6]")
+		check("layers", lg.Layers()[1], "[2 3 5 6]")
+	})
+}
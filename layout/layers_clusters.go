@@ -0,0 +1,342 @@
+package layout
+
+import (
+	"math"
+	"sort"
+)
+
+// ClusterBorder tells that fake node is left or right border of cluster in its layer.
+// Borders of cluster in consecutive layers are connected by segments, so that sides of box of cluster are straight.
+type ClusterBorder struct {
+	Cluster uint64
+	Right   bool
+}
+
+// clusterBorders adds fake nodes for left and right borders of clusters in each layer that cluster spans.
+// Cluster spans layers from its top to its bottom node, including nodes of nested clusters.
+// Layered graph is not changed when graph does not have clusters.
+func clusterBorders(g Graph, lg LayeredGraph) LayeredGraph {
+	if len(g.Clusters) == 0 {
+		return lg
+	}
+	tree := newClusterTree(g)
+
+	spans := make(map[uint64][2]int)
+	for _, n := range g.nodeIDs() {
+		layer := lg.NodeYX[n][0]
+		for _, c := range tree.nodeChain(n) {
+			span, ok := spans[c]
+			if !ok {
+				span = [2]int{layer, layer}
+			}
+			if layer < span[0] {
+				span[0] = layer
+			}
+			if layer > span[1] {
+				span[1] = layer
+			}
+			spans[c] = span
+		}
+	}
+
+	var next uint64
+	for n := range lg.NodeYX {
+		if n >= next {
+			next = n + 1
+		}
+	}
+
+	lg.Borders = make(map[uint64]ClusterBorder)
+	for _, c := range g.clusterIDs() {
+		span, ok := spans[c]
+		if !ok {
+			continue
+		}
+		for _, right := range [2]bool{false, true} {
+			for layer := span[0]; layer <= span[1]; layer++ {
+				lg.NodeYX[next] = [2]int{layer, 0}
+				lg.Dummy[next] = true
+				lg.Borders[next] = ClusterBorder{Cluster: c, Right: right}
+				if layer > span[0] {
+					lg.Segments[[2]uint64{next - 1, next}] = true
+				}
+				next++
+			}
+		}
+	}
+	return lg
+}
+
+// layeredNodeChains are clusters that have nodes of layered graph, from top cluster.
+// Fake nodes of edges are in clusters that have both ends of edge.
+func layeredNodeChains(tree clusterTree, lg LayeredGraph) map[uint64][]uint64 {
+	chains := make(map[uint64][]uint64, len(lg.NodeYX))
+	for e, nodes := range lg.Edges {
+		common := commonChain(tree.nodeChain(e[0]), tree.nodeChain(e[1]))
+		for _, n := range nodes[1 : len(nodes)-1] {
+			chains[n] = common
+		}
+	}
+	for n := range lg.NodeYX {
+		if b, ok := lg.Borders[n]; ok {
+			chains[n] = tree.chain(b.Cluster)
+		} else if !lg.Dummy[n] {
+			chains[n] = tree.nodeChain(n)
+		}
+	}
+	return chains
+}
+
+// clusterOrderKey is how node or cluster is ordered among nodes and clusters within same cluster.
+type clusterOrderKey struct {
+	pos     float64
+	cluster bool
+	id      uint64
+}
+
+func (k clusterOrderKey) less(o clusterOrderKey) bool {
+	if k.pos != o.pos {
+		return k.pos < o.pos
+	}
+	if k.cluster != o.cluster {
+		return !k.cluster
+	}
+	return k.id < o.id
+}
+
+// orderClusters makes nodes of each cluster contiguous in each layer of layers, with borders of cluster on sides.
+// Clusters are ordered by average position of their nodes in all layers, so that clusters follow same order in all layers.
+// Nodes within cluster keep their order.
+func orderClusters(g Graph, lg LayeredGraph, layers [][]uint64) {
+	if len(lg.Borders) == 0 {
+		return
+	}
+	chains := layeredNodeChains(newClusterTree(g), lg)
+
+	// position relative to size of layer, so that wide and narrow layers are same
+	pos := make(map[uint64]float64, len(lg.NodeYX))
+	sum := make(map[uint64]float64)
+	count := make(map[uint64]int)
+	for _, layer := range layers {
+		for i, n := range layer {
+			pos[n] = (float64(i) + 0.5) / float64(len(layer))
+			if _, ok := lg.Borders[n]; ok {
+				continue
+			}
+			for _, c := range chains[n] {
+				sum[c] += pos[n]
+				count[c]++
+			}
+		}
+	}
+
+	// key of node among items at level of nesting
+	key := func(n uint64, chain []uint64, level int) clusterOrderKey {
+		if level < len(chain) {
+			c := chain[level]
+			return clusterOrderKey{pos: sum[c] / float64(count[c]), cluster: true, id: c}
+		}
+		if b, ok := lg.Borders[n]; ok {
+			if b.Right {
+				return clusterOrderKey{pos: math.Inf(1), id: n}
+			}
+			return clusterOrderKey{pos: math.Inf(-1), id: n}
+		}
+		return clusterOrderKey{pos: pos[n], id: n}
+	}
+
+	for _, layer := range layers {
+		sort.SliceStable(layer, func(i, j int) bool {
+			a, b := layer[i], layer[j]
+			level := len(commonChain(chains[a], chains[b]))
+			return key(a, chains[a], level).less(key(b, chains[b], level))
+		})
+	}
+}
+
+// clusterSpans are first and last layers of clusters, from their borders.
+func clusterSpans(lg LayeredGraph) map[uint64][2]int {
+	spans := make(map[uint64][2]int)
+	for n, b := range lg.Borders {
+		layer := lg.NodeYX[n][0]
+		span, ok := spans[b.Cluster]
+		if !ok {
+			span = [2]int{layer, layer}
+		}
+		if layer < span[0] {
+			span[0] = layer
+		}
+		if layer > span[1] {
+			span[1] = layer
+		}
+		spans[b.Cluster] = span
+	}
+	return spans
+}
+
+// shiftClusterLayers moves layers apart to leave padding for boxes of clusters that start or end at layers.
+// Nested clusters that start or end at same layer need padding for each of them.
+func shiftClusterLayers(g Graph, lg LayeredGraph, nodeY map[uint64]int, padding int) {
+	if len(lg.Borders) == 0 || padding <= 0 {
+		return
+	}
+	tree := newClusterTree(g)
+	spans := clusterSpans(lg)
+
+	numLayers := 0
+	for _, yx := range lg.NodeYX {
+		if yx[0]+1 > numLayers {
+			numLayers = yx[0] + 1
+		}
+	}
+	starts := make([]int, numLayers)
+	ends := make([]int, numLayers)
+	for c, span := range spans {
+		numStart, numEnd := 0, 0
+		for _, p := range tree.chain(c) {
+			if spans[p][0] == span[0] {
+				numStart++
+			}
+			if spans[p][1] == span[1] {
+				numEnd++
+			}
+		}
+		if numStart > starts[span[0]] {
+			starts[span[0]] = numStart
+		}
+		if numEnd > ends[span[1]] {
+			ends[span[1]] = numEnd
+		}
+	}
+
+	shift := make([]int, numLayers)
+	for l := range shift {
+		shift[l] = padding * starts[l]
+		if l > 0 {
+			shift[l] += shift[l-1] + padding*ends[l-1]
+		}
+	}
+	for n, y := range nodeY {
+		nodeY[n] = y + shift[lg.NodeYX[n][0]]
+	}
+}
+
+// clusterBoxes sets boxes of clusters around their nodes, nested clusters and borders.
+// Across layers boxes are padding away from their contents, along layers boxes are at borders.
+func clusterBoxes(g Graph, lg LayeredGraph, allNodesXY map[uint64][2]int, rankAxis, padding int) {
+	if len(lg.Borders) == 0 {
+		return
+	}
+	tree := newClusterTree(g)
+
+	boxes := make(map[uint64]box, len(g.Clusters))
+	add := func(c uint64, b box) {
+		if o, ok := boxes[c]; ok {
+			b = box{minX: minInt(o.minX, b.minX), minY: minInt(o.minY, b.minY), maxX: maxInt(o.maxX, b.maxX), maxY: maxInt(o.maxY, b.maxY)}
+		}
+		boxes[c] = b
+	}
+	for n, b := range lg.Borders {
+		xy := allNodesXY[n]
+		add(b.Cluster, box{minX: xy[0], minY: xy[1], maxX: xy[0], maxY: xy[1]})
+	}
+	for n, c := range tree.nodeCluster {
+		node, xy := g.Nodes[n], allNodesXY[n]
+		add(c, box{minX: xy[0] - node.W/2, minY: xy[1] - node.H/2, maxX: xy[0] - node.W/2 + node.W, maxY: xy[1] - node.H/2 + node.H})
+	}
+
+	// nested clusters first
+	ids := g.clusterIDs()
+	depth := make(map[uint64]int, len(ids))
+	for _, c := range ids {
+		depth[c] = len(tree.chain(c))
+	}
+	sort.SliceStable(ids, func(i, j int) bool { return depth[ids[i]] > depth[ids[j]] })
+
+	for _, c := range ids {
+		b, ok := boxes[c]
+		if !ok {
+			continue
+		}
+		if rankAxis == 0 {
+			b.minX, b.maxX = b.minX-padding, b.maxX+padding
+		} else {
+			b.minY, b.maxY = b.minY-padding, b.maxY+padding
+		}
+		if p, ok := tree.parent[c]; ok {
+			add(p, b)
+		}
+
+		cluster := g.Clusters[c]
+		cluster.XY = [2]int{b.minX, b.minY}
+		cluster.W, cluster.H = b.maxX-b.minX, b.maxY-b.minY
+		g.Clusters[c] = cluster
+	}
+}
+
+// straightenBorders moves nodes so that each border of cluster is at same coordinate in all layers, and boxes of clusters do not overlap.
+// Left borders are moved to their leftmost and right borders to their rightmost coordinates,
+// and then nodes are pushed right until separations in layers hold.
+// When clusters are not ordered same way in all layers, borders can not be straight and pushes do not stop,
+// then after number of rounds nodes are pushed only to keep separations in layers.
+func straightenBorders(lg LayeredGraph, x map[uint64]int, sep separation) {
+	if len(lg.Borders) == 0 {
+		return
+	}
+
+	type side struct {
+		cluster uint64
+		right   bool
+	}
+	sides := make(map[side][]uint64)
+	for n, b := range lg.Borders {
+		s := side{cluster: b.Cluster, right: b.Right}
+		sides[s] = append(sides[s], n)
+	}
+	for s, nodes := range sides {
+		v := x[nodes[0]]
+		for _, n := range nodes {
+			if (s.right && x[n] > v) || (!s.right && x[n] < v) {
+				v = x[n]
+			}
+		}
+		for _, n := range nodes {
+			x[n] = v
+		}
+	}
+
+	layers := lg.Layers()
+	separate := func() (changed bool) {
+		for _, layer := range layers {
+			for i := 1; i < len(layer); i++ {
+				if v := x[layer[i-1]] + sep(layer[i-1], layer[i]); x[layer[i]] < v {
+					x[layer[i]] = v
+					changed = true
+				}
+			}
+		}
+		return changed
+	}
+
+	changed := true
+	for round := 0; changed && round <= len(lg.NodeYX); round++ {
+		changed = separate()
+		for _, nodes := range sides {
+			v := x[nodes[0]]
+			for _, n := range nodes {
+				if x[n] > v {
+					v = x[n]
+				}
+			}
+			for _, n := range nodes {
+				if x[n] != v {
+					x[n] = v
+					changed = true
+				}
+			}
+		}
+	}
+	if changed {
+		separate()
+	}
+}
@@ -0,0 +1,146 @@
+package layout_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/nikolaydubina/go-graph-layout/layout"
+)
+
+// clusterMembers are nodes in cluster and in nested clusters.
+func clusterMembers(g layout.Graph, c uint64) map[uint64]bool {
+	members := map[uint64]bool{}
+	for _, n := range g.Clusters[c].Nodes {
+		members[n] = true
+	}
+	for _, child := range g.Clusters[c].Clusters {
+		for n := range clusterMembers(g, child) {
+			members[n] = true
+		}
+	}
+	return members
+}
+
+func isNested(g layout.Graph, parent, child uint64) bool {
+	for _, c := range g.Clusters[parent].Clusters {
+		if c == child || isNested(g, c, child) {
+			return true
+		}
+	}
+	return false
+}
+
+func boxesOverlap(a, b [4]int) bool {
+	return a[0] < b[2] && b[0] < a[2] && a[1] < b[3] && b[1] < a[3]
+}
+
+func boxContains(a, b [4]int) bool {
+	return a[0] <= b[0] && a[1] <= b[1] && b[2] <= a[2] && b[3] <= a[3]
+}
+
+// checkClusters checks that boxes of clusters have all their nodes, do not overlap other nodes, and do not overlap other clusters.
+func checkClusters(t *testing.T, g layout.Graph) {
+	clusterBox := func(c layout.Cluster) [4]int { return [4]int{c.XY[0], c.XY[1], c.XY[0] + c.W, c.XY[1] + c.H} }
+	nodeBox := func(n layout.Node) [4]int { return [4]int{n.XY[0], n.XY[1], n.XY[0] + n.W, n.XY[1] + n.H} }
+
+	for c, cluster := range g.Clusters {
+		if cluster.W <= 0 || cluster.H <= 0 {
+			t.Errorf("cluster(%d) has empty box", c)
+		}
+		members := clusterMembers(g, c)
+		for n, node := range g.Nodes {
+			switch {
+			case members[n] && !boxContains(clusterBox(cluster), nodeBox(node)):
+				t.Errorf("cluster(%d) %v does not contain node(%d) %v", c, clusterBox(cluster), n, nodeBox(node))
+			case !members[n] && boxesOverlap(clusterBox(cluster), nodeBox(node)):
+				t.Errorf("cluster(%d) %v overlaps node(%d) %v", c, clusterBox(cluster), n, nodeBox(node))
+			}
+		}
+		for o, other := range g.Clusters {
+			switch {
+			case o == c:
+			case isNested(g, c, o):
+				if !boxContains(clusterBox(cluster), clusterBox(other)) || clusterBox(cluster) == clusterBox(other) {
+					t.Errorf("cluster(%d) %v does not contain cluster(%d) %v with padding", c, clusterBox(cluster), o, clusterBox(other))
+				}
+			case !isNested(g, o, c) && boxesOverlap(clusterBox(cluster), clusterBox(other)):
+				t.Errorf("cluster(%d) %v overlaps cluster(%d) %v", c, clusterBox(cluster), o, clusterBox(other))
+			}
+		}
+	}
+}
+
+func TestSugiyamaLayersStrategyGraphLayout_Clusters(t *testing.T) {
+	for _, input := range []struct {
+		name  string
+		jsonl string
+	}{
+		{name: "gin", jsonl: ginJSONL},
+		{name: "labels", jsonl: labelsJSONL},
+	} {
+		for _, d := range []layout.RankDirection{layout.TopToBottom, layout.BottomToTop, layout.LeftToRight, layout.RightToLeft} {
+			t.Run(fmt.Sprintf("%s direction(%d)", input.name, d), func(t *testing.T) {
+				gd, g, err := parseJSONLGraph(input.jsonl)
+				if err != nil {
+					t.Fatal(err)
+				}
+				g.Clusters, _ = pathClusters(*gd)
+				if len(g.Clusters) == 0 {
+					t.Fatal("no clusters")
+				}
+				l := newTestSugiyamaLayout()
+				l.RankDirection = d
+				l.ClusterPadding = 10
+				if err := l.UpdateGraphLayoutE(*g); err != nil {
+					t.Fatal(err)
+				}
+				checkClusters(t, *g)
+			})
+		}
+	}
+
+	t.Run("nested clusters in same layers", func(t *testing.T) {
+		g := layout.Graph{
+			Nodes: map[uint64]layout.Node{},
+			Edges: map[layout.EdgeID]layout.Edge{},
+			Clusters: map[uint64]layout.Cluster{
+				1: {Nodes: []uint64{1}, Clusters: []uint64{2}},
+				2: {Nodes: []uint64{2, 3}},
+				3: {Nodes: []uint64{4, 5}},
+			},
+		}
+		for n := uint64(1); n <= 7; n++ {
+			g.Nodes[n] = layout.Node{W: 30, H: 20}
+		}
+		for _, e := range []layout.EdgeID{{6, 1}, {6, 2}, {6, 4}, {1, 3}, {2, 3}, {4, 7}, {5, 7}, {6, 5}, {1, 7}} {
+			g.Edges[e] = layout.Edge{}
+		}
+		l := newTestSugiyamaLayout()
+		l.ClusterPadding = 10
+		if err := l.UpdateGraphLayoutE(g); err != nil {
+			t.Fatal(err)
+		}
+		checkClusters(t, g)
+	})
+
+	t.Run("invalid clusters", func(t *testing.T) {
+		for name, clusters := range map[string]map[uint64]layout.Cluster{
+			"missing node":    {1: {Nodes: []uint64{3}}},
+			"missing cluster": {1: {Clusters: []uint64{2}}},
+			"node in two":     {1: {Nodes: []uint64{1}}, 2: {Nodes: []uint64{1}}},
+			"cluster in two":  {1: {Clusters: []uint64{3}}, 2: {Clusters: []uint64{3}}, 3: {}},
+			"nested in self":  {1: {Clusters: []uint64{2}}, 2: {Clusters: []uint64{1}}},
+		} {
+			g := layout.Graph{
+				Nodes:    map[uint64]layout.Node{1: {}, 2: {}},
+				Edges:    map[layout.EdgeID]layout.Edge{{1, 2}: {}},
+				Clusters: clusters,
+			}
+			var errCluster layout.ClusterError
+			if err := newTestSugiyamaLayout().UpdateGraphLayoutE(g); !errors.As(err, &errCluster) {
+				t.Errorf("%s: expected cluster error, got %v", name, err)
+			}
+		}
+	})
+}
@@ -1,33 +1,39 @@
 package layout
 
-import (
-	"fmt"
-)
-
 // StraightEdgePathAssigner will check node locations for each fake/real node in path and set edge path to go through middle of it.
+// Edge starts and ends at ports of real nodes.
+// Layered graph edges that are not in graph are skipped, SugiyamaLayersStrategyGraphLayout checks that edges match before.
 type StraightEdgePathAssigner struct{}
 
 func (l StraightEdgePathAssigner) UpdateGraphLayout(g Graph, lg LayeredGraph, allNodesXY map[uint64][2]int) {
-	numAssignedEdges := 0
 	for e, nodes := range lg.Edges {
-		if _, ok := g.Edges[e]; !ok {
-			panic(fmt.Errorf("layered graph edge(%v) is not found in the original graph", e))
+		edge, ok := g.Edges[e]
+		if !ok {
+			continue
 		}
+		edge.Path = edgePolyline(g, e, nodes, allNodesXY)
+		edge.Curve = nil
+		g.Edges[e] = edge
+	}
+}
 
-		path := make([][2]int, len(nodes))
-		for i, n := range nodes {
-			xy := allNodesXY[n]
-			path[i] = [2]int{
-				xy[0],
-				xy[1],
-			}
-		}
+// edgePolyline goes through centers of fake nodes and starts and ends at ports of real nodes.
+func edgePolyline(g Graph, e EdgeID, nodes []uint64, allNodesXY map[uint64][2]int) [][2]int {
+	edge := g.Edges[e]
 
-		g.Edges[e] = Edge{Path: path}
-		numAssignedEdges++
+	path := make([][2]int, len(nodes))
+	for i, n := range nodes {
+		xy := allNodesXY[n]
+		path[i] = [2]int{
+			xy[0],
+			xy[1],
+		}
 	}
 
-	if numAssignedEdges != len(g.Edges) {
-		panic(fmt.Errorf("layered graph has wrong number of edges(%d) vs graph num edges (%d)", numAssignedEdges, len(g.Edges)))
-	}
+	from := g.Nodes[e[0]].portOffset(edge.FromPort)
+	to := g.Nodes[e[1]].portOffset(edge.ToPort)
+	path[0] = [2]int{path[0][0] + from[0], path[0][1] + from[1]}
+	path[len(path)-1] = [2]int{path[len(path)-1][0] + to[0], path[len(path)-1][1] + to[1]}
+
+	return path
 }
@@ -0,0 +1,96 @@
+package layout
+
+import "math"
+
+// SplineEdgePathAssigner sets edges to smooth piecewise cubic Bezier curves through centers of fake nodes.
+// Curve leaves and enters real nodes along direction of layers and keeps direction of polyline at fake nodes.
+// Control points of each piece are within bounding box of its polyline segment,
+// so that curve stays in corridor between fake nodes and does not overshoot into neighbors in layers.
+// Tangents are monotone, as in "Monotone Piecewise Cubic Interpolation", F. N. Fritsch, R. E. Carlson, 1980.
+// Path is set to polyline, same as in StraightEdgePathAssigner.
+// Direction of layers is LayeredGraph.RankDirection.
+// Layered graph edges that are not in graph are skipped, SugiyamaLayersStrategyGraphLayout checks that edges match before.
+type SplineEdgePathAssigner struct{}
+
+func (l SplineEdgePathAssigner) UpdateGraphLayout(g Graph, lg LayeredGraph, allNodesXY map[uint64][2]int) {
+	rankAxis := 1
+	if lg.RankDirection.isHorizontal() {
+		rankAxis = 0
+	}
+
+	for e, nodes := range lg.Edges {
+		edge, ok := g.Edges[e]
+		if !ok {
+			continue
+		}
+		edge.Path = edgePolyline(g, e, nodes, allNodesXY)
+		edge.Curve = splineCurve(edge.Path, rankAxis)
+		g.Edges[e] = edge
+	}
+}
+
+// splineCurve is piecewise cubic Bezier curve through points, starting and ending along rank axis.
+// When rank axis is negative, starts and ends along first and last segments.
+// Control points are third of tangent away from points.
+func splineCurve(points [][2]int, rankAxis int) [][2]int {
+	n := len(points)
+	if n < 2 {
+		return nil
+	}
+
+	tangents := make([][2]float64, n)
+	for a := 0; a < 2; a++ {
+		for i := 0; i < n; i++ {
+			var prev, next float64
+			if i > 0 {
+				prev = float64(points[i][a] - points[i-1][a])
+			}
+			if i < n-1 {
+				next = float64(points[i+1][a] - points[i][a])
+			}
+
+			var t float64
+			switch {
+			case i == 0 && (a == rankAxis || rankAxis < 0):
+				t = next
+			case i == n-1 && (a == rankAxis || rankAxis < 0):
+				t = prev
+			case i > 0 && i < n-1 && prev*next > 0:
+				t = (prev + next) / 2
+			}
+
+			// control points within bounding boxes of adjacent segments
+			if i > 0 {
+				t = clampAbs(t, 3*math.Abs(prev))
+			}
+			if i < n-1 {
+				t = clampAbs(t, 3*math.Abs(next))
+			}
+			tangents[i][a] = t
+		}
+	}
+
+	curve := make([][2]int, 0, 1+3*(n-1))
+	curve = append(curve, points[0])
+	for i := 0; i < n-1; i++ {
+		a, b := points[i], points[i+1]
+		ta, tb := tangents[i], tangents[i+1]
+		curve = append(curve,
+			[2]int{a[0] + int(math.Round(ta[0]/3)), a[1] + int(math.Round(ta[1]/3))},
+			[2]int{b[0] - int(math.Round(tb[0]/3)), b[1] - int(math.Round(tb[1]/3))},
+			b,
+		)
+	}
+	return curve
+}
+
+func clampAbs(v, limit float64) float64 {
+	switch {
+	case v > limit:
+		return limit
+	case v < -limit:
+		return -limit
+	default:
+		return v
+	}
+}
@@ -0,0 +1,71 @@
+package layout_test
+
+import (
+	"testing"
+
+	"github.com/nikolaydubina/go-graph-layout/layout"
+)
+
+func TestSplineEdgePathAssigner(t *testing.T) {
+	for _, direction := range []layout.RankDirection{layout.TopToBottom, layout.LeftToRight} {
+		_, g, err := parseJSONLGraph(ginJSONL)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		l := newTestSugiyamaLayout()
+		l.RankDirection = direction
+		l.EdgePathAssigner = layout.SplineEdgePathAssigner{}.UpdateGraphLayout
+		if err := l.UpdateGraphLayoutE(*g); err != nil {
+			t.Fatal(err)
+		}
+
+		along := 0
+		if direction == layout.LeftToRight {
+			along = 1
+		}
+		for e, edge := range g.Edges {
+			if len(edge.Curve) != 1+3*(len(edge.Path)-1) {
+				t.Fatalf("edge %v has %d curve points for %d path points", e, len(edge.Curve), len(edge.Path))
+			}
+			// curve leaves node across layers, as layout has
+			if edge.Curve[1][along] != edge.Curve[0][along] {
+				t.Errorf("edge %v curve %v does not start along direction of layers", e, edge.Curve)
+			}
+			for i := 0; i+1 < len(edge.Path); i++ {
+				a, b := edge.Path[i], edge.Path[i+1]
+				piece := edge.Curve[3*i : 3*i+4]
+				if piece[0] != a || piece[3] != b {
+					t.Errorf("edge %v curve %v does not go through path %v", e, edge.Curve, edge.Path)
+				}
+				for _, c := range piece[1:3] {
+					if !isBetween(c[0], a[0], b[0]) || !isBetween(c[1], a[1], b[1]) {
+						t.Errorf("edge %v control point %v is outside of segment %v %v", e, c, a, b)
+					}
+				}
+			}
+		}
+	}
+}
+
+func isBetween(v, a, b int) bool {
+	if a > b {
+		a, b = b, a
+	}
+	return a <= v && v <= b
+}
+
+func TestSplineEdgePathAssignerMissingEdge(t *testing.T) {
+	g := layout.Graph{
+		Nodes: map[uint64]layout.Node{1: {W: 10, H: 10}, 2: {W: 10, H: 10}},
+		Edges: map[layout.EdgeID]layout.Edge{},
+	}
+	lg := layout.LayeredGraph{
+		NodeYX: map[uint64][2]int{1: {0, 0}, 2: {1, 0}},
+		Edges:  map[layout.EdgeID][]uint64{{1, 2}: {1, 2}},
+	}
+	layout.SplineEdgePathAssigner{}.UpdateGraphLayout(g, lg, map[uint64][2]int{1: {0, 0}, 2: {0, 50}})
+	if len(g.Edges) != 0 {
+		t.Errorf("expected missing edge to be skipped, got %v", g.Edges)
+	}
+}
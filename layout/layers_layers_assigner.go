@@ -1,22 +1,31 @@
 package layout
 
-import "fmt"
-
 // Expects that graph g does not have cycles.
 // This step creates fake nodes and splits long edges into segments.
 func NewLayeredGraph(g Graph) LayeredGraph {
-	nodeYX := assignLevels(g)
+	return newLayeredGraph(g, assignLevels(g))
+}
+
+// newLayeredGraph makes layered graph from layers of real nodes.
+func newLayeredGraph(g Graph, nodeYX map[uint64][2]int) LayeredGraph {
 	edges := makeEdges(g, nodeYX)
-	return LayeredGraph{
+	lg := LayeredGraph{
 		NodeYX:   nodeYX,
-		Segments: makeSegments(edges),
+		Segments: makeSegments(edges, nodeYX),
 		Dummy:    makeDummy(edges),
 		Edges:    edges,
 	}
+	lg.Reindex()
+	return lg
 }
 
 func maxNodeID(g Graph) uint64 {
 	var maxNodeID uint64
+	for n := range g.Nodes {
+		if n > maxNodeID {
+			maxNodeID = n
+		}
+	}
 	for e := range g.Edges {
 		if e[0] > maxNodeID {
 			maxNodeID = e[0]
@@ -31,39 +40,47 @@ func maxNodeID(g Graph) uint64 {
 func assignLevels(g Graph) map[uint64][2]int {
 	nodeYX := make(map[uint64][2]int, len(g.Nodes))
 	neighbors := make(map[uint64][]uint64)
+	indegree := make(map[uint64]int, len(g.Nodes))
 	for e := range g.Edges {
-		neighbors[e[0]] = append(neighbors[e[0]], e[1])
+		if !e.isSelfLoop() {
+			neighbors[e[0]] = append(neighbors[e[0]], e[1])
+			indegree[e[1]]++
+		}
 	}
-	for _, root := range g.Roots() {
+
+	// in topological order, so each node is visited once after all its parents
+	que := g.Roots()
+	for _, root := range que {
 		nodeYX[root] = [2]int{0, 0}
-		for que := []uint64{root}; len(que) > 0; {
-			// pop
-			p := que[0]
-			if len(que) > 1 {
-				que = que[1:]
-			} else {
-				que = nil
-			}
+	}
+	for ; len(que) > 0; que = que[1:] {
+		p := que[0]
 
-			// set max depth for each child
-			for _, child := range neighbors[p] {
-					if l := nodeYX[p][0] + 1; l > nodeYX[child][0] {
-						nodeYX[child] = [2]int{l, 0}
-					}
-					que = append(que, child)
+		// set max depth for each child
+		for _, child := range neighbors[p] {
+			if l := nodeYX[p][0] + 1; l > nodeYX[child][0] {
+				nodeYX[child] = [2]int{l, 0}
+			}
+			indegree[child]--
+			if indegree[child] == 0 {
+				que = append(que, child)
 			}
 		}
 	}
 	return nodeYX
 }
 
-// for each long edge breaks it down to multiple segments, for short edge just adds it
-func makeSegments(edges map[[2]uint64][]uint64) map[[2]uint64]bool {
+// for each long edge breaks it down to multiple segments, for short edge just adds it.
+// Edges with less than two nodes are skipped, this is reported by LayeredGraph.Validate.
+// Flat edges between nodes in same layer are not segments.
+// Parallel short edges are same segment.
+func makeSegments(edges map[EdgeID][]uint64, nodeYX map[uint64][2]int) map[[2]uint64]bool {
 	segments := map[[2]uint64]bool{}
 	for e, nodes := range edges {
 		switch {
+		case len(nodes) == 2 && nodeYX[e[0]][0] == nodeYX[e[1]][0]:
 		case len(nodes) == 2:
-			segments[e] = true
+			segments[[2]uint64{e[0], e[1]}] = true
 		case len(nodes) > 2:
 			for i := range nodes {
 				if i == 0 {
@@ -71,15 +88,13 @@ func makeSegments(edges map[[2]uint64][]uint64) map[[2]uint64]bool {
 				}
 				segments[[2]uint64{nodes[i-1], nodes[i]}] = true
 			}
-		default:
-			panic(fmt.Errorf("edge(%v) has only one node(%v) but at least 2 expected", e, nodes))
 		}
 	}
 	return segments
 }
 
 // extracts all fake nodes for edges that are long into separate map
-func makeDummy(edges map[[2]uint64][]uint64) map[uint64]bool {
+func makeDummy(edges map[EdgeID][]uint64) map[uint64]bool {
 	dummy := map[uint64]bool{}
 	for _, nodes := range edges {
 		if len(nodes) > 2 {
@@ -96,11 +111,15 @@ func makeDummy(edges map[[2]uint64][]uint64) map[uint64]bool {
 
 // makeEdges split long edges into segments and add fake nodes
 // adds new fake nodes to nodeYX
-func makeEdges(g Graph, nodeYX map[uint64][2]int) map[[2]uint64][]uint64 {
-	edges := make(map[[2]uint64][]uint64, len(g.Edges))
+// self loops are skipped, they are drawn by layouts separately
+func makeEdges(g Graph, nodeYX map[uint64][2]int) map[EdgeID][]uint64 {
+	edges := make(map[EdgeID][]uint64, len(g.Edges))
 
 	nextFakeNodeID := maxNodeID(g) + 1
-	for e := range g.Edges {
+	for _, e := range g.edgeIDs() {
+		if e.isSelfLoop() {
+			continue
+		}
 		fromLayer := nodeYX[e[0]][0]
 		toLayer := nodeYX[e[1]][0]
 
@@ -0,0 +1,193 @@
+package layout
+
+import "sort"
+
+// CoffmanGrahamLayersAssigner assigns layers such that each layer is not wider than given limit.
+// Width is counted in number of real nodes or in sum of widths of real nodes.
+// Fake nodes are not counted, so final layers may contain more nodes.
+// Narrower limit makes more layers, trading height of drawing for width.
+// "Optimal scheduling for two-processor systems", E. G. Coffman, R. L. Graham, 1972
+// Ch.13.2.4 in "Handbook of Graph Drawing and Visualization", 2013.
+// Expects that graph g does not have cycles.
+type CoffmanGrahamLayersAssigner struct {
+	MaxWidth   int  // maximum width of layer, zero means no limit
+	NodesWidth bool // true = sum of Node.W, false = number of nodes
+}
+
+// AssignLevels makes layered graph with Coffman-Graham layering.
+func (a CoffmanGrahamLayersAssigner) AssignLevels(g Graph) LayeredGraph {
+	nodes := make([]uint64, 0, len(g.Nodes))
+	for n := range g.Nodes {
+		nodes = append(nodes, n)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i] < nodes[j] })
+
+	up, down := transitiveReduction(g, nodes)
+	label := coffmanGrahamLabels(nodes, up)
+
+	// fill layers bottom up, picking node with highest label which all successors are in lower layers
+	layer := make(map[uint64]int, len(nodes))
+	numLayers := 0
+	width := 0
+	for len(layer) < len(nodes) {
+		var best uint64
+		found := false
+		for _, n := range nodes {
+			if _, ok := layer[n]; ok {
+				continue
+			}
+			if !isBelowLayer(layer, down[n], numLayers) {
+				continue
+			}
+			if !found || label[n] > label[best] {
+				best = n
+				found = true
+			}
+		}
+
+		switch {
+		case found && (width == 0 || a.MaxWidth <= 0 || (width+a.nodeWidth(g, best)) <= a.MaxWidth):
+			layer[best] = numLayers
+			width += a.nodeWidth(g, best)
+		case width > 0:
+			numLayers++
+			width = 0
+		default:
+			// cycle, place remaining nodes in order of labels
+			for _, n := range nodes {
+				if _, ok := layer[n]; !ok {
+					layer[n] = numLayers
+				}
+			}
+		}
+	}
+
+	nodeYX := make(map[uint64][2]int, len(nodes))
+	for n, l := range layer {
+		nodeYX[n] = [2]int{numLayers - l, 0}
+	}
+	return newLayeredGraph(g, nodeYX)
+}
+
+func (a CoffmanGrahamLayersAssigner) nodeWidth(g Graph, n uint64) int {
+	if a.NodesWidth {
+		return g.Nodes[n].W
+	}
+	return 1
+}
+
+// isBelowLayer tells when all nodes are assigned and are strictly bellow current layer.
+func isBelowLayer(layer map[uint64]int, nodes []uint64, current int) bool {
+	for _, n := range nodes {
+		if l, ok := layer[n]; !ok || l >= current {
+			return false
+		}
+	}
+	return true
+}
+
+// coffmanGrahamLabels orders nodes such that node with lexicographically smallest
+// decreasing sequence of labels of its predecessors gets next label.
+func coffmanGrahamLabels(nodes []uint64, up map[uint64][]uint64) map[uint64]int {
+	label := make(map[uint64]int, len(nodes))
+
+	// predecessors labels, sorted decreasing
+	key := func(n uint64) []int {
+		ls := make([]int, 0, len(up[n]))
+		for _, p := range up[n] {
+			ls = append(ls, label[p])
+		}
+		sort.Sort(sort.Reverse(sort.IntSlice(ls)))
+		return ls
+	}
+
+	for next := 1; next <= len(nodes); next++ {
+		var best uint64
+		var bestKey []int
+		found := false
+		for _, n := range nodes {
+			if _, ok := label[n]; ok {
+				continue
+			}
+			ready := true
+			for _, p := range up[n] {
+				if _, ok := label[p]; !ok {
+					ready = false
+					break
+				}
+			}
+			if !ready {
+				continue
+			}
+			if k := key(n); !found || isLexLess(k, bestKey) {
+				best, bestKey, found = n, k, true
+			}
+		}
+
+		if !found {
+			// cycle, label remaining nodes in order
+			for _, n := range nodes {
+				if _, ok := label[n]; !ok {
+					best = n
+					break
+				}
+			}
+		}
+		label[best] = next
+	}
+
+	return label
+}
+
+func isLexLess(a, b []int) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+	return len(a) < len(b)
+}
+
+// transitiveReduction removes edges that are implied by longer paths.
+// Returns upper and lower neighbors for each node.
+// time: O(V * E)
+func transitiveReduction(g Graph, nodes []uint64) (up, down map[uint64][]uint64) {
+	children := make(map[uint64][]uint64, len(nodes))
+	for e := range g.Edges {
+		if !e.isSelfLoop() {
+			children[e[0]] = append(children[e[0]], e[1])
+		}
+	}
+	// parallel edges are same
+	for n, c := range children {
+		sort.Slice(c, func(i, j int) bool { return c[i] < c[j] })
+		children[n] = uniqueSorted(c)
+	}
+
+	up = make(map[uint64][]uint64, len(nodes))
+	down = make(map[uint64][]uint64, len(nodes))
+	for _, n := range nodes {
+		// nodes reachable from children by paths of at least one edge
+		reachable := map[uint64]bool{}
+		var que []uint64
+		for _, c := range children[n] {
+			que = append(que, children[c]...)
+		}
+		for ; len(que) > 0; que = que[1:] {
+			p := que[0]
+			if reachable[p] || p == n {
+				continue
+			}
+			reachable[p] = true
+			que = append(que, children[p]...)
+		}
+
+		for _, c := range children[n] {
+			if !reachable[c] {
+				down[n] = append(down[n], c)
+				up[c] = append(up[c], n)
+			}
+		}
+	}
+	return up, down
+}
@@ -0,0 +1,85 @@
+package layout_test
+
+import (
+	"testing"
+
+	"github.com/nikolaydubina/go-graph-layout/layout"
+)
+
+func realNodesLayersWidth(g layout.Graph, lg layout.LayeredGraph, nodesWidth bool) []int {
+	var widths []int
+	for n, yx := range lg.NodeYX {
+		if lg.Dummy[n] {
+			continue
+		}
+		for len(widths) <= yx[0] {
+			widths = append(widths, 0)
+		}
+		if nodesWidth {
+			widths[yx[0]] += g.Nodes[n].W
+		} else {
+			widths[yx[0]]++
+		}
+	}
+	return widths
+}
+
+func TestCoffmanGrahamLayersAssigner(t *testing.T) {
+	g := layout.Graph{
+		Nodes: map[uint64]layout.Node{},
+		Edges: map[layout.EdgeID]layout.Edge{},
+	}
+	g.Nodes[0] = layout.Node{W: 10}
+	for i := uint64(1); i <= 10; i++ {
+		g.Nodes[i] = layout.Node{W: int(i) * 10}
+		g.Edges[layout.EdgeID{0, i}] = layout.Edge{}
+	}
+	g.Nodes[11] = layout.Node{W: 10}
+	g.Edges[layout.EdgeID{1, 11}] = layout.Edge{}
+	g.Edges[layout.EdgeID{0, 11}] = layout.Edge{}
+
+	tests := []struct {
+		name       string
+		assigner   layout.CoffmanGrahamLayersAssigner
+		maxWidth   int
+		nodesWidth bool
+		numLayers  int
+	}{
+		{
+			name:      "no limit",
+			assigner:  layout.CoffmanGrahamLayersAssigner{},
+			maxWidth:  10,
+			numLayers: 3,
+		},
+		{
+			name:      "nodes",
+			assigner:  layout.CoffmanGrahamLayersAssigner{MaxWidth: 3},
+			maxWidth:  3,
+			numLayers: 5,
+		},
+		{
+			name:       "width",
+			assigner:   layout.CoffmanGrahamLayersAssigner{MaxWidth: 150, NodesWidth: true},
+			maxWidth:   150,
+			nodesWidth: true,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			lg := tc.assigner.AssignLevels(g)
+			if err := lg.Validate(); err != nil {
+				t.Error(err)
+			}
+
+			widths := realNodesLayersWidth(g, lg, tc.nodesWidth)
+			for l, w := range widths {
+				if w > tc.maxWidth {
+					t.Errorf("layer %d has width %d but limit is %d", l, w, tc.maxWidth)
+				}
+			}
+			if tc.numLayers > 0 && len(widths) != tc.numLayers {
+				t.Errorf("expected %d layers, got %d", tc.numLayers, len(widths))
+			}
+		})
+	}
+}
@@ -0,0 +1,511 @@
+package layout
+
+import (
+	"math"
+	"sort"
+)
+
+// NetworkSimplexLayersAssigner assigns layers such that total weighted length of edges is minimal,
+// while each edge spans at least its minimal length.
+// Layering is found by network simplex on each connected component.
+// Edge weight and minimal length are taken from Edge.Weight and Edge.MinLen.
+// This is used in Graphviz/dot, Section 2 in Graphviz dot paper TSE93.
+// Expects that graph g does not have cycles.
+type NetworkSimplexLayersAssigner struct {
+	MaxIterations int  // limit of pivots per connected component, zero means no limit
+	Balance       bool // move nodes with equal in and out weights to feasible layers with fewer nodes
+}
+
+// AssignLevels makes layered graph with network simplex layering.
+func (a NetworkSimplexLayersAssigner) AssignLevels(g Graph) LayeredGraph {
+	nodeYX := make(map[uint64][2]int, len(g.Nodes))
+	for _, c := range newSimplexComponents(g) {
+		c.initRank()
+		if !c.isRanked() {
+			// has cycles, keeping initial layers so that validation fails later
+			c.storeRanks(nodeYX)
+			continue
+		}
+		c.feasibleTree()
+		c.optimize(a.MaxIterations)
+		c.normalize()
+		if a.Balance {
+			c.balance()
+		}
+		c.storeRanks(nodeYX)
+	}
+	return newLayeredGraph(g, nodeYX)
+}
+
+type simplexEdge struct {
+	from, to int
+	weight   int
+	minLen   int
+	tree     bool
+	cutValue int
+}
+
+// simplexGraph is single connected component with nodes and edges indexed in deterministic order.
+type simplexGraph struct {
+	nodes []uint64
+	edges []simplexEdge
+	out   [][]int // node -> outgoing edges
+	in    [][]int // node -> incoming edges
+	rank  []int
+	low   []int
+	lim   []int
+	par   []int // node -> tree edge to parent, -1 for root
+}
+
+// newSimplexComponents splits graph into weakly connected components.
+func newSimplexComponents(g Graph) []*simplexGraph {
+	ids := make([]uint64, 0, len(g.Nodes))
+	for id := range g.Nodes {
+		ids = append(ids, id)
+	}
+	for e := range g.Edges {
+		if _, ok := g.Nodes[e[0]]; !ok {
+			ids = append(ids, e[0])
+		}
+		if _, ok := g.Nodes[e[1]]; !ok {
+			ids = append(ids, e[1])
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	// self loops do not change layers
+	edges := make([]EdgeID, 0, len(g.Edges))
+	for _, e := range g.edgeIDs() {
+		if !e.isSelfLoop() {
+			edges = append(edges, e)
+		}
+	}
+
+	neighbors := make(map[uint64][]uint64, len(ids))
+	for _, e := range edges {
+		neighbors[e[0]] = append(neighbors[e[0]], e[1])
+		neighbors[e[1]] = append(neighbors[e[1]], e[0])
+	}
+
+	component := make(map[uint64]int, len(ids))
+	var components []*simplexGraph
+	for _, id := range ids {
+		if _, ok := component[id]; ok {
+			continue
+		}
+		c := &simplexGraph{}
+		component[id] = len(components)
+		for que := []uint64{id}; len(que) > 0; {
+			p := que[0]
+			que = que[1:]
+			c.nodes = append(c.nodes, p)
+			for _, n := range neighbors[p] {
+				if _, ok := component[n]; !ok {
+					component[n] = len(components)
+					que = append(que, n)
+				}
+			}
+		}
+		components = append(components, c)
+	}
+
+	for _, c := range components {
+		sort.Slice(c.nodes, func(i, j int) bool { return c.nodes[i] < c.nodes[j] })
+	}
+
+	idx := make(map[uint64]int, len(ids))
+	for _, c := range components {
+		for i, id := range c.nodes {
+			idx[id] = i
+		}
+		c.out = make([][]int, len(c.nodes))
+		c.in = make([][]int, len(c.nodes))
+		c.rank = make([]int, len(c.nodes))
+		c.low = make([]int, len(c.nodes))
+		c.lim = make([]int, len(c.nodes))
+		c.par = make([]int, len(c.nodes))
+	}
+
+	for _, e := range edges {
+		c := components[component[e[0]]]
+		edge := g.Edges[e]
+		se := simplexEdge{
+			from:   idx[e[0]],
+			to:     idx[e[1]],
+			weight: edge.Weight,
+			minLen: edge.MinLen,
+		}
+		if se.weight == 0 {
+			se.weight = 1
+		}
+		if se.minLen == 0 {
+			se.minLen = 1
+		}
+		c.out[se.from] = append(c.out[se.from], len(c.edges))
+		c.in[se.to] = append(c.in[se.to], len(c.edges))
+		c.edges = append(c.edges, se)
+	}
+
+	return components
+}
+
+func (c *simplexGraph) slack(e int) int {
+	return c.rank[c.edges[e].to] - c.rank[c.edges[e].from] - c.edges[e].minLen
+}
+
+// initRank sets initial feasible ranking by topological traversal.
+// Nodes that are part of cycles are left with rank -1.
+func (c *simplexGraph) initRank() {
+	indegree := make([]int, len(c.nodes))
+	for i := range c.nodes {
+		indegree[i] = len(c.in[i])
+		c.rank[i] = -1
+	}
+
+	var que []int
+	for i := range c.nodes {
+		if indegree[i] == 0 {
+			que = append(que, i)
+			c.rank[i] = 0
+		}
+	}
+
+	for ; len(que) > 0; que = que[1:] {
+		p := que[0]
+		for _, e := range c.out[p] {
+			to := c.edges[e].to
+			if r := c.rank[p] + c.edges[e].minLen; r > c.rank[to] {
+				c.rank[to] = r
+			}
+			indegree[to]--
+			if indegree[to] == 0 {
+				que = append(que, to)
+			}
+		}
+	}
+}
+
+func (c *simplexGraph) isRanked() bool {
+	for _, r := range c.rank {
+		if r < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// tightTree marks maximal tree of tight edges reachable from first node.
+// Returns nodes in tree.
+func (c *simplexGraph) tightTree() []bool {
+	for e := range c.edges {
+		c.edges[e].tree = false
+	}
+
+	inTree := make([]bool, len(c.nodes))
+	inTree[0] = true
+	for que := []int{0}; len(que) > 0; que = que[1:] {
+		p := que[0]
+		for _, es := range [][]int{c.out[p], c.in[p]} {
+			for _, e := range es {
+				other := c.edges[e].to
+				if other == p {
+					other = c.edges[e].from
+				}
+				if !inTree[other] && c.slack(e) == 0 {
+					inTree[other] = true
+					c.edges[e].tree = true
+					que = append(que, other)
+				}
+			}
+		}
+	}
+	return inTree
+}
+
+// feasibleTree makes spanning tree of tight edges by shifting ranks of tree nodes.
+// Figure 2-2 in Graphviz dot paper TSE93.
+func (c *simplexGraph) feasibleTree() {
+	for {
+		inTree := c.tightTree()
+
+		numInTree := 0
+		for _, ok := range inTree {
+			if ok {
+				numInTree++
+			}
+		}
+		if numInTree == len(c.nodes) {
+			return
+		}
+
+		// non-tree edge incident on the tree with minimal slack
+		best := -1
+		for e, edge := range c.edges {
+			if inTree[edge.from] != inTree[edge.to] && (best < 0 || c.slack(e) < c.slack(best)) {
+				best = e
+			}
+		}
+
+		delta := c.slack(best)
+		if inTree[c.edges[best].to] {
+			delta = -delta
+		}
+		for i, ok := range inTree {
+			if ok {
+				c.rank[i] += delta
+			}
+		}
+	}
+}
+
+// postorder assigns low and lim numbers and parent edges so that
+// node w is in subtree of node v when low[v] <= lim[w] <= lim[v].
+func (c *simplexGraph) postorder() []int {
+	treeEdges := make([][]int, len(c.nodes))
+	for e, edge := range c.edges {
+		if edge.tree {
+			treeEdges[edge.from] = append(treeEdges[edge.from], e)
+			treeEdges[edge.to] = append(treeEdges[edge.to], e)
+		}
+	}
+
+	for i := range c.par {
+		c.par[i] = -1
+	}
+
+	// iterative DFS to avoid deep recursion on long chains
+	order := make([]int, 0, len(c.nodes))
+	visited := make([]bool, len(c.nodes))
+	next := make([]int, len(c.nodes))
+	stack := []int{0}
+	visited[0] = true
+	c.low[0] = 1
+	for len(stack) > 0 {
+		v := stack[len(stack)-1]
+		if next[v] < len(treeEdges[v]) {
+			e := treeEdges[v][next[v]]
+			next[v]++
+			w := c.edges[e].to
+			if w == v {
+				w = c.edges[e].from
+			}
+			if !visited[w] {
+				visited[w] = true
+				c.par[w] = e
+				c.low[w] = len(order) + 1
+				stack = append(stack, w)
+			}
+			continue
+		}
+		stack = stack[:len(stack)-1]
+		order = append(order, v)
+		c.lim[v] = len(order)
+	}
+	return order
+}
+
+func (c *simplexGraph) inSubtree(v, w int) bool {
+	return c.low[v] <= c.lim[w] && c.lim[w] <= c.lim[v]
+}
+
+// cutValues computes cut values of all tree edges bottom up.
+// Cut value is sum of weights of edges from tail component to head component,
+// minus sum of weights of edges from head component to tail component,
+// where components are made by removing tree edge from tree.
+func (c *simplexGraph) cutValues(order []int) {
+	for _, v := range order {
+		f := c.par[v]
+		if f < 0 {
+			continue
+		}
+
+		// v is tail of parent edge when direction is from subtree to rest of tree
+		dir := 1
+		if c.edges[f].to == v {
+			dir = -1
+		}
+
+		sum := 0
+		for _, es := range [][]int{c.out[v], c.in[v]} {
+			for _, e := range es {
+				sum += c.cutValueContribution(e, v, dir)
+			}
+		}
+		c.edges[f].cutValue = sum
+	}
+}
+
+func (c *simplexGraph) cutValueContribution(e int, v int, dir int) int {
+	edge := c.edges[e]
+	other := edge.to
+	if other == v {
+		other = edge.from
+	}
+
+	outside := !c.inSubtree(v, other)
+
+	var rv int
+	if outside {
+		rv = edge.weight
+	} else {
+		if edge.tree {
+			rv = edge.cutValue
+		}
+		rv -= edge.weight
+	}
+
+	var d int
+	if dir > 0 {
+		if edge.to == v {
+			d = 1
+		} else {
+			d = -1
+		}
+	} else {
+		if edge.from == v {
+			d = 1
+		} else {
+			d = -1
+		}
+	}
+	if outside {
+		d = -d
+	}
+	if d < 0 {
+		rv = -rv
+	}
+	return rv
+}
+
+// optimize replaces tree edges with negative cut values by non-tree edges until optimal.
+// Figure 2-1 in Graphviz dot paper TSE93.
+func (c *simplexGraph) optimize(maxIterations int) {
+	order := c.postorder()
+	c.cutValues(order)
+
+	for iter := 0; maxIterations <= 0 || iter < maxIterations; iter++ {
+		leave := -1
+		for e, edge := range c.edges {
+			if edge.tree && edge.cutValue < 0 {
+				leave = e
+				break
+			}
+		}
+		if leave < 0 {
+			return
+		}
+
+		// subtree root below leaving edge
+		v := c.edges[leave].to
+		tailInSubtree := false
+		if c.lim[c.edges[leave].from] < c.lim[c.edges[leave].to] {
+			v = c.edges[leave].from
+			tailInSubtree = true
+		}
+
+		// entering edge goes from head component to tail component with minimal slack
+		enter := -1
+		for e, edge := range c.edges {
+			if edge.tree {
+				continue
+			}
+			if c.inSubtree(v, edge.to) == tailInSubtree && c.inSubtree(v, edge.from) != tailInSubtree {
+				if enter < 0 || c.slack(e) < c.slack(enter) {
+					enter = e
+				}
+			}
+		}
+		if enter < 0 {
+			return
+		}
+
+		if delta := c.slack(enter); delta > 0 {
+			if tailInSubtree {
+				delta = -delta
+			}
+			for w := range c.nodes {
+				if c.inSubtree(v, w) {
+					c.rank[w] += delta
+				}
+			}
+		}
+
+		c.edges[leave].tree = false
+		c.edges[enter].tree = true
+		order = c.postorder()
+		c.cutValues(order)
+	}
+}
+
+// normalize makes lowest rank zero.
+func (c *simplexGraph) normalize() {
+	minRank := math.MaxInt
+	for _, r := range c.rank {
+		if r < minRank {
+			minRank = r
+		}
+	}
+	for i := range c.rank {
+		c.rank[i] -= minRank
+	}
+}
+
+// balance moves nodes with equal in and out weights to feasible rank with fewest nodes.
+// This reduces crowding of layers without changing total edge length.
+func (c *simplexGraph) balance() {
+	maxRank := 0
+	for _, r := range c.rank {
+		if r > maxRank {
+			maxRank = r
+		}
+	}
+
+	count := make([]int, maxRank+1)
+	for _, r := range c.rank {
+		count[r]++
+	}
+
+	for v := range c.nodes {
+		if len(c.in[v]) == 0 && len(c.out[v]) == 0 {
+			continue
+		}
+
+		weightIn, weightOut := 0, 0
+		low, high := 0, maxRank
+		for _, e := range c.in[v] {
+			weightIn += c.edges[e].weight
+			if r := c.rank[c.edges[e].from] + c.edges[e].minLen; r > low {
+				low = r
+			}
+		}
+		for _, e := range c.out[v] {
+			weightOut += c.edges[e].weight
+			if r := c.rank[c.edges[e].to] - c.edges[e].minLen; r < high {
+				high = r
+			}
+		}
+		if weightIn != weightOut {
+			continue
+		}
+
+		best := c.rank[v]
+		for r := low; r <= high; r++ {
+			if count[r] < count[best] {
+				best = r
+			}
+		}
+		count[c.rank[v]]--
+		count[best]++
+		c.rank[v] = best
+	}
+}
+
+func (c *simplexGraph) storeRanks(nodeYX map[uint64][2]int) {
+	for i, id := range c.nodes {
+		r := c.rank[i]
+		if r < 0 {
+			r = 0
+		}
+		nodeYX[id] = [2]int{r, 0}
+	}
+}
@@ -0,0 +1,67 @@
+package layout_test
+
+import (
+	"testing"
+
+	"github.com/nikolaydubina/go-graph-layout/layout"
+)
+
+func totalEdgesSpan(g layout.Graph, lg layout.LayeredGraph) int {
+	span := 0
+	for e := range g.Edges {
+		span += lg.NodeYX[e[1]][0] - lg.NodeYX[e[0]][0]
+	}
+	return span
+}
+
+func TestNetworkSimplexLayersAssigner(t *testing.T) {
+	t.Run("shorter than longest path", func(t *testing.T) {
+		g := layout.Graph{
+			Nodes: map[uint64]layout.Node{1: {}, 2: {}, 3: {}, 4: {}, 5: {}},
+			Edges: map[layout.EdgeID]layout.Edge{
+				{1, 2}: {},
+				{2, 3}: {},
+				{3, 4}: {},
+				{5, 4}: {},
+			},
+		}
+
+		lg := layout.NetworkSimplexLayersAssigner{}.AssignLevels(g)
+		if err := lg.Validate(); err != nil {
+			t.Error(err)
+		}
+		if span := totalEdgesSpan(g, lg); span != 4 {
+			t.Errorf("expected span 4, got %d: %v", span, lg.NodeYX)
+		}
+		if span := totalEdgesSpan(g, layout.NewLayeredGraph(g)); span != 6 {
+			t.Errorf("expected longest path span 6, got %d", span)
+		}
+	})
+
+	t.Run("weight and min length", func(t *testing.T) {
+		g := layout.Graph{
+			Nodes: map[uint64]layout.Node{1: {}, 2: {}, 3: {}, 4: {}},
+			Edges: map[layout.EdgeID]layout.Edge{
+				{1, 2}: {MinLen: 2},
+				{2, 4}: {},
+				{1, 3}: {},
+				{3, 4}: {Weight: 10},
+			},
+		}
+
+		lg := layout.NetworkSimplexLayersAssigner{}.AssignLevels(g)
+		if err := lg.Validate(); err != nil {
+			t.Error(err)
+		}
+
+		expected := map[uint64]int{1: 0, 2: 2, 3: 2, 4: 3}
+		for n, y := range expected {
+			if lg.NodeYX[n][0] != y {
+				t.Errorf("node %d expected layer %d, got %d", n, y, lg.NodeYX[n][0])
+			}
+		}
+		if len(lg.Dummy) != 2 {
+			t.Errorf("expected 2 dummy nodes, got %d", len(lg.Dummy))
+		}
+	})
+}
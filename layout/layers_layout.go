@@ -1,5 +1,11 @@
 package layout
 
+import (
+	"context"
+	"math"
+	"time"
+)
+
 type CycleRemover interface {
 	RemoveCycles(g Graph)
 	Restore(g Graph)
@@ -13,47 +19,263 @@ type NodesVerticalCoordinatesAssigner interface {
 	NodesVerticalCoordinates(g Graph, lg LayeredGraph) map[uint64]int
 }
 
+// RankDirection is direction in which layers follow each other.
+type RankDirection uint8
+
+const (
+	TopToBottom RankDirection = iota
+	BottomToTop
+	LeftToRight
+	RightToLeft
+)
+
+// isHorizontal is when layers follow each other left to right or right to left.
+func (d RankDirection) isHorizontal() bool {
+	return d == LeftToRight || d == RightToLeft
+}
+
+// transform moves coordinates computed for top to bottom layers to direction.
+// Sizes are sizes of boxes around nodes in direction, nodes without size are points.
+func (d RankDirection) transform(nodesXY map[uint64][2]int, sizes map[uint64][2]int) {
+	if d.isHorizontal() {
+		for n, xy := range nodesXY {
+			nodesXY[n] = [2]int{xy[1], xy[0]}
+		}
+	}
+
+	switch d {
+	case BottomToTop:
+		mirrorAxis(nodesXY, sizes, 1)
+	case RightToLeft:
+		mirrorAxis(nodesXY, sizes, 0)
+	}
+}
+
+// mirrorAxis reverses centers of boxes along axis, keeping boxes within same range.
+func mirrorAxis(nodesXY map[uint64][2]int, sizes map[uint64][2]int, axis int) {
+	if len(nodesXY) == 0 {
+		return
+	}
+	minV, maxV := math.MaxInt, math.MinInt
+	for n, xy := range nodesXY {
+		minV = minInt(minV, xy[axis]-sizes[n][axis]/2)
+		maxV = maxInt(maxV, xy[axis]-sizes[n][axis]/2+sizes[n][axis])
+	}
+	for n, xy := range nodesXY {
+		// corner of box at other end of range, box of odd size keeps center rounding of corner
+		xy[axis] = minV + maxV - (xy[axis] - sizes[n][axis]/2 + sizes[n][axis]) + sizes[n][axis]/2
+		nodesXY[n] = xy
+	}
+}
+
 // Kozo Sugiyama algorithm breaks down layered graph construction in phases.
+// Ports of edges are passed to phases in LayeredGraph.Ports.
+// Labels of edges are placed next to fake nodes in LayeredGraph.Labels.
+// Clusters are kept together in each layer between fake nodes of their borders in LayeredGraph.Borders.
+// Ordering assigner keeps order constraints of flat edges and clusters, as WarfieldOrderingOptimizer does.
+// Nodes of rank constraints are merged for CycleRemover and LevelsAssigner, so that any levels assigner keeps them in same layers.
+// Flat edges between nodes in same layer go from left to right, and around layer when there are nodes between their ends.
+// Coordinates are assigned as if layers go top to bottom.
+// For horizontal rank directions width and height of nodes are swapped when assigning coordinates,
+// so that boxes of nodes and edges are correct in final layout.
 type SugiyamaLayersStrategyGraphLayout struct {
 	CycleRemover                       CycleRemover
 	LevelsAssigner                     func(g Graph) LayeredGraph
 	OrderingAssigner                   func(g Graph, lg LayeredGraph)
+	OrderingAssignerContext            func(ctx context.Context, g Graph, lg LayeredGraph) // when set, used instead of OrderingAssigner
 	NodesHorizontalCoordinatesAssigner NodesHorizontalCoordinatesAssigner
 	NodesVerticalCoordinatesAssigner   NodesVerticalCoordinatesAssigner
 	EdgePathAssigner                   func(g Graph, lg LayeredGraph, allNodesXY map[uint64][2]int)
+	Observer                           Observer // receives duration of each phase
+	RankDirection                      RankDirection
+	ClusterPadding                     int // space between boxes of clusters and their nodes across layers, along layers it is distance between nodes of horizontal assigner
 }
 
 // UpdateGraphLayout breaks down layered graph construction in phases.
+// Edges to missing nodes are skipped and kept as they are.
+// Panics on other errors, that UpdateGraphLayoutE returns.
 func (l SugiyamaLayersStrategyGraphLayout) UpdateGraphLayout(g Graph) {
-	l.CycleRemover.RemoveCycles(g)
+	missing := make(map[EdgeID]Edge)
+	for e, edge := range g.Edges {
+		_, from := g.Nodes[e[0]]
+		_, to := g.Nodes[e[1]]
+		if !from || !to {
+			missing[e] = edge
+			delete(g.Edges, e)
+		}
+	}
+	defer func() {
+		for e, edge := range missing {
+			g.Edges[e] = edge
+		}
+	}()
 
-	lg := l.LevelsAssigner(g)
-	if err := lg.Validate(); err != nil {
+	if err := l.UpdateGraphLayoutE(g); err != nil {
 		panic(err)
 	}
+}
+
+// UpdateGraphLayoutE breaks down layered graph construction in phases.
+// Returns error when graph is invalid or when phases produce inconsistent layered graph.
+func (l SugiyamaLayersStrategyGraphLayout) UpdateGraphLayoutE(g Graph) error {
+	return l.UpdateGraphLayoutContext(context.Background(), g)
+}
+
+// UpdateGraphLayoutContext breaks down layered graph construction in phases.
+// Context is passed to OrderingAssignerContext, which keeps best ordering found so far when context is done.
+// Other phases are completed regardless of context, so that graph has complete layout.
+func (l SugiyamaLayersStrategyGraphLayout) UpdateGraphLayoutContext(ctx context.Context, g Graph) error {
+	if err := g.Validate(); err != nil {
+		return err
+	}
+
+	// self loops are not in layers, they are drawn at side of their nodes that is along layers
+	loops := make(map[EdgeID]Edge)
+	for e, edge := range g.Edges {
+		if e.isSelfLoop() {
+			loops[e] = edge
+			delete(g.Edges, e)
+		}
+	}
+	defer func() {
+		for e, edge := range loops {
+			g.Edges[e] = edge
+		}
+		if l.RankDirection.isHorizontal() {
+			selfLoops(g, SideBottom, true)
+		} else {
+			selfLoops(g, SideRight, true)
+		}
+	}()
+
+	// with rank constraints cycles are removed as if nodes of each group are merged, flat edges can make cycles
+	start := time.Now()
+	if len(g.Ranks) > 0 {
+		reversed := removeRankCycles(g, l.CycleRemover)
+		defer func() {
+			for e, rev := range reversed {
+				reverseEdge(g, rev, e)
+			}
+		}()
+	} else {
+		l.CycleRemover.RemoveCycles(g)
+		defer l.CycleRemover.Restore(g)
+	}
+	observe(l.Observer, Event{Phase: PhaseCycleRemoval, Elapsed: time.Since(start)})
 
-	l.OrderingAssigner(g, lg)
+	if nodes := rankCycleNodes(g); len(nodes) > 0 {
+		return CycleError{Nodes: nodes}
+	}
 
-	nodeX := l.NodesHorizontalCoordinatesAssigner.NodesHorizontalCoordinates(g, lg)
-	nodeY := l.NodesVerticalCoordinatesAssigner.NodesVerticalCoordinates(g, lg)
+	start = time.Now()
+	lg, err := rankConstrainedLevels(g, l.LevelsAssigner)
+	observe(l.Observer, Event{Phase: PhaseLevels, Elapsed: time.Since(start)})
+	if err != nil {
+		return err
+	}
+	if err := lg.Validate(); err != nil {
+		return err
+	}
+	if err := validateEdgesMatch(g, lg); err != nil {
+		return err
+	}
+	lg = labelLayers(g, lg)
+	lg = clusterBorders(g, lg)
+	lg.Reindex()
+	lg.RankDirection = l.RankDirection
+	if l.RankDirection.isHorizontal() {
+		lg.Ports = segmentPorts(g, lg, 1)
+	} else {
+		lg.Ports = segmentPorts(g, lg, 0)
+	}
+
+	start = time.Now()
+	if l.OrderingAssignerContext != nil {
+		l.OrderingAssignerContext(ctx, g, lg)
+	} else {
+		l.OrderingAssigner(g, lg)
+	}
+	lg.Reindex()
+	if l.Observer != nil {
+		elapsed := time.Since(start)
+		n := numCrossingsPorts(lg.adjacency(), lg.Ports, lg.Layers())
+		l.Observer.Observe(Event{Phase: PhaseOrdering, Crossings: n, BestCrossings: n, Elapsed: elapsed})
+	}
+
+	// graph with dimensions of nodes as if layers go top to bottom
+	gc := g
+	if l.RankDirection.isHorizontal() {
+		gc = Graph{Nodes: make(map[uint64]Node, len(g.Nodes)), Edges: make(map[EdgeID]Edge, len(g.Edges))}
+		for n, node := range g.Nodes {
+			gc.Nodes[n] = Node{W: node.H, H: node.W}
+		}
+		for e, edge := range g.Edges {
+			edge.Label.W, edge.Label.H = edge.Label.H, edge.Label.W
+			gc.Edges[e] = edge
+		}
+	}
+
+	distance, space := flatRoutes(gc, lg)
+
+	start = time.Now()
+	nodeX := l.NodesHorizontalCoordinatesAssigner.NodesHorizontalCoordinates(gc, lg)
+	observe(l.Observer, Event{Phase: PhaseHorizontalCoordinates, Elapsed: time.Since(start)})
+
+	start = time.Now()
+	nodeY := l.NodesVerticalCoordinatesAssigner.NodesVerticalCoordinates(gc, lg)
+	shiftClusterLayers(g, lg, nodeY, l.ClusterPadding)
+	shiftFlatLayers(lg, nodeY, space, l.RankDirection == BottomToTop || l.RankDirection == RightToLeft)
+	observe(l.Observer, Event{Phase: PhaseVerticalCoordinates, Elapsed: time.Since(start)})
 
 	// real and fake node coordinates
 	allNodesXY := make(map[uint64][2]int, len(g.Nodes))
 	for n := range lg.NodeYX {
 		allNodesXY[n] = [2]int{nodeX[n], nodeY[n]}
 	}
+	sizes := make(map[uint64][2]int, len(g.Nodes)+len(lg.Labels))
+	for n, node := range g.Nodes {
+		sizes[n] = [2]int{node.W, node.H}
+	}
+	for n, e := range lg.Labels {
+		sizes[n] = [2]int{g.Edges[e].Label.W, g.Edges[e].Label.H}
+	}
+	l.RankDirection.transform(allNodesXY, sizes)
+
+	// export coordinates to real nodes, parallel edges are separated around them
+	for n, node := range g.Nodes {
+		node.XY = [2]int{allNodesXY[n][0] - node.W/2, allNodesXY[n][1] - node.H/2}
+		g.Nodes[n] = node
+	}
 
 	// export coordinates for edges
+	start = time.Now()
 	l.EdgePathAssigner(g, lg, allNodesXY)
+	if l.RankDirection.isHorizontal() {
+		flatEdgePaths(g, lg, allNodesXY, 0, distance)
+		shiftParallelEdges(g, 0)
+	} else {
+		flatEdgePaths(g, lg, allNodesXY, 1, distance)
+		shiftParallelEdges(g, 1)
+	}
+	observe(l.Observer, Event{Phase: PhaseEdgePaths, Elapsed: time.Since(start)})
 
-	// export coordinates to real nodes
-	for n, node := range g.Nodes {
-		g.Nodes[n] = Node{
-			XY: [2]int{nodeX[n] - node.W/2, nodeY[n] - node.H/2},
-			W:  node.W,
-			H:  node.H,
+	// labels are after fake nodes along layer and centered across layer
+	for n, e := range lg.Labels {
+		edge := g.Edges[e]
+		xy := allNodesXY[n]
+		if l.RankDirection.isHorizontal() {
+			edge.Label.XY = [2]int{xy[0] - edge.Label.W/2, xy[1]}
+		} else {
+			edge.Label.XY = [2]int{xy[0], xy[1] - edge.Label.H/2}
 		}
+		g.Edges[e] = edge
+	}
+
+	if l.RankDirection.isHorizontal() {
+		clusterBoxes(g, lg, allNodesXY, 0, l.ClusterPadding)
+	} else {
+		clusterBoxes(g, lg, allNodesXY, 1, l.ClusterPadding)
 	}
 
-	l.CycleRemover.Restore(g)
+	return nil
 }
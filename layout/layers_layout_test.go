@@ -0,0 +1,383 @@
+package layout_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/nikolaydubina/go-graph-layout/layout"
+)
+
+type noCycleRemover struct{}
+
+func (noCycleRemover) RemoveCycles(g layout.Graph) {}
+
+func (noCycleRemover) Restore(g layout.Graph) {}
+
+func newTestSugiyamaLayout() layout.SugiyamaLayersStrategyGraphLayout {
+	return layout.SugiyamaLayersStrategyGraphLayout{
+		CycleRemover:   layout.NewGreedyCycleRemover(),
+		LevelsAssigner: layout.NewLayeredGraph,
+		OrderingAssigner: layout.WarfieldOrderingOptimizer{
+			Epochs:                   10,
+			LayerOrderingInitializer: layout.BFSOrderingInitializer{},
+			LayerOrderingOptimizer:   layout.WMedianOrderingOptimizer{},
+		}.Optimize,
+		NodesHorizontalCoordinatesAssigner: layout.BrandesKopfLayersNodesHorizontalAssigner{Delta: 25},
+		NodesVerticalCoordinatesAssigner:   layout.BasicNodesVerticalCoordinatesAssigner{MarginLayers: 25},
+		EdgePathAssigner:                   layout.StraightEdgePathAssigner{}.UpdateGraphLayout,
+	}
+}
+
+func TestSugiyamaLayersStrategyGraphLayoutE(t *testing.T) {
+	t.Run("missing node", func(t *testing.T) {
+		g := layout.Graph{
+			Nodes: map[uint64]layout.Node{1: {}},
+			Edges: map[layout.EdgeID]layout.Edge{{1, 2}: {}},
+		}
+
+		err := newTestSugiyamaLayout().UpdateGraphLayoutE(g)
+
+		var errMissing layout.MissingNodeError
+		if !errors.As(err, &errMissing) || errMissing.Node != 2 {
+			t.Errorf("expected missing node error, got %v", err)
+		}
+	})
+
+	t.Run("missing node without error", func(t *testing.T) {
+		g := layout.Graph{
+			Nodes: map[uint64]layout.Node{1: {W: 10, H: 10}, 3: {W: 10, H: 10}},
+			Edges: map[layout.EdgeID]layout.Edge{{1, 2}: {}, {1, 3}: {}},
+		}
+
+		newTestSugiyamaLayout().UpdateGraphLayout(g)
+
+		if _, ok := g.Edges[layout.EdgeID{1, 2}]; !ok {
+			t.Errorf("expected edge to missing node to be kept")
+		}
+		if path := g.Edges[layout.EdgeID{1, 3}].Path; len(path) != 2 || g.Nodes[1].XY == g.Nodes[3].XY {
+			t.Errorf("expected nodes to be laid out, got path %v", path)
+		}
+	})
+
+	t.Run("other errors panic without error", func(t *testing.T) {
+		g := layout.Graph{
+			Nodes: map[uint64]layout.Node{1: {}, 2: {}, 3: {}},
+			Edges: map[layout.EdgeID]layout.Edge{{1, 2}: {}, {2, 3}: {}, {3, 2}: {}},
+		}
+		l := newTestSugiyamaLayout()
+		l.CycleRemover = noCycleRemover{}
+
+		defer func() {
+			var errCycle layout.CycleError
+			if err, ok := recover().(error); !ok || !errors.As(err, &errCycle) {
+				t.Errorf("expected panic with cycle error, got %v", err)
+			}
+		}()
+		l.UpdateGraphLayout(g)
+	})
+
+	t.Run("cycle left after removal", func(t *testing.T) {
+		g := layout.Graph{
+			Nodes: map[uint64]layout.Node{1: {}, 2: {}, 3: {}},
+			Edges: map[layout.EdgeID]layout.Edge{{1, 2}: {}, {2, 3}: {}, {3, 2}: {}},
+		}
+		l := newTestSugiyamaLayout()
+		l.CycleRemover = noCycleRemover{}
+
+		err := l.UpdateGraphLayoutE(g)
+
+		var errCycle layout.CycleError
+		if !errors.As(err, &errCycle) || len(errCycle.Nodes) != 2 {
+			t.Errorf("expected cycle error, got %v", err)
+		}
+	})
+
+	t.Run("cycle left after removal with ranks", func(t *testing.T) {
+		g := layout.Graph{
+			Nodes: map[uint64]layout.Node{1: {}, 2: {}, 3: {}, 4: {}},
+			Edges: map[layout.EdgeID]layout.Edge{{1, 2}: {}, {2, 3}: {}, {4, 1}: {}},
+			Ranks: []layout.RankConstraint{{Kind: layout.RankSame, Nodes: []uint64{3, 4}}},
+		}
+		l := newTestSugiyamaLayout()
+		l.CycleRemover = noCycleRemover{}
+
+		err := l.UpdateGraphLayoutE(g)
+
+		var errCycle layout.CycleError
+		if !errors.As(err, &errCycle) || len(errCycle.Nodes) != 4 {
+			t.Errorf("expected cycle error, got %v", err)
+		}
+	})
+
+	t.Run("wrong layer direction", func(t *testing.T) {
+		g := layout.Graph{
+			Nodes: map[uint64]layout.Node{1: {}, 2: {}},
+			Edges: map[layout.EdgeID]layout.Edge{{1, 2}: {}},
+		}
+		l := newTestSugiyamaLayout()
+		l.LevelsAssigner = func(g layout.Graph) layout.LayeredGraph {
+			lg := layout.NewLayeredGraph(g)
+			lg.NodeYX[2] = [2]int{0, 1}
+			return lg
+		}
+
+		err := l.UpdateGraphLayoutE(g)
+
+		var errDirection layout.LayerDirectionError
+		if !errors.As(err, &errDirection) || errDirection.Segment != [2]uint64{1, 2} {
+			t.Errorf("expected layer direction error, got %v", err)
+		}
+	})
+
+	t.Run("cycles are removed and restored", func(t *testing.T) {
+		g := layout.Graph{
+			Nodes: map[uint64]layout.Node{1: {W: 10, H: 10}, 2: {W: 10, H: 10}, 3: {W: 10, H: 10}},
+			Edges: map[layout.EdgeID]layout.Edge{{1, 2}: {}, {2, 3}: {}, {3, 1}: {}},
+		}
+
+		if err := newTestSugiyamaLayout().UpdateGraphLayoutE(g); err != nil {
+			t.Error(err)
+		}
+
+		for _, e := range []layout.EdgeID{{1, 2}, {2, 3}, {3, 1}} {
+			path := g.Edges[e].Path
+			if len(path) < 2 || path[0] != g.Nodes[e[0]].CenterXY() || path[len(path)-1] != g.Nodes[e[1]].CenterXY() {
+				t.Errorf("edge %v has wrong path %v", e, path)
+			}
+		}
+	})
+}
+
+func TestSugiyamaLayersStrategyGraphLayoutContext(t *testing.T) {
+	_, g, err := parseJSONLGraph(ginJSONL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	l := newTestSugiyamaLayout()
+	l.OrderingAssignerContext = layout.WarfieldOrderingOptimizer{
+		Epochs:                   1000000,
+		LayerOrderingInitializer: layout.BFSOrderingInitializer{},
+		LayerOrderingOptimizer:   layout.WMedianOrderingOptimizer{},
+	}.OptimizeContext
+
+	if err := l.UpdateGraphLayoutContext(ctx, *g); err != nil {
+		t.Error(err)
+	}
+	for e, edge := range g.Edges {
+		if len(edge.Path) < 2 {
+			t.Errorf("edge %v has no path", e)
+		}
+	}
+}
+
+func TestSugiyamaLayersStrategyGraphLayoutObserver(t *testing.T) {
+	_, g, err := parseJSONLGraph(ginJSONL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var events []layout.Event
+	observer := layout.ObserverFunc(func(e layout.Event) { events = append(events, e) })
+
+	l := newTestSugiyamaLayout()
+	l.Observer = observer
+	l.OrderingAssigner = layout.WarfieldOrderingOptimizer{
+		Epochs:                   10,
+		LayerOrderingInitializer: layout.BFSOrderingInitializer{},
+		LayerOrderingOptimizer:   layout.WMedianOrderingOptimizer{},
+		Observer:                 observer,
+	}.Optimize
+
+	if err := l.UpdateGraphLayoutE(*g); err != nil {
+		t.Fatal(err)
+	}
+
+	var phases []layout.Phase
+	bestCrossings := -1
+	for _, e := range events {
+		if e.Phase == layout.PhaseOrderingEpoch {
+			if bestCrossings >= 0 && e.BestCrossings > bestCrossings {
+				t.Errorf("best crossings increased from %d to %d", bestCrossings, e.BestCrossings)
+			}
+			bestCrossings = e.BestCrossings
+			continue
+		}
+		phases = append(phases, e.Phase)
+	}
+
+	expected := []layout.Phase{
+		layout.PhaseCycleRemoval,
+		layout.PhaseLevels,
+		layout.PhaseOrdering,
+		layout.PhaseHorizontalCoordinates,
+		layout.PhaseVerticalCoordinates,
+		layout.PhaseEdgePaths,
+	}
+	if fmt.Sprint(phases) != fmt.Sprint(expected) {
+		t.Errorf("expected phases %v, got %v", expected, phases)
+	}
+	if bestCrossings < 0 {
+		t.Error("expected ordering epochs")
+	}
+}
+
+func TestSugiyamaLayersStrategyGraphLayoutRankDirection(t *testing.T) {
+	tests := []struct {
+		direction layout.RankDirection
+		axis      int  // axis along which layers follow
+		reversed  bool // layers follow in decreasing coordinate
+	}{
+		{direction: layout.TopToBottom, axis: 1},
+		{direction: layout.BottomToTop, axis: 1, reversed: true},
+		{direction: layout.LeftToRight, axis: 0},
+		{direction: layout.RightToLeft, axis: 0, reversed: true},
+	}
+	layoutGraph := func(t *testing.T, d layout.RankDirection) layout.Graph {
+		_, g, err := parseJSONLGraph(smallJSONL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		l := newTestSugiyamaLayout()
+		l.RankDirection = d
+		if err := l.UpdateGraphLayoutE(*g); err != nil {
+			t.Fatal(err)
+		}
+		return *g
+	}
+	// nodesRange is range of boxes of nodes along axis
+	nodesRange := func(g layout.Graph, axis int) [2]int {
+		r := [2]int{math.MaxInt, math.MinInt}
+		for _, node := range g.Nodes {
+			size := [2]int{node.W, node.H}
+			r = [2]int{minInt(r[0], node.XY[axis]), maxInt(r[1], node.XY[axis]+size[axis])}
+		}
+		return r
+	}
+	for _, tc := range tests {
+		t.Run(fmt.Sprint(tc.direction), func(t *testing.T) {
+			g := layoutGraph(t, tc.direction)
+
+			if tc.reversed {
+				// same range as layers that follow in increasing coordinate
+				expected := nodesRange(layoutGraph(t, tc.direction-1), tc.axis)
+				if got := nodesRange(g, tc.axis); got != expected || got[0] < 0 {
+					t.Errorf("expected nodes in range %v along axis %d, got %v", expected, tc.axis, got)
+				}
+			}
+
+			if a, b, ok := overlappingNodes(g, 0); ok {
+				t.Errorf("nodes %d %v and %d %v overlap", a, g.Nodes[a], b, g.Nodes[b])
+			}
+
+			for e, edge := range g.Edges {
+				from := g.Nodes[e[0]].CenterXY()[tc.axis]
+				to := g.Nodes[e[1]].CenterXY()[tc.axis]
+				if (from >= to) != tc.reversed || from == to {
+					t.Errorf("edge %v goes from %d to %d along axis %d", e, from, to, tc.axis)
+				}
+				if len(edge.Path) < 2 || edge.Path[0] != g.Nodes[e[0]].CenterXY() || edge.Path[len(edge.Path)-1] != g.Nodes[e[1]].CenterXY() {
+					t.Errorf("edge %v has path %v that does not connect nodes", e, edge.Path)
+				}
+			}
+		})
+	}
+}
+
+func TestSugiyamaLayersStrategyGraphLayoutPorts(t *testing.T) {
+	tests := []struct {
+		direction layout.RankDirection
+		node      layout.Node
+		axis      int // axis along layers
+	}{
+		{
+			direction: layout.TopToBottom,
+			node:      layout.Node{W: 100, H: 20, Ports: map[string]layout.Port{"a": {Side: layout.SideBottom, Offset: 10}, "b": {Side: layout.SideBottom, Offset: 90}}},
+			axis:      0,
+		},
+		{
+			direction: layout.LeftToRight,
+			node:      layout.Node{W: 20, H: 100, Ports: map[string]layout.Port{"a": {Side: layout.SideRight, Offset: 10}, "b": {Side: layout.SideRight, Offset: 90}}},
+			axis:      1,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(fmt.Sprint(tc.direction), func(t *testing.T) {
+			t.Run("ordering follows ports", func(t *testing.T) {
+				g := layout.Graph{
+					Nodes: map[uint64]layout.Node{1: tc.node, 2: {W: 20, H: 20}, 3: {W: 20, H: 20}},
+					Edges: map[layout.EdgeID]layout.Edge{{1, 2}: {FromPort: "b"}, {1, 3}: {FromPort: "a"}},
+				}
+				l := newTestSugiyamaLayout()
+				l.RankDirection = tc.direction
+				if err := l.UpdateGraphLayoutE(g); err != nil {
+					t.Fatal(err)
+				}
+
+				if a, b := g.Nodes[3].CenterXY()[tc.axis], g.Nodes[2].CenterXY()[tc.axis]; a >= b {
+					t.Errorf("node 3 at %d is expected before node 2 at %d", a, b)
+				}
+				for e, edge := range g.Edges {
+					if edge.Path[0] != g.Nodes[1].PortXY(edge.FromPort) || edge.Path[len(edge.Path)-1] != g.Nodes[e[1]].CenterXY() {
+						t.Errorf("edge %v has path %v that does not start at port", e, edge.Path)
+					}
+				}
+			})
+
+			t.Run("straight edge from port", func(t *testing.T) {
+				g := layout.Graph{
+					Nodes: map[uint64]layout.Node{1: tc.node, 2: {W: 20, H: 20}},
+					Edges: map[layout.EdgeID]layout.Edge{{1, 2}: {FromPort: "b"}},
+				}
+				l := newTestSugiyamaLayout()
+				l.RankDirection = tc.direction
+				if err := l.UpdateGraphLayoutE(g); err != nil {
+					t.Fatal(err)
+				}
+
+				if from, to := g.Nodes[1].PortXY("b")[tc.axis], g.Nodes[2].CenterXY()[tc.axis]; from != to {
+					t.Errorf("edge goes from port at %d to node at %d, expected straight edge", from, to)
+				}
+			})
+		})
+	}
+}
+
+func TestPortsWithCycleRemoval(t *testing.T) {
+	g := layout.Graph{
+		Nodes: map[uint64]layout.Node{
+			1: {W: 40, H: 20, Ports: map[string]layout.Port{"out": {Side: layout.SideRight, Offset: 5}}},
+			2: {W: 40, H: 20, Ports: map[string]layout.Port{"in": {Side: layout.SideLeft, Offset: 15}}},
+			3: {W: 40, H: 20},
+		},
+		Edges: map[layout.EdgeID]layout.Edge{{1, 2}: {FromPort: "out", ToPort: "in"}, {2, 3}: {}, {3, 1}: {}},
+	}
+	if err := newTestSugiyamaLayout().UpdateGraphLayoutE(g); err != nil {
+		t.Fatal(err)
+	}
+
+	for e, edge := range g.Edges {
+		if edge.Path[0] != g.Nodes[e[0]].PortXY(edge.FromPort) || edge.Path[len(edge.Path)-1] != g.Nodes[e[1]].PortXY(edge.ToPort) {
+			t.Errorf("edge %v has path %v that does not connect ports", e, edge.Path)
+		}
+	}
+}
+
+func BenchmarkSugiyamaLayersStrategyGraphLayout(b *testing.B) {
+	for _, n := range []int{500, 5000} {
+		b.Run(fmt.Sprintf("nodes(%d)", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				g := newTestRandomGraph(n, n+n/5)
+				if err := newTestSugiyamaLayout().UpdateGraphLayoutE(g); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
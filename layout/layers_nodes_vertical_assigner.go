@@ -7,12 +7,16 @@ type BasicNodesVerticalCoordinatesAssigner struct {
 	FakeNodeHeight int
 }
 
-func layersMaxHeights(g Graph, layers [][]uint64) []int {
+func layersMaxHeights(g Graph, lg LayeredGraph, layers [][]uint64) []int {
 	hmax := make([]int, len(layers))
 	for i, nodes := range layers {
 		for _, node := range nodes {
-			if hmax[i] < g.Nodes[node].H {
-				hmax[i] = g.Nodes[node].H
+			h := g.Nodes[node].H
+			if _, lh, ok := lg.labelSize(g, node); ok {
+				h = lh
+			}
+			if hmax[i] < h {
+				hmax[i] = h
 			}
 		}
 	}
@@ -23,7 +27,7 @@ func (s BasicNodesVerticalCoordinatesAssigner) NodesVerticalCoordinates(g Graph,
 	nodeY := make(map[uint64]int, len(lg.NodeYX))
 
 	layers := lg.Layers()
-	layersHMax := layersMaxHeights(g, layers)
+	layersHMax := layersMaxHeights(g, lg, layers)
 
 	yOffset := 0
 	for i, nodes := range layers {
@@ -1,9 +1,10 @@
 package layout
 
 import (
-	"log"
+	"context"
 	"math/rand"
 	"sort"
+	"time"
 )
 
 type LayerOrderingInitializer interface {
@@ -14,6 +15,12 @@ type LayerOrderingOptimizer interface {
 	Optimize(segments map[[2]uint64]bool, layers [][]uint64, idx int, downUp bool)
 }
 
+// PortsLayerOrderingOptimizer is LayerOrderingOptimizer that considers ports of segments.
+// Ports are same as in LayeredGraph.
+type PortsLayerOrderingOptimizer interface {
+	OptimizePorts(segments map[[2]uint64]bool, ports map[[2]uint64][2]float64, layers [][]uint64, idx int, downUp bool)
+}
+
 type CompositeLayerOrderingOptimizer struct {
 	Optimizers []LayerOrderingOptimizer
 }
@@ -24,40 +31,133 @@ func (o CompositeLayerOrderingOptimizer) Optimize(segments map[[2]uint64]bool, l
 	}
 }
 
+// OptimizePorts passes ports to optimizers that consider them.
+func (o CompositeLayerOrderingOptimizer) OptimizePorts(segments map[[2]uint64]bool, ports map[[2]uint64][2]float64, layers [][]uint64, idx int, downUp bool) {
+	o.optimizeAdjacency(newSegmentAdjacency(segments), ports, layers, idx, downUp)
+}
+
+func (o CompositeLayerOrderingOptimizer) optimizeAdjacency(adj segmentAdjacency, ports map[[2]uint64][2]float64, layers [][]uint64, idx int, downUp bool) {
+	for _, q := range o.Optimizers {
+		optimizeLayer(q, adj, ports, layers, idx, downUp)
+	}
+}
+
+// withDefaultRand sets source of randomness to optimizers that do not have it.
+func (o CompositeLayerOrderingOptimizer) withDefaultRand(r *rand.Rand) LayerOrderingOptimizer {
+	optimizers := make([]LayerOrderingOptimizer, len(o.Optimizers))
+	for i, q := range o.Optimizers {
+		optimizers[i] = q
+		if q, ok := q.(randLayerOrderingOptimizer); ok {
+			optimizers[i] = q.withDefaultRand(r)
+		}
+	}
+	return CompositeLayerOrderingOptimizer{Optimizers: optimizers}
+}
+
+// randLayerOrderingOptimizer is LayerOrderingOptimizer that uses source of randomness.
+type randLayerOrderingOptimizer interface {
+	withDefaultRand(r *rand.Rand) LayerOrderingOptimizer
+}
+
+// segmentAdjacency is segments with upper and lower neighbors of nodes,
+// so that neighbors of node are found without looking up segments to all nodes of adjacent layers.
+type segmentAdjacency struct {
+	segments map[[2]uint64]bool
+	up, down map[uint64][]uint64
+}
+
+// time: O(E)
+func newSegmentAdjacency(segments map[[2]uint64]bool) segmentAdjacency {
+	adj := segmentAdjacency{segments: segments, up: make(map[uint64][]uint64), down: make(map[uint64][]uint64)}
+	for e := range segments {
+		adj.down[e[0]] = append(adj.down[e[0]], e[1])
+		adj.up[e[1]] = append(adj.up[e[1]], e[0])
+	}
+	return adj
+}
+
+// adjacencyLayerOrderingOptimizer is LayerOrderingOptimizer that finds neighbors by adjacency.
+type adjacencyLayerOrderingOptimizer interface {
+	optimizeAdjacency(adj segmentAdjacency, ports map[[2]uint64][2]float64, layers [][]uint64, idx int, downUp bool)
+}
+
+// optimizeLayer passes adjacency to optimizer when it uses it, and ports when it considers them.
+func optimizeLayer(o LayerOrderingOptimizer, adj segmentAdjacency, ports map[[2]uint64][2]float64, layers [][]uint64, idx int, downUp bool) {
+	if q, ok := o.(adjacencyLayerOrderingOptimizer); ok {
+		q.optimizeAdjacency(adj, ports, layers, idx, downUp)
+		return
+	}
+	if q, ok := o.(PortsLayerOrderingOptimizer); ok && len(ports) > 0 {
+		q.OptimizePorts(adj.segments, ports, layers, idx, downUp)
+		return
+	}
+	o.Optimize(adj.segments, layers, idx, downUp)
+}
+
 // WarfieldOrderingOptimizer is heuristic based strategy for ordering optimization.
 // Goes up and down number of iterations across all layers.
 // Considers upper and lower fixed and permutes ordering in layer.
 // Used in Graphviz/dot.
+// Crossings of segments that start at different ports of same node are counted too.
+// After initialization and each epoch flat edges go left to right and clusters are made contiguous in layers,
+// so that crossings are counted and best ordering is kept only for orderings that satisfy them.
+// Random initializer and optimizers without source of randomness share one source with fixed seed for each run.
 type WarfieldOrderingOptimizer struct {
 	Epochs                   int
 	LayerOrderingInitializer LayerOrderingInitializer
 	LayerOrderingOptimizer   LayerOrderingOptimizer
+	Observer                 Observer // receives crossings after each epoch
 }
 
 func (o WarfieldOrderingOptimizer) Optimize(g Graph, lg LayeredGraph) {
+	o.OptimizeContext(context.Background(), g, lg)
+}
+
+// OptimizeContext stops optimization when context is done, keeping best ordering found so far.
+func (o WarfieldOrderingOptimizer) OptimizeContext(ctx context.Context, g Graph, lg LayeredGraph) {
+	// default source of randomness is made once, so that random orderings differ between layers and epochs
+	r := randOrDefault(nil)
+	initializer, optimizer := o.LayerOrderingInitializer, o.LayerOrderingOptimizer
+	if q, ok := initializer.(RandomLayerOrderingInitializer); ok && q.Rand == nil {
+		initializer = RandomLayerOrderingInitializer{Rand: r}
+	}
+	if q, ok := optimizer.(randLayerOrderingOptimizer); ok {
+		optimizer = q.withDefaultRand(r)
+	}
+
 	// layers is temporary layers
 	layers := lg.Layers()
-	o.LayerOrderingInitializer.Init(lg.Segments, layers)
+	initializer.Init(lg.Segments, layers)
+	constrainOrder(g, lg, layers)
 
+	adj := lg.adjacency()
 	bestN := -1
 	bestLayers := newLayersFrom(layers)
+	start := time.Now()
 
-	for t := 0; t < o.Epochs; t++ {
+	for t := 0; t < o.Epochs && ctx.Err() == nil; t++ {
 		downUp := (t % 2) == 0
 		for i := range layers {
+			if ctx.Err() != nil {
+				break
+			}
 			j := i
 			if downUp {
 				j = len(layers) - 1 - i
 			}
-			o.LayerOrderingOptimizer.Optimize(lg.Segments, layers, j, downUp)
+			optimizeLayer(optimizer, adj, lg.Ports, layers, j, downUp)
+		}
+		if ctx.Err() != nil {
+			break
 		}
+		constrainOrder(g, lg, layers)
 
-		N := numCrossings(lg.Segments, layers)
+		N := numCrossingsPorts(adj, lg.Ports, layers)
 		if bestN < 0 || N < bestN {
 			bestN = N
 			copyLayers(bestLayers, layers)
 		}
-		log.Printf("warfield ordering optimizer:\t epoch(%d)\t best(%d)\t current(%d)\n", t, bestN, N)
+		observe(o.Observer, Event{Phase: PhaseOrderingEpoch, Step: t, Crossings: N, BestCrossings: bestN, Elapsed: time.Since(start)})
 		if N == 0 {
 			break
 		}
@@ -69,18 +169,39 @@ func (o WarfieldOrderingOptimizer) Optimize(g Graph, lg LayeredGraph) {
 			lg.NodeYX[node] = [2]int{y, x}
 		}
 	}
+	lg.Reindex()
+}
+
+// constrainOrder changes order in layers so that flat edges go left to right and nodes of clusters are contiguous.
+func constrainOrder(g Graph, lg LayeredGraph, layers [][]uint64) {
+	orderFlatEdges(lg, layers)
+	orderClusters(g, lg, layers)
 }
 
 // BFSOrderingInitializer will set order in each layer by traversing BFS from roots.
 type BFSOrderingInitializer struct{}
 
 func (o BFSOrderingInitializer) Init(segments map[[2]uint64]bool, layers [][]uint64) {
-	// accumulate where node can lead to
-	fromNodeToNodes := map[uint64][]uint64{}
+	// accumulate where node can lead to, in order of current layers
+	pos := map[uint64]int{}
+	for _, layer := range layers {
+		for _, node := range layer {
+			pos[node] = len(pos)
+		}
+	}
+	sortedSegments := make([][2]uint64, 0, len(segments))
 	for e := range segments {
-		if _, ok := fromNodeToNodes[e[0]]; !ok {
-			fromNodeToNodes[e[0]] = []uint64{}
+		sortedSegments = append(sortedSegments, e)
+	}
+	sort.Slice(sortedSegments, func(i, j int) bool {
+		a, b := sortedSegments[i], sortedSegments[j]
+		if pos[a[0]] != pos[b[0]] {
+			return pos[a[0]] < pos[b[0]]
 		}
+		return pos[a[1]] < pos[b[1]]
+	})
+	fromNodeToNodes := map[uint64][]uint64{}
+	for _, e := range sortedSegments {
 		fromNodeToNodes[e[0]] = append(fromNodeToNodes[e[0]], e[1])
 	}
 
@@ -90,9 +211,11 @@ func (o BFSOrderingInitializer) Init(segments map[[2]uint64]bool, layers [][]uin
 		hasParent[e[1]] = true
 	}
 	var roots []uint64
-	for e := range segments {
-		if _, ok := hasParent[e[1]]; !ok {
-			roots = append(roots, e[1])
+	for _, layer := range layers {
+		for _, node := range layer {
+			if !hasParent[node] {
+				roots = append(roots, node)
+			}
 		}
 	}
 
@@ -119,17 +242,20 @@ func (o BFSOrderingInitializer) Init(segments map[[2]uint64]bool, layers [][]uin
 	}
 
 	for l := range layers {
-		sort.Slice(layers[l], func(i, j int) bool { return ord[layers[l][i]] < ord[layers[l][j]] })
+		sort.SliceStable(layers[l], func(i, j int) bool { return ord[layers[l][i]] < ord[layers[l][j]] })
 	}
 }
 
 // RandomLayerOrderingInitializer assigns random ordering in each layer
-type RandomLayerOrderingInitializer struct{}
+type RandomLayerOrderingInitializer struct {
+	Rand *rand.Rand // source of randomness, source with fixed seed when nil, made once for each run in WarfieldOrderingOptimizer
+}
 
 func (o RandomLayerOrderingInitializer) Init(_ map[[2]uint64]bool, layers [][]uint64) {
+	r := randOrDefault(o.Rand)
 	for i := range layers {
 		l := layers[i]
-		rand.Shuffle(len(l), func(a, b int) { l[a], l[b] = l[b], l[a] })
+		r.Shuffle(len(l), func(a, b int) { l[a], l[b] = l[b], l[a] })
 	}
 }
 
@@ -137,64 +263,90 @@ func (o RandomLayerOrderingInitializer) Init(_ map[[2]uint64]bool, layers [][]ui
 // Median has property of stable vertical edges which is especially useful for "long" edges (fake nodes).
 // Eades and Wormald, 1994
 // This is used in dot/Graphviz, Figure 3-2 in Graphviz dot paper TSE93.
+// With ports, positions of neighbors are moved by positions of ports at both ends of segments.
 type WMedianOrderingOptimizer struct{}
 
 func (o WMedianOrderingOptimizer) Optimize(segments map[[2]uint64]bool, layers [][]uint64, y int, downUp bool) {
+	o.OptimizePorts(segments, nil, layers, y, downUp)
+}
+
+func (o WMedianOrderingOptimizer) OptimizePorts(segments map[[2]uint64]bool, ports map[[2]uint64][2]float64, layers [][]uint64, y int, downUp bool) {
+	o.optimizeAdjacency(newSegmentAdjacency(segments), ports, layers, y, downUp)
+}
+
+func (o WMedianOrderingOptimizer) optimizeAdjacency(adj segmentAdjacency, ports map[[2]uint64][2]float64, layers [][]uint64, y int, downUp bool) {
 	w := map[uint64]float64{}
 
-	for i, node := range layers[y] {
-		var xs []int
+	var pos map[uint64]int
+	switch {
+	case downUp && y < len(layers)-1:
+		pos = layerPositions(layers[y+1])
+	case !downUp && y > 0:
+		pos = layerPositions(layers[y-1])
+	}
+
+	for _, node := range layers[y] {
+		var P []float64
 		if downUp {
-			xs = lowerNeighborsX(segments, layers, i, y)
+			P = adj.lowerNeighborsPos(ports, pos, node)
 		} else {
-			xs = upperNeighborsX(segments, layers, i, y)
-		}
-
-		P := make([]float64, len(xs))
-		for i, v := range xs {
-			P[i] = float64(v)
+			P = adj.upperNeighborsPos(ports, pos, node)
 		}
+		sort.Float64s(P)
 		w[node] = median(P)
 	}
 
-	sort.Slice(layers[y], func(i, j int) bool { return w[layers[y][i]] < w[layers[y][j]] })
+	sort.SliceStable(layers[y], func(i, j int) bool { return w[layers[y][i]] < w[layers[y][j]] })
 }
 
-// time: O(len(layer))
-// space: O(len(layer))
-func lowerNeighborsX(segments map[[2]uint64]bool, layers [][]uint64, x int, y int) []int {
-	if y == (len(layers) - 1) {
-		return nil
+// layerPositions are positions of nodes in layer.
+func layerPositions(layer []uint64) map[uint64]int {
+	pos := make(map[uint64]int, len(layer))
+	for i, n := range layer {
+		pos[n] = i
 	}
+	return pos
+}
 
-	t := layers[y][x]
-
-	var nx []int
-	for i, n := range layers[y+1] {
-		if segments[[2]uint64{t, n}] {
-			nx = append(nx, i)
+// layerNeighbors are neighbors that are in layer with positions pos, in order of layer.
+// time: O(deg log deg)
+func layerNeighbors(neighbors []uint64, pos map[uint64]int) []uint64 {
+	var ns []uint64
+	for _, n := range neighbors {
+		if _, ok := pos[n]; ok {
+			ns = append(ns, n)
 		}
 	}
+	sort.Slice(ns, func(i, j int) bool { return pos[ns[i]] < pos[ns[j]] })
+	return ns
+}
 
+// neighborsX are sorted positions of neighbors that are in layer with positions pos.
+func neighborsX(neighbors []uint64, pos map[uint64]int) []int {
+	var nx []int
+	for _, n := range layerNeighbors(neighbors, pos) {
+		nx = append(nx, pos[n])
+	}
 	return nx
 }
 
-// time: O(len(layer))
-// space: O(len(layer))
-func upperNeighborsX(segments map[[2]uint64]bool, layers [][]uint64, x int, y int) []int {
-	if y == 0 {
-		return nil
+// lowerNeighborsPos are positions of lower neighbors in order of layer, moved by positions of ports at both ends of segments.
+func (a segmentAdjacency) lowerNeighborsPos(ports map[[2]uint64][2]float64, pos map[uint64]int, node uint64) []float64 {
+	var nx []float64
+	for _, n := range layerNeighbors(a.down[node], pos) {
+		p := ports[[2]uint64{node, n}]
+		nx = append(nx, float64(pos[n])+p[1]-p[0])
 	}
+	return nx
+}
 
-	t := layers[y][x]
-
-	var nx []int
-	for i, n := range layers[y-1] {
-		if segments[[2]uint64{n, t}] {
-			nx = append(nx, i)
-		}
+// upperNeighborsPos are positions of upper neighbors in order of layer, moved by positions of ports at both ends of segments.
+func (a segmentAdjacency) upperNeighborsPos(ports map[[2]uint64][2]float64, pos map[uint64]int, node uint64) []float64 {
+	var nx []float64
+	for _, n := range layerNeighbors(a.up[node], pos) {
+		p := ports[[2]uint64{n, node}]
+		nx = append(nx, float64(pos[n])+p[0]-p[1])
 	}
-
 	return nx
 }
 
@@ -219,6 +371,10 @@ func median(P []float64) float64 {
 type SwitchAdjacentOrderingOptimizer struct{}
 
 func (o SwitchAdjacentOrderingOptimizer) Optimize(segments map[[2]uint64]bool, layers [][]uint64, y int, downUp bool) {
+	o.OptimizePorts(segments, nil, layers, y, downUp)
+}
+
+func (o SwitchAdjacentOrderingOptimizer) OptimizePorts(segments map[[2]uint64]bool, ports map[[2]uint64][2]float64, layers [][]uint64, y int, downUp bool) {
 	if len(layers[y]) < 2 {
 		return
 	}
@@ -240,11 +396,11 @@ func (o SwitchAdjacentOrderingOptimizer) Optimize(segments map[[2]uint64]bool, l
 		swapped := []uint64{layers[y][j], layers[y][i]}
 		var currCrossings, swappedCrossings int
 		if downUp {
-			currCrossings = numCrossingsBetweenLayers(segments, current, layers[y+1])
-			swappedCrossings = numCrossingsBetweenLayers(segments, swapped, layers[y+1])
+			currCrossings = numCrossingsBetweenLayersPorts(segments, ports, current, layers[y+1])
+			swappedCrossings = numCrossingsBetweenLayersPorts(segments, ports, swapped, layers[y+1])
 		} else {
-			currCrossings = numCrossingsBetweenLayers(segments, layers[y-1], current)
-			swappedCrossings = numCrossingsBetweenLayers(segments, layers[y-1], swapped)
+			currCrossings = numCrossingsBetweenLayersPorts(segments, ports, layers[y-1], current)
+			swappedCrossings = numCrossingsBetweenLayersPorts(segments, ports, layers[y-1], swapped)
 		}
 
 		if swappedCrossings < currCrossings {
@@ -253,9 +409,159 @@ func (o SwitchAdjacentOrderingOptimizer) Optimize(segments map[[2]uint64]bool, l
 	}
 }
 
+// BarycenterOrderingOptimizer sorts nodes in layer by average position of neighbors in both upper and lower layers.
+// Positions are relative to size of layer, so that layers of different sizes have same weight.
+// Nodes without neighbors keep their position.
+// Direction of sweep is not used, since both adjacent layers are considered.
+// Sugiyama, Tagawa, Toda, 1981
+// With ports, positions of neighbors are moved by positions of ports at both ends of segments.
+type BarycenterOrderingOptimizer struct{}
+
+func (o BarycenterOrderingOptimizer) Optimize(segments map[[2]uint64]bool, layers [][]uint64, y int, downUp bool) {
+	o.OptimizePorts(segments, nil, layers, y, downUp)
+}
+
+func (o BarycenterOrderingOptimizer) OptimizePorts(segments map[[2]uint64]bool, ports map[[2]uint64][2]float64, layers [][]uint64, y int, downUp bool) {
+	o.optimizeAdjacency(newSegmentAdjacency(segments), ports, layers, y, downUp)
+}
+
+func (o BarycenterOrderingOptimizer) optimizeAdjacency(adj segmentAdjacency, ports map[[2]uint64][2]float64, layers [][]uint64, y int, _ bool) {
+	w := make(map[uint64]float64, len(layers[y]))
+
+	var upper, lower map[uint64]int
+	if y > 0 {
+		upper = layerPositions(layers[y-1])
+	}
+	if y < len(layers)-1 {
+		lower = layerPositions(layers[y+1])
+	}
+
+	for i, node := range layers[y] {
+		sum := 0.0
+		count := 0
+		if y > 0 {
+			for _, x := range adj.upperNeighborsPos(ports, upper, node) {
+				sum += (x + 0.5) / float64(len(layers[y-1]))
+				count++
+			}
+		}
+		if y < len(layers)-1 {
+			for _, x := range adj.lowerNeighborsPos(ports, lower, node) {
+				sum += (x + 0.5) / float64(len(layers[y+1]))
+				count++
+			}
+		}
+
+		if count == 0 {
+			w[node] = (float64(i) + 0.5) / float64(len(layers[y]))
+		} else {
+			w[node] = sum / float64(count)
+		}
+	}
+
+	sort.SliceStable(layers[y], func(i, j int) bool { return w[layers[y][i]] < w[layers[y][j]] })
+}
+
+// SiftingOrderingOptimizer moves each node to position in layer with fewest crossings with both upper and lower layers.
+// Nodes are sifted in order of decreasing degree.
+// Crossings are counted incrementally by swapping node with its neighbor in layer, so each node takes O(len(layer) * degree).
+// Direction of sweep is not used, since both adjacent layers are considered.
+// "Using sifting for k-layer straightline crossing minimization", C. Matuszewski, R. Schönfeld, P. Molitor, 1999
+type SiftingOrderingOptimizer struct{}
+
+func (o SiftingOrderingOptimizer) Optimize(segments map[[2]uint64]bool, layers [][]uint64, y int, downUp bool) {
+	o.optimizeAdjacency(newSegmentAdjacency(segments), nil, layers, y, downUp)
+}
+
+func (o SiftingOrderingOptimizer) optimizeAdjacency(adj segmentAdjacency, _ map[[2]uint64][2]float64, layers [][]uint64, y int, _ bool) {
+	layer := layers[y]
+	if len(layer) < 2 {
+		return
+	}
+
+	// positions of neighbors do not change while layer is permuted
+	upper := make(map[uint64][]int, len(layer))
+	lower := make(map[uint64][]int, len(layer))
+	var upperPos, lowerPos map[uint64]int
+	if y > 0 {
+		upperPos = layerPositions(layers[y-1])
+	}
+	if y < len(layers)-1 {
+		lowerPos = layerPositions(layers[y+1])
+	}
+	for _, node := range layer {
+		if y > 0 {
+			upper[node] = neighborsX(adj.up[node], upperPos)
+		}
+		if y < len(layers)-1 {
+			lower[node] = neighborsX(adj.down[node], lowerPos)
+		}
+	}
+
+	// crossings between edges of u and v when u is left of v
+	crossings := func(u, v uint64) int {
+		return numCrossingsSorted(upper[u], upper[v]) + numCrossingsSorted(lower[u], lower[v])
+	}
+
+	nodes := make([]uint64, len(layer))
+	copy(nodes, layer)
+	sort.SliceStable(nodes, func(i, j int) bool {
+		return (len(upper[nodes[i]]) + len(lower[nodes[i]])) > (len(upper[nodes[j]]) + len(lower[nodes[j]]))
+	})
+
+	for _, u := range nodes {
+		// move to leftmost position
+		i := 0
+		for layer[i] != u {
+			i++
+		}
+		copy(layer[1:i+1], layer[:i])
+		layer[0] = u
+
+		// move right by swaps, tracking change in crossings
+		best, bestDelta, delta := 0, 0, 0
+		for p := 1; p < len(layer); p++ {
+			v := layer[p]
+			delta += crossings(v, u) - crossings(u, v)
+			layer[p-1], layer[p] = v, u
+			if delta < bestDelta {
+				best, bestDelta = p, delta
+			}
+		}
+
+		// move back from rightmost to best position
+		copy(layer[best+1:], layer[best:len(layer)-1])
+		layer[best] = u
+	}
+}
+
+// numCrossingsSorted counts pairs of edges that cross, when edges go from left node to positions a and from right node to positions b.
+// Positions have to be sorted.
+// time: O(len(a) + len(b))
+func numCrossingsSorted(a, b []int) int {
+	count := 0
+	j := 0
+	for _, x := range a {
+		for j < len(b) && b[j] < x {
+			j++
+		}
+		count += j
+	}
+	return count
+}
+
 // RandomLayerOrderingOptimizer picks best out of epochs random orderings.
 type RandomLayerOrderingOptimizer struct {
 	Epochs int
+	Rand   *rand.Rand // source of randomness, source with fixed seed when nil, made once for each run in WarfieldOrderingOptimizer
+}
+
+// withDefaultRand sets source of randomness when it is not set.
+func (o RandomLayerOrderingOptimizer) withDefaultRand(r *rand.Rand) LayerOrderingOptimizer {
+	if o.Rand == nil {
+		o.Rand = r
+	}
+	return o
 }
 
 func (o RandomLayerOrderingOptimizer) Optimize(segments map[[2]uint64]bool, layers [][]uint64, idx int, downUp bool) {
@@ -263,8 +569,9 @@ func (o RandomLayerOrderingOptimizer) Optimize(segments map[[2]uint64]bool, laye
 	layer := make([]uint64, len(layers[idx]))
 	copy(layer, layers[idx])
 
+	r := randOrDefault(o.Rand)
 	for i := 0; i < o.Epochs; i++ {
-		rand.Shuffle(len(layer), func(a, b int) { layer[a], layer[b] = layer[b], layer[a] })
+		r.Shuffle(len(layer), func(a, b int) { layer[a], layer[b] = layer[b], layer[a] })
 
 		N := 0
 		if idx > 0 {
@@ -299,6 +606,54 @@ func numCrossingsBetweenLayers(segments map[[2]uint64]bool, ltop, lbottom []uint
 	return sum
 }
 
+// numCrossingsBetweenLayersPorts is numCrossingsBetweenLayers that considers ports of segments.
+// Segments of same node cross when order of their ports is different from order of their other ends.
+// time: O(ntop * nbot + E * log(E))
+// memory: O(E)
+func numCrossingsBetweenLayersPorts(segments map[[2]uint64]bool, ports map[[2]uint64][2]float64, ltop, lbottom []uint64) int {
+	if len(ports) == 0 {
+		return numCrossingsBetweenLayers(segments, ltop, lbottom)
+	}
+
+	// positions of {top, bottom} ends of segments
+	var ends [][2]float64
+	for i, u := range ltop {
+		for j, v := range lbottom {
+			if s := [2]uint64{u, v}; segments[s] {
+				ends = append(ends, [2]float64{float64(i) + ports[s][0], float64(j) + ports[s][1]})
+			}
+		}
+	}
+	return numCrossingsEnds(ends)
+}
+
+// numCrossingsEnds is number of pairs of segments that cross, given positions of their {top, bottom} ends.
+// time: O(E log E)
+func numCrossingsEnds(ends [][2]float64) int {
+	sort.Slice(ends, func(i, j int) bool {
+		if ends[i][0] != ends[j][0] {
+			return ends[i][0] < ends[j][0]
+		}
+		return ends[i][1] < ends[j][1]
+	})
+
+	bottoms := make([]float64, len(ends))
+	for i, e := range ends {
+		bottoms[i] = e[1]
+	}
+	sort.Float64s(bottoms)
+
+	// count previous segments that end strictly to the right
+	sum := 0
+	bit := NewFenwickTree(len(ends))
+	for i, e := range ends {
+		notGreater := sort.Search(len(bottoms), func(k int) bool { return bottoms[k] > e[1] })
+		sum += i - bit.Query(notGreater)
+		bit.Update(sort.SearchFloat64s(bottoms, e[1])+1, 1)
+	}
+	return sum
+}
+
 type FenwickTree []int
 
 func NewFenwickTree(nbElements int) FenwickTree {
@@ -319,15 +674,40 @@ func (bit FenwickTree) Query(idx int) int {
 	return sum
 }
 
-// time: O(?)
-// memory: O(1)
-func numCrossings(segments map[[2]uint64]bool, layers [][]uint64) int {
+// numCrossingsPorts is number of crossings between all adjacent layers, considering ports of segments.
+// time: O(E log E)
+func numCrossingsPorts(adj segmentAdjacency, ports map[[2]uint64][2]float64, layers [][]uint64) int {
 	count := 0
-	for i := range layers {
-		if i == 0 {
-			continue
-		}
-		count += numCrossingsBetweenLayers(segments, layers[i-1], layers[i])
+	for i := 1; i < len(layers); i++ {
+		count += adj.numCrossingsBetweenLayers(ports, layers[i-1], layers[i])
 	}
 	return count
 }
+
+// numCrossingsBetweenLayers is same as numCrossingsBetweenLayersPorts, but takes neighbors from adjacency.
+// time: O(E log E), where E is number of segments between layers
+func (a segmentAdjacency) numCrossingsBetweenLayers(ports map[[2]uint64][2]float64, ltop, lbottom []uint64) int {
+	pos := layerPositions(ltop)
+	if len(ports) == 0 {
+		sum := 0
+		bit := NewFenwickTree(len(ltop))
+		for i := len(lbottom) - 1; i >= 0; i-- {
+			nx := neighborsX(a.up[lbottom[i]], pos)
+			for j := len(nx) - 1; j >= 0; j-- {
+				bit.Update(nx[j]+1, 1)
+				sum += bit.Query(nx[j])
+			}
+		}
+		return sum
+	}
+
+	// positions of {top, bottom} ends of segments
+	var ends [][2]float64
+	for j, v := range lbottom {
+		for _, u := range layerNeighbors(a.up[v], pos) {
+			s := [2]uint64{u, v}
+			ends = append(ends, [2]float64{float64(pos[u]) + ports[s][0], float64(j) + ports[s][1]})
+		}
+	}
+	return numCrossingsEnds(ends)
+}
@@ -0,0 +1,107 @@
+package layout_test
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/nikolaydubina/go-graph-layout/layout"
+)
+
+func TestLayerOrderingOptimizers(t *testing.T) {
+	optimizers := []layout.LayerOrderingOptimizer{
+		layout.BarycenterOrderingOptimizer{},
+		layout.SiftingOrderingOptimizer{},
+	}
+
+	tests := []struct {
+		name     string
+		segments [][2]uint64
+		layers   [][]uint64
+		y        int
+		expected []uint64
+	}{
+		{
+			name:     "reverse",
+			segments: [][2]uint64{{1, 6}, {2, 5}, {3, 4}},
+			layers:   [][]uint64{{1, 2, 3}, {4, 5, 6}},
+			y:        1,
+			expected: []uint64{6, 5, 4},
+		},
+		{
+			name:     "both layers",
+			segments: [][2]uint64{{1, 5}, {2, 4}, {4, 7}, {5, 6}, {3, 8}, {8, 9}},
+			layers:   [][]uint64{{1, 2, 3}, {8, 4, 5}, {6, 7, 9}},
+			y:        1,
+			expected: []uint64{5, 4, 8},
+		},
+	}
+	for _, o := range optimizers {
+		for _, tc := range tests {
+			t.Run(fmt.Sprintf("%T/%s", o, tc.name), func(t *testing.T) {
+				segments := map[[2]uint64]bool{}
+				for _, e := range tc.segments {
+					segments[e] = true
+				}
+				layers := make([][]uint64, len(tc.layers))
+				for i, l := range tc.layers {
+					layers[i] = append([]uint64{}, l...)
+				}
+
+				o.Optimize(segments, layers, tc.y, true)
+
+				if fmt.Sprint(layers[tc.y]) != fmt.Sprint(tc.expected) {
+					t.Errorf("expected %v, got %v", tc.expected, layers[tc.y])
+				}
+			})
+		}
+	}
+}
+
+func TestRandomOrderingWithoutRandIsDeterministic(t *testing.T) {
+	segments := map[[2]uint64]bool{{1, 6}: true, {2, 5}: true, {3, 4}: true, {1, 7}: true, {2, 8}: true}
+	for name, o := range map[string]func(layers [][]uint64){
+		"initializer": func(layers [][]uint64) { layout.RandomLayerOrderingInitializer{}.Init(segments, layers) },
+		"optimizer": func(layers [][]uint64) {
+			layout.RandomLayerOrderingOptimizer{Epochs: 5}.Optimize(segments, layers, 1, true)
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var results []string
+			for i := 0; i < 10; i++ {
+				layers := [][]uint64{{1, 2, 3}, {4, 5, 6, 7, 8}}
+				o(layers)
+				results = append(results, fmt.Sprint(layers))
+			}
+			for _, r := range results {
+				if r != results[0] {
+					t.Errorf("expected same orderings, got %v", results)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestWarfieldRandomOrderingWithoutRandSharesSource(t *testing.T) {
+	g := newTestRandomGraph(30, 45)
+	order := func(initializer layout.LayerOrderingInitializer, optimizer layout.LayerOrderingOptimizer) string {
+		lg := layout.NewLayeredGraph(g)
+		layout.WarfieldOrderingOptimizer{
+			Epochs:                   4,
+			LayerOrderingInitializer: initializer,
+			LayerOrderingOptimizer:   layout.CompositeLayerOrderingOptimizer{Optimizers: []layout.LayerOrderingOptimizer{optimizer}},
+		}.Optimize(g, lg)
+		return fmt.Sprint(lg.Layers())
+	}
+
+	r := rand.New(rand.NewSource(1))
+	expected := order(layout.RandomLayerOrderingInitializer{Rand: r}, layout.RandomLayerOrderingOptimizer{Epochs: 3, Rand: r})
+	got := order(layout.RandomLayerOrderingInitializer{}, layout.RandomLayerOrderingOptimizer{Epochs: 3})
+	if got != expected {
+		t.Errorf("expected ordering with one source of randomness for run %v, got %v", expected, got)
+	}
+	if again := order(layout.RandomLayerOrderingInitializer{}, layout.RandomLayerOrderingOptimizer{Epochs: 3}); again != got {
+		t.Errorf("expected same ordering on each run %v, got %v", got, again)
+	}
+}
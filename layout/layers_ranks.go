@@ -0,0 +1,416 @@
+package layout
+
+import (
+	"fmt"
+	"sort"
+)
+
+// RankKind is how nodes of rank constraint are placed in layers, same as rank of subgraph in dot.
+type RankKind uint8
+
+const (
+	RankSame   RankKind = iota // nodes are in same layer
+	RankMin                    // nodes are in top layer, other nodes can be in it too
+	RankSource                 // nodes are in top layer, other nodes are below it
+	RankMax                    // nodes are in bottom layer, other nodes can be in it too
+	RankSink                   // nodes are in bottom layer, other nodes are above it
+)
+
+// RankConstraint tells which nodes are in same layer, and optionally that layer is top or bottom.
+// All nodes of min and source constraints are in same top layer, all nodes of max and sink constraints are in same bottom layer.
+// Edges between nodes in same layer are flat edges.
+type RankConstraint struct {
+	Kind  RankKind
+	Nodes []uint64
+}
+
+// validateRanks checks that rank constraints reference existing nodes and each node is in at most one constraint.
+func (g Graph) validateRanks() error {
+	constraint := make(map[uint64]int)
+	for i, r := range g.Ranks {
+		if r.Kind > RankSink {
+			return RankError{Rank: i, Reason: fmt.Sprintf("has unknown kind(%d)", r.Kind)}
+		}
+		for _, n := range r.Nodes {
+			if _, ok := g.Nodes[n]; !ok {
+				return RankError{Rank: i, Reason: fmt.Sprintf("has missing node(%d)", n)}
+			}
+			if other, ok := constraint[n]; ok {
+				return RankError{Rank: i, Reason: fmt.Sprintf("has node(%d) that is already in rank(%d)", n, other)}
+			}
+			constraint[n] = i
+		}
+	}
+	return nil
+}
+
+// rankGroups are nodes that are in same layer, keyed by smallest node of group.
+type rankGroups struct {
+	rep               map[uint64]uint64 // node -> smallest node of its group, missing for nodes out of groups
+	constraint        map[uint64]int    // smallest node of group -> index of constraint, for errors
+	top, bottom       uint64            // smallest nodes of top and bottom groups
+	hasTop, hasBottom bool
+	source, sink      bool // top and bottom layers have only nodes of their groups
+}
+
+func newRankGroups(g Graph) rankGroups {
+	r := rankGroups{rep: make(map[uint64]uint64), constraint: make(map[uint64]int)}
+
+	// min and source constraints are one group, max and sink constraints are one group
+	var top, bottom []uint64
+	topIdx, bottomIdx := -1, -1
+	for i, c := range g.Ranks {
+		switch c.Kind {
+		case RankSame:
+			r.add(c.Nodes, i)
+		case RankMin, RankSource:
+			top = append(top, c.Nodes...)
+			r.source = r.source || c.Kind == RankSource
+			if topIdx < 0 {
+				topIdx = i
+			}
+		case RankMax, RankSink:
+			bottom = append(bottom, c.Nodes...)
+			r.sink = r.sink || c.Kind == RankSink
+			if bottomIdx < 0 {
+				bottomIdx = i
+			}
+		}
+	}
+	r.top, r.hasTop = r.add(top, topIdx)
+	r.bottom, r.hasBottom = r.add(bottom, bottomIdx)
+	return r
+}
+
+func (r rankGroups) add(nodes []uint64, constraint int) (rep uint64, ok bool) {
+	if len(nodes) == 0 {
+		return 0, false
+	}
+	rep = nodes[0]
+	for _, n := range nodes {
+		if n < rep {
+			rep = n
+		}
+	}
+	for _, n := range nodes {
+		r.rep[n] = rep
+	}
+	r.constraint[rep] = constraint
+	return rep, true
+}
+
+func (r rankGroups) of(n uint64) uint64 {
+	if rep, ok := r.rep[n]; ok {
+		return rep
+	}
+	return n
+}
+
+// merge is graph where nodes of each group are merged into single node, without edges within groups.
+func (r rankGroups) merge(g Graph) Graph {
+	merged := Graph{Nodes: make(map[uint64]Node, len(g.Nodes)), Edges: make(map[EdgeID]Edge, len(g.Edges))}
+	for _, n := range g.nodeIDs() {
+		merged.Nodes[r.of(n)] = g.Nodes[r.of(n)]
+	}
+	for _, e := range g.edgeIDs() {
+		from, to := r.of(e[0]), r.of(e[1])
+		if from == to {
+			continue
+		}
+		me := EdgeID{from, to}
+		for _, ok := merged.Edges[me]; ok; _, ok = merged.Edges[me] {
+			me[2]++
+		}
+		merged.Edges[me] = g.Edges[e]
+	}
+	return merged
+}
+
+// removeRankCycles reverses edges that make cycles when nodes of groups are merged, as dot does.
+// Cycle remover picks edges to reverse in graph of merged nodes, then all edges between same merged nodes go in same direction.
+// Edges within groups are flat, they are not reversed and may make cycles.
+// Returns reversed edges, original edge -> reversed edge.
+func removeRankCycles(g Graph, cr CycleRemover) map[EdgeID]EdgeID {
+	r := newRankGroups(g)
+	merged := r.merge(g)
+	cr.RemoveCycles(merged)
+	forward := make(map[[2]uint64]bool, len(merged.Edges))
+	for e := range merged.Edges {
+		forward[[2]uint64{e[0], e[1]}] = true
+	}
+	cr.Restore(merged)
+
+	reversed := make(map[EdgeID]EdgeID)
+	for _, e := range g.edgeIDs() {
+		from, to := r.of(e[0]), r.of(e[1])
+		if from == to || forward[[2]uint64{from, to}] {
+			continue
+		}
+		rev := reversedEdgeID(g, e, reversed)
+		reverseEdge(g, e, rev)
+		reversed[e] = rev
+	}
+	return reversed
+}
+
+// rankCycleNodes are nodes in cycles, with rank constraints cycles are in graph with merged nodes of each group.
+// Flat edges within groups do not make cycles.
+func rankCycleNodes(g Graph) []uint64 {
+	if len(g.Ranks) == 0 {
+		return g.cycleNodes()
+	}
+	r := newRankGroups(g)
+	inCycle := make(map[uint64]bool)
+	for _, n := range r.merge(g).cycleNodes() {
+		inCycle[n] = true
+	}
+	var nodes []uint64
+	for _, n := range g.nodeIDs() {
+		if inCycle[r.of(n)] {
+			nodes = append(nodes, n)
+		}
+	}
+	return nodes
+}
+
+// rankConstrainedLevels assigns layers with levels assigner, so that rank constraints hold.
+// Nodes of each group are merged into single node for levels assigner, edges within groups become flat edges.
+// Returns error when constraints conflict with directions of edges.
+func rankConstrainedLevels(g Graph, assigner func(g Graph) LayeredGraph) (LayeredGraph, error) {
+	if len(g.Ranks) == 0 {
+		return assigner(g), nil
+	}
+	r := newRankGroups(g)
+	merged := r.merge(g)
+
+	for _, e := range merged.edgeIDs() {
+		if r.hasTop && e[1] == r.top {
+			return LayeredGraph{}, RankError{Rank: r.constraint[r.top], Reason: fmt.Sprintf("is top layer but has edge from node(%d)", e[0])}
+		}
+		if r.hasBottom && e[0] == r.bottom {
+			return LayeredGraph{}, RankError{Rank: r.constraint[r.bottom], Reason: fmt.Sprintf("is bottom layer but has edge to node(%d)", e[1])}
+		}
+	}
+
+	lg := assigner(merged)
+	level := make(map[uint64]int, len(merged.Nodes))
+	for n := range merged.Nodes {
+		level[n] = lg.NodeYX[n][0]
+	}
+
+	// top group does not have incoming edges, so it can be moved up, other nodes can be moved down
+	if r.hasTop {
+		level[r.top] = 0
+		if r.source {
+			for n := range level {
+				if n != r.top {
+					level[n]++
+				}
+			}
+		}
+	}
+	if r.hasBottom {
+		maxLevel, others := 0, false
+		for n, l := range level {
+			if l > maxLevel {
+				maxLevel, others = l, false
+			}
+			if l == maxLevel && n != r.bottom {
+				others = true
+			}
+		}
+		level[r.bottom] = maxLevel
+		if r.sink && others {
+			level[r.bottom] = maxLevel + 1
+		}
+	}
+
+	// without empty layers that no edge needs, edges keep spans up to their minimal lengths
+	var levels []int
+	seen := make(map[int]bool)
+	for _, l := range level {
+		if !seen[l] {
+			seen[l] = true
+			levels = append(levels, l)
+		}
+	}
+	sort.Ints(levels)
+	into := make(map[int][]EdgeID)
+	for _, e := range merged.edgeIDs() {
+		into[level[e[1]]] = append(into[level[e[1]]], e)
+	}
+	layer := make(map[int]int, len(levels))
+	for i, l := range levels {
+		if i > 0 {
+			layer[l] = layer[levels[i-1]] + 1
+		}
+		for _, e := range into[l] {
+			span := minInt(maxInt(merged.Edges[e].MinLen, 1), l-level[e[0]])
+			layer[l] = maxInt(layer[l], layer[level[e[0]]]+span)
+		}
+	}
+
+	nodeYX := make(map[uint64][2]int, len(g.Nodes))
+	for n := range g.Nodes {
+		nodeYX[n] = [2]int{layer[level[r.of(n)]], 0}
+	}
+	return newLayeredGraph(g, nodeYX), nil
+}
+
+// isFlat is when edge is between nodes in same layer.
+func (g LayeredGraph) isFlat(nodes []uint64) bool {
+	return len(nodes) == 2 && g.NodeYX[nodes[0]][0] == g.NodeYX[nodes[1]][0]
+}
+
+// orderFlatEdges keeps order of layers, except that source of each flat edge is left of its target, as in dot.
+// When flat edges make cycle, leftmost node of cycle is first.
+func orderFlatEdges(lg LayeredGraph, layers [][]uint64) {
+	next := make(map[uint64][]uint64)
+	indegree := make(map[uint64]int)
+	for _, nodes := range lg.Edges {
+		if lg.isFlat(nodes) {
+			next[nodes[0]] = append(next[nodes[0]], nodes[1])
+			indegree[nodes[1]]++
+		}
+	}
+	if len(next) == 0 {
+		return
+	}
+
+	for _, layer := range layers {
+		// topological order that takes leftmost available node first
+		order := make([]uint64, 0, len(layer))
+		done := make(map[uint64]bool, len(layer))
+		for len(order) < len(layer) {
+			k := -1
+			for i, n := range layer {
+				if !done[n] && (k < 0 || indegree[n] == 0 && indegree[layer[k]] != 0) {
+					k = i
+				}
+			}
+			n := layer[k]
+			done[n] = true
+			order = append(order, n)
+			for _, m := range next[n] {
+				indegree[m]--
+			}
+		}
+		copy(layer, order)
+	}
+}
+
+// flatRoutes are distances of routes of flat edges from their layers, and space that routes need next to each layer.
+// Flat edges that have nodes between their ends or have labels are routed around layer.
+// Routes that overlap along layer are at different distances, shorter routes closer to layer,
+// and each route has space for its label between it and next route. Sizes are as if layers go top to bottom.
+func flatRoutes(g Graph, lg LayeredGraph) (distance map[EdgeID]int, space map[int]int) {
+	type route struct {
+		e      EdgeID
+		lo, hi int
+	}
+	routes := make(map[int][]route)
+	for _, e := range g.edgeIDs() {
+		nodes, ok := lg.Edges[e]
+		if !ok || !lg.isFlat(nodes) {
+			continue
+		}
+		a, b := lg.NodeYX[e[0]][1], lg.NodeYX[e[1]][1]
+		if (a-b == 1 || b-a == 1) && g.Edges[e].Label.isEmpty() {
+			continue
+		}
+		layer := lg.NodeYX[e[0]][0]
+		routes[layer] = append(routes[layer], route{e: e, lo: minInt(a, b), hi: maxInt(a, b)})
+	}
+
+	distance = make(map[EdgeID]int)
+	space = make(map[int]int, len(routes))
+	for layer, rs := range routes {
+		sort.SliceStable(rs, func(i, j int) bool { return rs[i].hi-rs[i].lo < rs[j].hi-rs[j].lo })
+
+		// track of route is next after tracks of shorter routes that overlap it
+		track := make([]int, len(rs))
+		var heights []int
+		for i, r := range rs {
+			for j := 0; j < i; j++ {
+				if rs[j].lo <= r.hi && r.lo <= rs[j].hi && track[j] >= track[i] {
+					track[i] = track[j] + 1
+				}
+			}
+			if track[i] == len(heights) {
+				heights = append(heights, 0)
+			}
+			heights[track[i]] = maxInt(heights[track[i]], selfLoopSize+g.Edges[r.e].Label.H)
+		}
+
+		offsets := make([]int, len(heights)+1)
+		for t, h := range heights {
+			offsets[t+1] = offsets[t] + h
+		}
+		for i, r := range rs {
+			distance[r.e] = offsets[track[i]] + selfLoopSize
+		}
+		space[layer] = offsets[len(heights)]
+	}
+	return distance, space
+}
+
+// shiftFlatLayers moves layers apart, so that there is space for routes of flat edges next to layers.
+// Space is before its layer, or after it when layers are mirrored in final layout.
+func shiftFlatLayers(lg LayeredGraph, nodeY map[uint64]int, space map[int]int, after bool) {
+	if len(space) == 0 {
+		return
+	}
+	for n, y := range nodeY {
+		layer := lg.NodeYX[n][0]
+		for l, s := range space {
+			if l < layer || (l == layer && !after) {
+				y += s
+			}
+		}
+		nodeY[n] = y
+	}
+}
+
+// flatEdgePaths routes flat edges around layer at their distances from layer,
+// on side of smaller coordinates across layers. Labels are after routes.
+// Curves are set for edges that already have curves.
+func flatEdgePaths(g Graph, lg LayeredGraph, allNodesXY map[uint64][2]int, rankAxis int, distance map[EdgeID]int) {
+	layers := lg.Layers()
+	along := 1 - rankAxis
+
+	// coordinate of layer boxes on side of smaller coordinates
+	top := make([]int, len(layers))
+	for i, layer := range layers {
+		for j, n := range layer {
+			size := [2]int{g.Nodes[n].W, g.Nodes[n].H}
+			if v := allNodesXY[n][rankAxis] - size[rankAxis]/2; j == 0 || v < top[i] {
+				top[i] = v
+			}
+		}
+	}
+
+	for _, e := range g.edgeIDs() {
+		d, ok := distance[e]
+		if !ok {
+			continue
+		}
+		edge := g.Edges[e]
+		path := edgePolyline(g, e, lg.Edges[e], allNodesXY)
+		v := top[lg.NodeYX[e[0]][0]] - d
+		from, to := path[0], path[1]
+		from[rankAxis], to[rankAxis] = v, v
+		path = [][2]int{path[0], from, to, path[1]}
+
+		if !edge.Label.isEmpty() {
+			size := [2]int{edge.Label.W, edge.Label.H}
+			edge.Label.XY[rankAxis] = v - size[rankAxis]
+			edge.Label.XY[along] = (from[along]+to[along])/2 - size[along]/2
+		}
+
+		edge.Path = path
+		if edge.Curve != nil {
+			edge.Curve = splineCurve(path, -1)
+		}
+		g.Edges[e] = edge
+	}
+}
@@ -0,0 +1,253 @@
+package layout_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/nikolaydubina/go-graph-layout/layout"
+)
+
+func newTestRanksGraph(ranks ...layout.RankConstraint) layout.Graph {
+	g := layout.Graph{
+		Nodes: map[uint64]layout.Node{},
+		Edges: map[layout.EdgeID]layout.Edge{},
+		Ranks: ranks,
+	}
+	for n := uint64(1); n <= 7; n++ {
+		g.Nodes[n] = layout.Node{W: 30, H: 20}
+	}
+	for _, e := range []layout.EdgeID{{1, 2}, {2, 3}, {1, 4}, {4, 5}, {5, 6}, {3, 6}, {2, 5}, {1, 7}} {
+		g.Edges[e] = layout.Edge{}
+	}
+	return g
+}
+
+// checkFlatEdges checks that flat edges go from left to right and do not go through other nodes.
+func checkFlatEdges(t *testing.T, g layout.Graph) {
+	t.Helper()
+	for e, edge := range g.Edges {
+		from, to := g.Nodes[e[0]].CenterXY(), g.Nodes[e[1]].CenterXY()
+		if from[1] != to[1] {
+			continue
+		}
+		if from[0] >= to[0] {
+			t.Errorf("flat edge %v goes from right to left", e)
+		}
+		for i := 1; i < len(edge.Path); i++ {
+			for n, node := range g.Nodes {
+				if n != e[0] && n != e[1] && crossesNode(edge.Path[i-1], edge.Path[i], node) {
+					t.Errorf("flat edge %v goes through node %d", e, n)
+				}
+			}
+		}
+	}
+}
+
+func TestSugiyamaLayersStrategyGraphLayout_Ranks(t *testing.T) {
+	y := func(g layout.Graph, n uint64) int { return g.Nodes[n].CenterXY()[1] }
+
+	tests := []struct {
+		name  string
+		ranks []layout.RankConstraint
+		check func(g layout.Graph) error
+	}{
+		{
+			name:  "same",
+			ranks: []layout.RankConstraint{{Kind: layout.RankSame, Nodes: []uint64{2, 4, 5}}},
+			check: func(g layout.Graph) error {
+				if y(g, 2) != y(g, 4) || y(g, 2) != y(g, 5) {
+					return fmt.Errorf("nodes are in different layers %d %d %d", y(g, 2), y(g, 4), y(g, 5))
+				}
+				return nil
+			},
+		},
+		{
+			name:  "same with path between",
+			ranks: []layout.RankConstraint{{Kind: layout.RankSame, Nodes: []uint64{1, 3}}},
+			check: func(g layout.Graph) error {
+				if y(g, 1) != y(g, 3) {
+					return fmt.Errorf("nodes are in different layers %d %d", y(g, 1), y(g, 3))
+				}
+				return nil
+			},
+		},
+		{
+			name:  "min",
+			ranks: []layout.RankConstraint{{Kind: layout.RankMin, Nodes: []uint64{4}}, {Kind: layout.RankMin, Nodes: []uint64{1}}},
+			check: func(g layout.Graph) error {
+				if y(g, 1) != y(g, 4) {
+					return fmt.Errorf("nodes are in different layers %d %d", y(g, 1), y(g, 4))
+				}
+				return nil
+			},
+		},
+		{
+			name:  "source",
+			ranks: []layout.RankConstraint{{Kind: layout.RankSource, Nodes: []uint64{1}}},
+			check: func(g layout.Graph) error {
+				for n := range g.Nodes {
+					if n != 1 && y(g, n) <= y(g, 1) {
+						return fmt.Errorf("node %d is not below source", n)
+					}
+				}
+				return nil
+			},
+		},
+		{
+			name:  "max",
+			ranks: []layout.RankConstraint{{Kind: layout.RankMax, Nodes: []uint64{7}}},
+			check: func(g layout.Graph) error {
+				for n := range g.Nodes {
+					if y(g, n) > y(g, 7) {
+						return fmt.Errorf("node %d is below max", n)
+					}
+				}
+				return nil
+			},
+		},
+		{
+			name:  "sink",
+			ranks: []layout.RankConstraint{{Kind: layout.RankSink, Nodes: []uint64{7, 6}}},
+			check: func(g layout.Graph) error {
+				for n := range g.Nodes {
+					if n != 7 && n != 6 && y(g, n) >= y(g, 7) {
+						return fmt.Errorf("node %d is not above sink", n)
+					}
+				}
+				if y(g, 6) != y(g, 7) {
+					return fmt.Errorf("sink nodes are in different layers")
+				}
+				return nil
+			},
+		},
+	}
+
+	assigners := map[string]func(g layout.Graph) layout.LayeredGraph{
+		"longest path":    layout.NewLayeredGraph,
+		"coffman graham":  layout.CoffmanGrahamLayersAssigner{MaxWidth: 2}.AssignLevels,
+		"network simplex": layout.NetworkSimplexLayersAssigner{}.AssignLevels,
+	}
+
+	for _, tc := range tests {
+		for name, assigner := range assigners {
+			for _, d := range []layout.RankDirection{layout.TopToBottom, layout.LeftToRight} {
+				t.Run(fmt.Sprintf("%s %s direction(%d)", tc.name, name, d), func(t *testing.T) {
+					g := newTestRanksGraph(tc.ranks...)
+					l := newTestSugiyamaLayout()
+					l.LevelsAssigner = assigner
+					l.RankDirection = d
+					if err := l.UpdateGraphLayoutE(g); err != nil {
+						t.Fatal(err)
+					}
+					if d == layout.LeftToRight {
+						// check as if layers go top to bottom
+						for n, node := range g.Nodes {
+							node.XY[0], node.XY[1], node.W, node.H = node.XY[1], node.XY[0], node.H, node.W
+							g.Nodes[n] = node
+						}
+						for e, edge := range g.Edges {
+							for i, p := range edge.Path {
+								edge.Path[i] = [2]int{p[1], p[0]}
+							}
+							g.Edges[e] = edge
+						}
+					}
+					if err := tc.check(g); err != nil {
+						t.Error(err)
+					}
+					checkFlatEdges(t, g)
+				})
+			}
+		}
+	}
+
+	t.Run("flat edge label", func(t *testing.T) {
+		g := newTestRanksGraph(layout.RankConstraint{Kind: layout.RankSame, Nodes: []uint64{2, 5}})
+		g.Edges[layout.EdgeID{2, 5}] = layout.Edge{Label: layout.Label{W: 20, H: 10}}
+		if err := newTestSugiyamaLayout().UpdateGraphLayoutE(g); err != nil {
+			t.Fatal(err)
+		}
+		// label is on top of route around layer
+		edge := g.Edges[layout.EdgeID{2, 5}]
+		if l, p := edge.Label, edge.Path; len(p) != 4 || l.XY[1]+l.H != p[1][1] || l.XY[0] < p[1][0] || l.XY[0]+l.W > p[2][0] {
+			t.Errorf("label %v is not on path %v", edge.Label, edge.Path)
+		}
+		checkFlatEdges(t, g)
+	})
+
+	t.Run("min len", func(t *testing.T) {
+		span := func(ranks ...layout.RankConstraint) int {
+			g := layout.Graph{
+				Nodes: map[uint64]layout.Node{1: {W: 30, H: 20}, 2: {W: 30, H: 20}, 3: {W: 30, H: 20}, 4: {W: 30, H: 20}},
+				Edges: map[layout.EdgeID]layout.Edge{{1, 2}: {MinLen: 3}},
+				Ranks: ranks,
+			}
+			l := newTestSugiyamaLayout()
+			l.LevelsAssigner = layout.NetworkSimplexLayersAssigner{}.AssignLevels
+			if err := l.UpdateGraphLayoutE(g); err != nil {
+				t.Fatal(err)
+			}
+			return y(g, 2) - y(g, 1)
+		}
+		// unrelated rank constraint does not shorten edge
+		if without, with := span(), span(layout.RankConstraint{Kind: layout.RankSame, Nodes: []uint64{3, 4}}); with != without {
+			t.Errorf("edge with min len spans %d without ranks and %d with ranks", without, with)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for name, ranks := range map[string][]layout.RankConstraint{
+			"missing node":       {{Nodes: []uint64{8}}},
+			"node in two":        {{Nodes: []uint64{1, 2}}, {Kind: layout.RankMax, Nodes: []uint64{2}}},
+			"unknown kind":       {{Kind: 100, Nodes: []uint64{1}}},
+			"min with parent":    {{Kind: layout.RankMin, Nodes: []uint64{2}}},
+			"sink with children": {{Kind: layout.RankSink, Nodes: []uint64{5}}},
+		} {
+			var errRank layout.RankError
+			if err := newTestSugiyamaLayout().UpdateGraphLayoutE(newTestRanksGraph(ranks...)); !errors.As(err, &errRank) {
+				t.Errorf("%s: expected rank error, got %v", name, err)
+			}
+		}
+	})
+
+	t.Run("flat edge routes", func(t *testing.T) {
+		g := layout.Graph{
+			Nodes: map[uint64]layout.Node{},
+			Edges: map[layout.EdgeID]layout.Edge{},
+			Ranks: []layout.RankConstraint{{Kind: layout.RankSame, Nodes: []uint64{1, 2, 3, 4, 5}}},
+		}
+		for n := uint64(0); n <= 5; n++ {
+			g.Nodes[n] = layout.Node{W: 30, H: 20}
+			if n > 0 {
+				g.Edges[layout.EdgeID{0, n}] = layout.Edge{}
+			}
+		}
+		for _, e := range []layout.EdgeID{{1, 3}, {1, 4}, {2, 4}, {2, 5}, {1, 5}} {
+			g.Edges[e] = layout.Edge{Label: layout.Label{W: 10, H: 10}}
+		}
+		if err := newTestSugiyamaLayout().UpdateGraphLayoutE(g); err != nil {
+			t.Fatal(err)
+		}
+		checkFlatEdges(t, g)
+
+		// routes are between layers and do not overlap each other with their labels
+		bottom := g.Nodes[0].XY[1] + g.Nodes[0].H
+		for e, edge := range g.Edges {
+			if len(edge.Path) != 4 {
+				continue
+			}
+			if edge.Label.XY[1] <= bottom {
+				t.Errorf("route of %v with label %v is in layer above", e, edge.Label)
+			}
+			for o, other := range g.Edges {
+				if o == e || len(other.Path) != 4 || other.Path[1][0] > edge.Path[2][0] || edge.Path[1][0] > other.Path[2][0] {
+					continue
+				}
+				if v := other.Path[1][1]; v == edge.Path[1][1] || (v > edge.Label.XY[1] && v < edge.Path[1][1]) {
+					t.Errorf("route of %v at %d overlaps route of %v at %d", o, v, e, edge.Path[1][1])
+				}
+			}
+		}
+	})
+}
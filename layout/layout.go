@@ -1,10 +1,25 @@
 package layout
 
+import "context"
+
 // Layout is something that can update graph layout
 type Layout interface {
 	UpdateGraphLayout(g Graph)
 }
 
+// LayoutE is something that can update graph layout and returns error instead of panic.
+// All layouts in this package implement it, at least by validating graph before layout.
+type LayoutE interface {
+	UpdateGraphLayoutE(g Graph) error
+}
+
+// LayoutContext is something that can update graph layout and stops early when context is done.
+// When stopped early, best layout found so far is kept in graph and this is not an error.
+// Callers can check context error to know if layout was stopped early.
+type LayoutContext interface {
+	UpdateGraphLayoutContext(ctx context.Context, g Graph) error
+}
+
 // SequenceLayout applies sequence of layouts
 type SequenceLayout struct {
 	Layouts []Layout
@@ -15,3 +30,45 @@ func (s SequenceLayout) UpdateGraphLayout(g Graph) {
 		l.UpdateGraphLayout(g)
 	}
 }
+
+// UpdateGraphLayoutE validates graph once and applies sequence of layouts, stops on first error.
+// Layouts that do not implement LayoutE are applied without errors.
+func (s SequenceLayout) UpdateGraphLayoutE(g Graph) error {
+	if err := g.Validate(); err != nil {
+		return err
+	}
+	for _, l := range s.Layouts {
+		if le, ok := l.(LayoutE); ok {
+			if err := le.UpdateGraphLayoutE(g); err != nil {
+				return err
+			}
+			continue
+		}
+		l.UpdateGraphLayout(g)
+	}
+	return nil
+}
+
+// UpdateGraphLayoutContext validates graph once and applies sequence of layouts, stops on first error.
+// Context is passed to layouts that implement LayoutContext.
+// All layouts are applied even if context is done, so that graph has complete layout.
+func (s SequenceLayout) UpdateGraphLayoutContext(ctx context.Context, g Graph) error {
+	if err := g.Validate(); err != nil {
+		return err
+	}
+	for _, l := range s.Layouts {
+		switch l := l.(type) {
+		case LayoutContext:
+			if err := l.UpdateGraphLayoutContext(ctx, g); err != nil {
+				return err
+			}
+		case LayoutE:
+			if err := l.UpdateGraphLayoutE(g); err != nil {
+				return err
+			}
+		default:
+			l.UpdateGraphLayout(g)
+		}
+	}
+	return nil
+}
@@ -2,8 +2,10 @@ package layout_test
 
 import (
 	_ "embed"
+	"errors"
 	"fmt"
 	"os"
+	"sort"
 	"strings"
 	"testing"
 
@@ -21,7 +23,7 @@ func parseJSONLGraph(in string) (*graph.Graph, *layout.Graph, error) {
 
 	gl := layout.Graph{
 		Nodes: make(map[uint64]layout.Node),
-		Edges: make(map[[2]uint64]layout.Edge),
+		Edges: make(map[layout.EdgeID]layout.Edge),
 	}
 
 	for id, node := range gd.Nodes {
@@ -34,18 +36,115 @@ func parseJSONLGraph(in string) (*graph.Graph, *layout.Graph, error) {
 		gl.Nodes[id] = layout.Node{W: rnode.Width(), H: rnode.Height()}
 	}
 
-	for e := range gd.Edges {
-		gl.Edges[e] = layout.Edge{}
+	for e, edata := range gd.Edges {
+		// compute w and h for labels same as for nodes
+		var label layout.Label
+		if text := edgeLabel(edata); text != "" {
+			rlabel := svg.EdgeLabel{Text: text}
+			label = layout.Label{W: rlabel.Width(), H: rlabel.Height()}
+		}
+		gl.Edges[layout.EdgeID{e[0], e[1]}] = layout.Edge{Label: label}
 	}
 
 	return &gd, &gl, nil
 }
 
-func writeSVG(gd graph.Graph, gl layout.Graph) string {
+// pathClusters groups nodes by host and by owner in paths of nodes, such as github.com/nikolaydubina.
+// Prefix makes cluster when it has more than one node and fewer nodes than enclosing cluster.
+func pathClusters(gd graph.Graph) (map[uint64]layout.Cluster, map[uint64]string) {
+	prefixes := func(id string) []string {
+		parts := strings.Split(id, "/")
+		var ps []string
+		for i := 1; i < len(parts) && i <= 2; i++ {
+			ps = append(ps, strings.Join(parts[:i], "/"))
+		}
+		return ps
+	}
+
+	count := map[string]int{}
+	for _, node := range gd.Nodes {
+		for _, p := range prefixes(node.ID()) {
+			count[p]++
+		}
+	}
+	// valid prefixes of node, from top
+	valid := func(id string) []string {
+		var ps []string
+		for _, p := range prefixes(id) {
+			if count[p] > 1 && (len(ps) == 0 || count[p] < count[ps[len(ps)-1]]) {
+				ps = append(ps, p)
+			}
+		}
+		return ps
+	}
+
+	var names []string
+	for p, c := range count {
+		if c > 1 {
+			names = append(names, p)
+		}
+	}
+	sort.Strings(names)
+	ids := map[string]uint64{}
+	for i, p := range names {
+		ids[p] = uint64(i + 1)
+	}
+
+	clusters := map[uint64]layout.Cluster{}
+	titles := map[uint64]string{}
+	for n, node := range gd.Nodes {
+		ps := valid(node.ID())
+		for i, p := range ps {
+			c := clusters[ids[p]]
+			titles[ids[p]] = p
+			if i == len(ps)-1 {
+				c.Nodes = append(c.Nodes, n)
+			}
+			if i > 0 {
+				parent := clusters[ids[ps[i-1]]]
+				if !containsID(parent.Clusters, ids[p]) {
+					parent.Clusters = append(parent.Clusters, ids[p])
+					clusters[ids[ps[i-1]]] = parent
+				}
+			}
+			clusters[ids[p]] = c
+		}
+	}
+	return clusters, titles
+}
+
+func containsID(ids []uint64, id uint64) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
+// edgeLabel is values of edge data except ends of edge, sorted by keys.
+func edgeLabel(edata graph.EdgeData) string {
+	var keys []string
+	for k := range edata {
+		if k != "from" && k != "to" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	var values []string
+	for _, k := range keys {
+		values = append(values, svg.RenderValue(edata[k]))
+	}
+	return strings.Join(values, " ")
+}
+
+// writeSVG renders graph, with arrowheads at ends of edges when arrows is set.
+func writeSVG(gd graph.Graph, gl layout.Graph, arrows bool) string {
 	graph := svg.Graph{
 		ID:    "graph-root",
 		Nodes: map[uint64]svg.Node{},
-		Edges: map[[2]uint64]svg.Edge{},
+		Edges: map[[3]uint64]svg.Edge{},
 	}
 
 	for id, node := range gd.Nodes {
@@ -57,15 +156,43 @@ func writeSVG(gd graph.Graph, gl layout.Graph) string {
 		}
 	}
 
+	head := svg.ArrowNone
+	definitions := []svg.Renderable{}
+	if arrows {
+		head = svg.ArrowNormal
+		definitions = svg.Markers()
+	}
+
 	for e, edata := range gl.Edges {
-		graph.Edges[e] = svg.Edge{
-			Path: edata.Path,
+		edge := svg.Edge{
+			Path:  edata.Path,
+			Curve: edata.Curve,
+			Head:  head,
+		}
+		if edata.Label.W > 0 {
+			edge.Label = svg.EdgeLabel{XY: edata.Label.XY, Text: edgeLabel(gd.Edges[[2]uint64{e[0], e[1]}])}
+		}
+		graph.Edges[[3]uint64(e)] = edge
+	}
+
+	_, titles := pathClusters(gd)
+	graph.Clusters = map[uint64]svg.Cluster{}
+	for c, cluster := range gl.Clusters {
+		if cluster.W == 0 {
+			continue
+		}
+		graph.Clusters[c] = svg.Cluster{
+			ID:    fmt.Sprintf("%d", c),
+			XY:    cluster.XY,
+			W:     cluster.W,
+			H:     cluster.H,
+			Title: titles[c],
 		}
 	}
 
 	svgContainer := svg.SVG{
 		ID:          "svg-root",
-		Definitions: []svg.Renderable{},
+		Definitions: definitions,
 		Body:        graph,
 	}
 	return svgContainer.Render()
@@ -80,6 +207,9 @@ var smallJSONL string
 //go:embed testdata/brandeskopf.jsonl
 var brandeskopfJSONL string
 
+//go:embed testdata/labels.jsonl
+var labelsJSONL string
+
 func TestE2E(t *testing.T) {
 	inputJSONLGraphs := []struct {
 		name            string
@@ -97,11 +227,18 @@ func TestE2E(t *testing.T) {
 			name:            "brandeskopf",
 			inputJSONLGraph: brandeskopfJSONL,
 		},
+		{
+			name:            "labels",
+			inputJSONLGraph: labelsJSONL,
+		},
 	}
 
 	layouts := []struct {
-		name string
-		l    layout.Layout
+		name     string
+		l        layout.Layout
+		clip     bool // edges end at borders of nodes and have arrowheads
+		clusters bool // nodes are grouped to clusters by paths in their ids
+		labels   bool // edges have labels from their data
 	}{
 		{
 			name: "forces",
@@ -127,6 +264,28 @@ func TestE2E(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "fruchterman_reingold",
+			l: layout.SequenceLayout{
+				Layouts: []layout.Layout{
+					layout.FruchtermanReingoldLayout{
+						Cooling:  layout.CoolingAdaptive,
+						MaxSteps: 500,
+						Epsilon:  0.5,
+					},
+					layout.DirectEdgesLayout{},
+				},
+			},
+		},
+		{
+			name: "stress",
+			l: layout.SequenceLayout{
+				Layouts: []layout.Layout{
+					layout.StressMajorizationLayout{MaxSteps: 300},
+					layout.DirectEdgesLayout{},
+				},
+			},
+		},
 		{
 			name: "eades",
 			l: layout.SequenceLayout{
@@ -143,6 +302,41 @@ func TestE2E(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "eades_overlap_removal",
+			l: layout.SequenceLayout{
+				Layouts: []layout.Layout{
+					layout.EadesGonumLayout{
+						Repulsion: 1,
+						Rate:      0.05,
+						Updates:   30,
+						Theta:     0.2,
+						ScaleX:    0.5,
+						ScaleY:    0.5,
+					},
+					layout.OverlapRemovalLayout{Gap: 10},
+					layout.DirectEdgesLayout{},
+				},
+			},
+		},
+		{
+			name: "eades_labels",
+			l: layout.SequenceLayout{
+				Layouts: []layout.Layout{
+					layout.EadesGonumLayout{
+						Repulsion: 1,
+						Rate:      0.05,
+						Updates:   30,
+						Theta:     0.2,
+						ScaleX:    0.5,
+						ScaleY:    0.5,
+					},
+					layout.DirectEdgesLayout{},
+					layout.EdgeLabelsLayout{Margin: 5},
+				},
+			},
+			labels: true,
+		},
 		{
 			name: "isomap",
 			l: layout.SequenceLayout{
@@ -155,6 +349,34 @@ func TestE2E(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "isomap_clip",
+			l: layout.SequenceLayout{
+				Layouts: []layout.Layout{
+					layout.IsomapR2GonumLayout{
+						ScaleX: 0.5,
+						ScaleY: 0.5,
+					},
+					layout.DirectEdgesLayout{},
+				},
+			},
+			clip: true,
+		},
+		{
+			name: "isomap_obstacles",
+			l: layout.SequenceLayout{
+				Layouts: []layout.Layout{
+					layout.IsomapR2GonumLayout{
+						ScaleX: 0.5,
+						ScaleY: 0.5,
+					},
+					layout.ObstacleAvoidingEdgesLayout{
+						Margin: 10,
+						Smooth: true,
+					},
+				},
+			},
+		},
 		{
 			name: "layers",
 			l: layout.SugiyamaLayersStrategyGraphLayout{
@@ -180,18 +402,243 @@ func TestE2E(t *testing.T) {
 				EdgePathAssigner: layout.StraightEdgePathAssigner{}.UpdateGraphLayout,
 			},
 		},
+		{
+			name: "layers_simplex",
+			l: layout.SugiyamaLayersStrategyGraphLayout{
+				CycleRemover:   layout.NewGreedyCycleRemover(),
+				LevelsAssigner: layout.NetworkSimplexLayersAssigner{Balance: true}.AssignLevels,
+				OrderingAssigner: layout.WarfieldOrderingOptimizer{
+					Epochs:                   100,
+					LayerOrderingInitializer: layout.BFSOrderingInitializer{},
+					LayerOrderingOptimizer: layout.CompositeLayerOrderingOptimizer{
+						Optimizers: []layout.LayerOrderingOptimizer{
+							layout.WMedianOrderingOptimizer{},
+							layout.SwitchAdjacentOrderingOptimizer{},
+						},
+					},
+				}.Optimize,
+				NodesHorizontalCoordinatesAssigner: layout.BrandesKopfLayersNodesHorizontalAssigner{
+					Delta: 25,
+				},
+				NodesVerticalCoordinatesAssigner: layout.BasicNodesVerticalCoordinatesAssigner{
+					MarginLayers:   25,
+					FakeNodeHeight: 25,
+				},
+				EdgePathAssigner: layout.StraightEdgePathAssigner{}.UpdateGraphLayout,
+			},
+		},
+		{
+			name: "layers_sifting",
+			l: layout.SugiyamaLayersStrategyGraphLayout{
+				CycleRemover:   layout.NewGreedyCycleRemover(),
+				LevelsAssigner: layout.NewLayeredGraph,
+				OrderingAssigner: layout.WarfieldOrderingOptimizer{
+					Epochs:                   100,
+					LayerOrderingInitializer: layout.BFSOrderingInitializer{},
+					LayerOrderingOptimizer: layout.CompositeLayerOrderingOptimizer{
+						Optimizers: []layout.LayerOrderingOptimizer{
+							layout.BarycenterOrderingOptimizer{},
+							layout.SiftingOrderingOptimizer{},
+						},
+					},
+				}.Optimize,
+				NodesHorizontalCoordinatesAssigner: layout.BrandesKopfLayersNodesHorizontalAssigner{
+					Delta: 25,
+				},
+				NodesVerticalCoordinatesAssigner: layout.BasicNodesVerticalCoordinatesAssigner{
+					MarginLayers:   25,
+					FakeNodeHeight: 25,
+				},
+				EdgePathAssigner: layout.StraightEdgePathAssigner{}.UpdateGraphLayout,
+			},
+		},
+		{
+			name: "layers_lr",
+			l: layout.SugiyamaLayersStrategyGraphLayout{
+				CycleRemover:   layout.NewGreedyCycleRemover(),
+				LevelsAssigner: layout.NetworkSimplexLayersAssigner{Balance: true}.AssignLevels,
+				OrderingAssigner: layout.WarfieldOrderingOptimizer{
+					Epochs:                   100,
+					LayerOrderingInitializer: layout.BFSOrderingInitializer{},
+					LayerOrderingOptimizer: layout.CompositeLayerOrderingOptimizer{
+						Optimizers: []layout.LayerOrderingOptimizer{
+							layout.WMedianOrderingOptimizer{},
+							layout.SwitchAdjacentOrderingOptimizer{},
+						},
+					},
+				}.Optimize,
+				NodesHorizontalCoordinatesAssigner: layout.BrandesKopfLayersNodesHorizontalAssigner{
+					Delta: 25,
+				},
+				NodesVerticalCoordinatesAssigner: layout.BasicNodesVerticalCoordinatesAssigner{
+					MarginLayers:   50,
+					FakeNodeHeight: 25,
+				},
+				EdgePathAssigner: layout.StraightEdgePathAssigner{}.UpdateGraphLayout,
+				RankDirection:    layout.LeftToRight,
+			},
+		},
+		{
+			name: "layers_spline",
+			l: layout.SugiyamaLayersStrategyGraphLayout{
+				CycleRemover:   layout.NewGreedyCycleRemover(),
+				LevelsAssigner: layout.NetworkSimplexLayersAssigner{Balance: true}.AssignLevels,
+				OrderingAssigner: layout.WarfieldOrderingOptimizer{
+					Epochs:                   100,
+					LayerOrderingInitializer: layout.BFSOrderingInitializer{},
+					LayerOrderingOptimizer: layout.CompositeLayerOrderingOptimizer{
+						Optimizers: []layout.LayerOrderingOptimizer{
+							layout.WMedianOrderingOptimizer{},
+							layout.SwitchAdjacentOrderingOptimizer{},
+						},
+					},
+				}.Optimize,
+				NodesHorizontalCoordinatesAssigner: layout.BrandesKopfLayersNodesHorizontalAssigner{
+					Delta: 25,
+				},
+				NodesVerticalCoordinatesAssigner: layout.BasicNodesVerticalCoordinatesAssigner{
+					MarginLayers:   50,
+					FakeNodeHeight: 25,
+				},
+				EdgePathAssigner: layout.SplineEdgePathAssigner{}.UpdateGraphLayout,
+			},
+		},
+		{
+			name: "layers_spline_clip",
+			l: layout.SugiyamaLayersStrategyGraphLayout{
+				CycleRemover:   layout.NewGreedyCycleRemover(),
+				LevelsAssigner: layout.NetworkSimplexLayersAssigner{Balance: true}.AssignLevels,
+				OrderingAssigner: layout.WarfieldOrderingOptimizer{
+					Epochs:                   100,
+					LayerOrderingInitializer: layout.BFSOrderingInitializer{},
+					LayerOrderingOptimizer: layout.CompositeLayerOrderingOptimizer{
+						Optimizers: []layout.LayerOrderingOptimizer{
+							layout.WMedianOrderingOptimizer{},
+							layout.SwitchAdjacentOrderingOptimizer{},
+						},
+					},
+				}.Optimize,
+				NodesHorizontalCoordinatesAssigner: layout.BrandesKopfLayersNodesHorizontalAssigner{
+					Delta: 25,
+				},
+				NodesVerticalCoordinatesAssigner: layout.BasicNodesVerticalCoordinatesAssigner{
+					MarginLayers:   50,
+					FakeNodeHeight: 25,
+				},
+				EdgePathAssigner: layout.SplineEdgePathAssigner{}.UpdateGraphLayout,
+			},
+			clip: true,
+		},
+		{
+			name: "layers_orthogonal",
+			l: layout.SugiyamaLayersStrategyGraphLayout{
+				CycleRemover:   layout.NewGreedyCycleRemover(),
+				LevelsAssigner: layout.NetworkSimplexLayersAssigner{Balance: true}.AssignLevels,
+				OrderingAssigner: layout.WarfieldOrderingOptimizer{
+					Epochs:                   100,
+					LayerOrderingInitializer: layout.BFSOrderingInitializer{},
+					LayerOrderingOptimizer: layout.CompositeLayerOrderingOptimizer{
+						Optimizers: []layout.LayerOrderingOptimizer{
+							layout.WMedianOrderingOptimizer{},
+							layout.SwitchAdjacentOrderingOptimizer{},
+						},
+					},
+				}.Optimize,
+				NodesHorizontalCoordinatesAssigner: layout.BrandesKopfLayersNodesHorizontalAssigner{
+					Delta: 25,
+				},
+				NodesVerticalCoordinatesAssigner: layout.BasicNodesVerticalCoordinatesAssigner{
+					MarginLayers:   50,
+					FakeNodeHeight: 25,
+				},
+				EdgePathAssigner: layout.OrthogonalEdgesLayout{Margin: 10, Spacing: 5}.AssignEdgePaths,
+			},
+		},
+		{
+			name: "layers_clusters",
+			l: layout.SugiyamaLayersStrategyGraphLayout{
+				CycleRemover:   layout.NewGreedyCycleRemover(),
+				LevelsAssigner: layout.NetworkSimplexLayersAssigner{Balance: true}.AssignLevels,
+				OrderingAssigner: layout.WarfieldOrderingOptimizer{
+					Epochs:                   100,
+					LayerOrderingInitializer: layout.BFSOrderingInitializer{},
+					LayerOrderingOptimizer: layout.CompositeLayerOrderingOptimizer{
+						Optimizers: []layout.LayerOrderingOptimizer{
+							layout.WMedianOrderingOptimizer{},
+							layout.SwitchAdjacentOrderingOptimizer{},
+						},
+					},
+				}.Optimize,
+				NodesHorizontalCoordinatesAssigner: layout.BrandesKopfLayersNodesHorizontalAssigner{
+					Delta: 25,
+				},
+				NodesVerticalCoordinatesAssigner: layout.BasicNodesVerticalCoordinatesAssigner{
+					MarginLayers:   50,
+					FakeNodeHeight: 25,
+				},
+				EdgePathAssigner: layout.StraightEdgePathAssigner{}.UpdateGraphLayout,
+				ClusterPadding:   15,
+			},
+			clusters: true,
+		},
+		{
+			name: "layers_labels",
+			l: layout.SugiyamaLayersStrategyGraphLayout{
+				CycleRemover:   layout.NewGreedyCycleRemover(),
+				LevelsAssigner: layout.NetworkSimplexLayersAssigner{Balance: true}.AssignLevels,
+				OrderingAssigner: layout.WarfieldOrderingOptimizer{
+					Epochs:                   100,
+					LayerOrderingInitializer: layout.BFSOrderingInitializer{},
+					LayerOrderingOptimizer: layout.CompositeLayerOrderingOptimizer{
+						Optimizers: []layout.LayerOrderingOptimizer{
+							layout.WMedianOrderingOptimizer{},
+							layout.SwitchAdjacentOrderingOptimizer{},
+						},
+					},
+				}.Optimize,
+				NodesHorizontalCoordinatesAssigner: layout.BrandesKopfLayersNodesHorizontalAssigner{
+					Delta: 25,
+				},
+				NodesVerticalCoordinatesAssigner: layout.BasicNodesVerticalCoordinatesAssigner{
+					MarginLayers:   50,
+					FakeNodeHeight: 25,
+				},
+				EdgePathAssigner: layout.StraightEdgePathAssigner{}.UpdateGraphLayout,
+			},
+			labels: true,
+		},
 	}
 	for _, inputJSONLGraph := range inputJSONLGraphs {
 		for _, l := range layouts {
 			name := fmt.Sprintf("testdata/%s_%s.svg", inputJSONLGraph.name, l.name)
+			if l.clip {
+				l.l = layout.SequenceLayout{Layouts: []layout.Layout{l.l, layout.ClipEdgesLayout{}}}
+			}
 			t.Run(name, func(t *testing.T) {
-				gd, gl, err := parseJSONLGraph(inputJSONLGraph.inputJSONLGraph)
-				if err != nil {
-					t.Error(err)
+				layoutSVG := func() string {
+					gd, gl, err := parseJSONLGraph(inputJSONLGraph.inputJSONLGraph)
+					if err != nil {
+						t.Error(err)
+					}
+					if l.clusters {
+						gl.Clusters, _ = pathClusters(*gd)
+					}
+					if !l.labels {
+						for e, edge := range gl.Edges {
+							edge.Label = layout.Label{}
+							gl.Edges[e] = edge
+						}
+					}
+
+					l.l.UpdateGraphLayout(*gl)
+					return writeSVG(*gd, *gl, l.clip)
 				}
+				svgResult := layoutSVG()
 
-				l.l.UpdateGraphLayout(*gl)
-				svgResult := writeSVG(*gd, *gl)
+				// same input has to produce same output
+				if svgAgain := layoutSVG(); svgAgain != svgResult {
+					t.Errorf("layout is not deterministic")
+				}
 
 				outputfile, err := os.Create(name)
 				if err != nil {
@@ -208,3 +655,37 @@ func TestE2E(t *testing.T) {
 		}
 	}
 }
+
+func TestLayoutE(t *testing.T) {
+	layouts := map[string]layout.LayoutE{
+		"direct":     layout.DirectEdgesLayout{},
+		"clip":       layout.ClipEdgesLayout{},
+		"labels":     layout.EdgeLabelsLayout{},
+		"obstacles":  layout.ObstacleAvoidingEdgesLayout{},
+		"orthogonal": layout.OrthogonalEdgesLayout{},
+		"overlap":    layout.OverlapRemovalLayout{},
+		"scaler":     &layout.ScalerLayout{Scale: 2},
+		"force":      layout.ForceGraphLayout{MaxSteps: 1},
+		"fr":         layout.FruchtermanReingoldLayout{},
+		"stress":     layout.StressMajorizationLayout{},
+		"eades":      layout.EadesGonumLayout{Updates: 1},
+		"isomap":     layout.IsomapR2GonumLayout{},
+		"layers":     newTestSugiyamaLayout(),
+		"sequence":   layout.SequenceLayout{},
+	}
+	for name, l := range layouts {
+		t.Run(name, func(t *testing.T) {
+			g := layout.Graph{
+				Nodes: map[uint64]layout.Node{1: {W: 10, H: 10}},
+				Edges: map[layout.EdgeID]layout.Edge{{1, 2}: {}},
+			}
+
+			err := l.UpdateGraphLayoutE(g)
+
+			var errMissing layout.MissingNodeError
+			if !errors.As(err, &errMissing) || errMissing.Node != 2 {
+				t.Errorf("expected missing node error, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package layout
+
+import (
+	"log"
+	"time"
+)
+
+// Phase is name of layout stage that reports progress.
+type Phase string
+
+const (
+	PhaseCycleRemoval          Phase = "cycle_removal"
+	PhaseLevels                Phase = "levels"
+	PhaseOrdering              Phase = "ordering"
+	PhaseOrderingEpoch         Phase = "ordering_epoch"
+	PhaseHorizontalCoordinates Phase = "horizontal_coordinates"
+	PhaseVerticalCoordinates   Phase = "vertical_coordinates"
+	PhaseEdgePaths             Phase = "edge_paths"
+	PhaseForceStep             Phase = "force_step"
+	PhaseGonumUpdate           Phase = "gonum_update"
+	PhaseStressStep            Phase = "stress_step"
+	PhaseEdgeLabels            Phase = "edge_labels"
+)
+
+// Event is progress of layout.
+// Fields that are not relevant to phase are zero.
+type Event struct {
+	Phase         Phase
+	Step          int           // epoch or step number
+	Crossings     int           // number of crossings of current ordering
+	BestCrossings int           // number of crossings of best ordering so far
+	Energy        float64       // sum of squared forces on nodes
+	Stress        float64       // sum of squared relative differences of distances between nodes from lengths of paths between them
+	Overlaps      int           // number of labels that overlap nodes or other labels
+	Elapsed       time.Duration // since start of phase, or since start of iterations for steps
+}
+
+// Observer receives progress of layouts.
+// Can be used to plot convergence, show progress or log.
+type Observer interface {
+	Observe(e Event)
+}
+
+// ObserverFunc is function that is Observer.
+type ObserverFunc func(e Event)
+
+func (f ObserverFunc) Observe(e Event) { f(e) }
+
+// LogObserver writes events to standard logger.
+type LogObserver struct{}
+
+func (LogObserver) Observe(e Event) {
+	log.Printf("%s:\t step(%d)\t crossings(%d)\t best crossings(%d)\t energy(%f)\t stress(%f)\t overlaps(%d)\t elapsed(%s)\n", e.Phase, e.Step, e.Crossings, e.BestCrossings, e.Energy, e.Stress, e.Overlaps, e.Elapsed)
+}
+
+// observe sends event to observer if it is set.
+func observe(o Observer, e Event) {
+	if o != nil {
+		o.Observe(e)
+	}
+}
@@ -0,0 +1,186 @@
+package layout
+
+import (
+	"math"
+	"sort"
+)
+
+// OverlapRemovalLayout moves nodes so that their boxes do not overlap and have gap between them, keeping order of nodes
+// along axes and moving them little, as in scan of VPSC by Dwyer, Marriott and Stuckey.
+// First nodes that are closer to be separated horizontally than vertically are moved horizontally,
+// then all nodes that still overlap horizontally are moved vertically.
+// Edges are not updated, so edge layout should be after it.
+type OverlapRemovalLayout struct {
+	Gap int // minimal distance between boxes of nodes
+}
+
+func (l OverlapRemovalLayout) UpdateGraphLayout(g Graph) {
+	nodes := g.nodeIDs()
+	for axis := 0; axis < 2; axis++ {
+		// order by centers, ties by id
+		order := make([]int, len(nodes))
+		for i := range order {
+			order[i] = i
+		}
+		center := func(i int) int { return 2*g.Nodes[nodes[i]].XY[axis] + nodeSize(g.Nodes[nodes[i]], axis) }
+		sort.SliceStable(order, func(a, b int) bool { return center(order[a]) < center(order[b]) })
+
+		cs := l.separations(g, nodes, order, axis)
+		if len(cs) == 0 {
+			continue
+		}
+		desired := make([]float64, len(nodes))
+		for i, n := range nodes {
+			desired[i] = float64(g.Nodes[n].XY[axis])
+		}
+		for i, x := range solveSeparations(desired, order, cs) {
+			node := g.Nodes[nodes[i]]
+			node.XY[axis] = int(math.Round(x))
+			g.Nodes[nodes[i]] = node
+		}
+	}
+}
+
+// UpdateGraphLayoutE validates graph and updates its layout.
+func (l OverlapRemovalLayout) UpdateGraphLayoutE(g Graph) error {
+	if err := g.Validate(); err != nil {
+		return err
+	}
+	l.UpdateGraphLayout(g)
+	return nil
+}
+
+// gapConstraint is x[left] + gap <= x[right].
+type gapConstraint struct {
+	left, right int
+	gap         float64
+}
+
+// separations are constraints along axis between nodes that overlap with gap across axis.
+// Horizontally, nodes that overlap more horizontally than vertically are skipped, so they are separated vertically.
+// Left node of separation is earlier in order along axis.
+// time: O(V log V + number of pairs that overlap across axis)
+func (l OverlapRemovalLayout) separations(g Graph, nodes []uint64, order []int, axis int) []gapConstraint {
+	across := 1 - axis
+	lo := func(i int, k int) int { return g.Nodes[nodes[i]].XY[k] }
+	hi := func(i int, k int) int { return g.Nodes[nodes[i]].XY[k] + nodeSize(g.Nodes[nodes[i]], k) + l.Gap }
+
+	rank := make([]int, len(nodes))
+	for r, i := range order {
+		rank[i] = r
+	}
+	sweep := make([]int, len(nodes))
+	for i := range sweep {
+		sweep[i] = i
+	}
+	sort.SliceStable(sweep, func(a, b int) bool { return lo(sweep[a], across) < lo(sweep[b], across) })
+
+	var cs []gapConstraint
+	for a, i := range sweep {
+		for _, j := range sweep[a+1:] {
+			if lo(j, across) >= hi(i, across) {
+				break
+			}
+			if hi(j, across) <= lo(i, across) {
+				continue
+			}
+			left, right := i, j
+			if rank[j] < rank[i] {
+				left, right = j, i
+			}
+			if axis == 0 {
+				// overlaps along axis and across axis, to be separated by smaller move
+				overlap := hi(left, 0) - lo(right, 0)
+				overlapAcross := minInt(hi(i, 1)-lo(j, 1), hi(j, 1)-lo(i, 1))
+				if overlap > 0 && overlap > overlapAcross {
+					continue
+				}
+			}
+			cs = append(cs, gapConstraint{left: left, right: right, gap: float64(nodeSize(g.Nodes[nodes[left]], axis) + l.Gap)})
+		}
+	}
+	return cs
+}
+
+func nodeSize(n Node, axis int) int {
+	if axis == 0 {
+		return n.W
+	}
+	return n.H
+}
+
+// solveSeparations are positions close to desired that satisfy separations.
+// Variables are merged to blocks along violated separations, and each block is at average of desired positions,
+// same as satisfy of VPSC. Variables are processed in order, separations have to go along it.
+// Separations that are still violated are satisfied by moving right variables.
+func solveSeparations(desired []float64, order []int, cs []gapConstraint) []float64 {
+	n := len(desired)
+
+	in := make([][]int, n)
+	for c, s := range cs {
+		in[s.right] = append(in[s.right], c)
+	}
+
+	// variable is at position of its block plus offset
+	block := make([]int, n)
+	offset := make([]float64, n)
+	members := make([][]int, n)
+	pos := make([]float64, n)
+	for i := range desired {
+		block[i] = i
+		members[i] = []int{i}
+		pos[i] = desired[i]
+	}
+	x := func(i int) float64 { return pos[block[i]] + offset[i] }
+
+	for _, v := range order {
+		for {
+			// most violated separation into block from other block
+			b, best, violation := block[v], -1, 1e-9
+			for _, m := range members[b] {
+				for _, c := range in[m] {
+					s := cs[c]
+					if block[s.left] == b {
+						continue
+					}
+					if d := x(s.left) + s.gap - x(s.right); d > violation {
+						best, violation = c, d
+					}
+				}
+			}
+			if best < 0 {
+				break
+			}
+
+			// merge block to block of left variable, so that separation is exact
+			s := cs[best]
+			lb := block[s.left]
+			shift := offset[s.left] + s.gap - offset[s.right]
+			for _, m := range members[b] {
+				offset[m] += shift
+				block[m] = lb
+			}
+			members[lb] = append(members[lb], members[b]...)
+			members[b] = nil
+
+			sum := 0.0
+			for _, m := range members[lb] {
+				sum += desired[m] - offset[m]
+			}
+			pos[lb] = sum / float64(len(members[lb]))
+		}
+	}
+
+	xs := make([]float64, n)
+	for i := range xs {
+		xs[i] = x(i)
+	}
+	for _, v := range order {
+		for _, c := range in[v] {
+			if s := cs[c]; xs[s.left]+s.gap > xs[v] {
+				xs[v] = xs[s.left] + s.gap
+			}
+		}
+	}
+	return xs
+}
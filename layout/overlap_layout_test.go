@@ -0,0 +1,75 @@
+package layout_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/nikolaydubina/go-graph-layout/layout"
+)
+
+// checkNoOverlaps checks that boxes of nodes are at least gap apart.
+func checkNoOverlaps(t *testing.T, g layout.Graph, gap int) {
+	t.Helper()
+	for a, na := range g.Nodes {
+		for b, nb := range g.Nodes {
+			if a >= b {
+				continue
+			}
+			if na.XY[0] < nb.XY[0]+nb.W+gap && nb.XY[0] < na.XY[0]+na.W+gap && na.XY[1] < nb.XY[1]+nb.H+gap && nb.XY[1] < na.XY[1]+na.H+gap {
+				t.Errorf("nodes %d %v and %d %v overlap", a, na, b, nb)
+			}
+		}
+	}
+}
+
+func TestOverlapRemovalLayout(t *testing.T) {
+	t.Run("random", func(t *testing.T) {
+		for _, gap := range []int{0, 5} {
+			t.Run(fmt.Sprintf("gap(%d)", gap), func(t *testing.T) {
+				g := newTestRandomGraph(200, 0)
+				for n, node := range g.Nodes {
+					node.XY = [2]int{node.XY[0] / 5, node.XY[1] / 5}
+					node.W, node.H = 20+int(n%5)*10, 10+int(n%3)*10
+					g.Nodes[n] = node
+				}
+				layout.OverlapRemovalLayout{Gap: gap}.UpdateGraphLayout(g)
+				checkNoOverlaps(t, g, gap)
+			})
+		}
+	})
+
+	t.Run("row", func(t *testing.T) {
+		g := layout.Graph{
+			Nodes: map[uint64]layout.Node{
+				1: {XY: [2]int{0, 0}, W: 20, H: 100},
+				2: {XY: [2]int{10, 0}, W: 20, H: 100},
+				3: {XY: [2]int{20, 0}, W: 20, H: 100},
+			},
+		}
+		layout.OverlapRemovalLayout{Gap: 10}.UpdateGraphLayout(g)
+
+		// moved horizontally, in same order, around same middle
+		for n, x := range map[uint64]int{1: -20, 2: 10, 3: 40} {
+			if xy := g.Nodes[n].XY; xy != [2]int{x, 0} {
+				t.Errorf("node %d: expected %v, got %v", n, [2]int{x, 0}, xy)
+			}
+		}
+	})
+
+	t.Run("no overlaps", func(t *testing.T) {
+		g := layout.Graph{
+			Nodes: map[uint64]layout.Node{
+				1: {XY: [2]int{0, 0}, W: 20, H: 20},
+				2: {XY: [2]int{25, 0}, W: 20, H: 20},
+				3: {XY: [2]int{0, 25}, W: 20, H: 20},
+			},
+		}
+		layout.OverlapRemovalLayout{Gap: 5}.UpdateGraphLayout(g)
+		if xy := g.Nodes[2].XY; xy != [2]int{25, 0} {
+			t.Errorf("expected node to stay, got %v", xy)
+		}
+		if xy := g.Nodes[3].XY; xy != [2]int{0, 25} {
+			t.Errorf("expected node to stay, got %v", xy)
+		}
+	})
+}
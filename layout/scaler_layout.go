@@ -7,14 +7,11 @@ type ScalerLayout struct {
 
 func (l *ScalerLayout) UpdateGraphLayout(g Graph) {
 	for i := range g.Nodes {
-		x := float64(g.Nodes[i].XY[0])
-		y := float64(g.Nodes[i].XY[1])
-
-		g.Nodes[i] = Node{
-			XY: [2]int{int(x * l.Scale), int(y * l.Scale)},
-			W:  g.Nodes[i].W,
-			H:  g.Nodes[i].H,
-		}
+		node := g.Nodes[i]
+		x := float64(node.XY[0])
+		y := float64(node.XY[1])
+		node.XY = [2]int{int(x * l.Scale), int(y * l.Scale)}
+		g.Nodes[i] = node
 	}
 
 	// can not recompute edge layout as some paths are complex and not direct
@@ -24,15 +21,37 @@ func (l *ScalerLayout) UpdateGraphLayout(g Graph) {
 			y := float64(xy[1])
 			g.Edges[e].Path[p] = [2]int{int(x * l.Scale), int(y * l.Scale)}
 		}
+		for p, xy := range g.Edges[e].Curve {
+			x := float64(xy[0])
+			y := float64(xy[1])
+			g.Edges[e].Curve[p] = [2]int{int(x * l.Scale), int(y * l.Scale)}
+		}
 
 		// if edge was not previously set adding at least two nodes for start and end
-		if len(g.Edges[e].Path) == 0 {
-			g.Edges[e] = Edge{Path: make([][2]int, 2)}
+		edge := g.Edges[e]
+		if len(edge.Path) == 0 {
+			edge.Path = make([][2]int, 2)
 		}
 
-		// end and start should use center coordinates of nodes
-		// note, this overrites ports for edges
-		g.Edges[e].Path[0] = g.Nodes[e[0]].CenterXY()
-		g.Edges[e].Path[len(g.Edges[e].Path)-1] = g.Nodes[e[1]].CenterXY()
+		// end and start should use ports of nodes, which are not scaled with nodes
+		edge.Path[0] = g.Nodes[e[0]].PortXY(edge.FromPort)
+		edge.Path[len(edge.Path)-1] = g.Nodes[e[1]].PortXY(edge.ToPort)
+		if len(edge.Curve) > 0 {
+			edge.Curve[0] = edge.Path[0]
+			edge.Curve[len(edge.Curve)-1] = edge.Path[len(edge.Path)-1]
+		}
+
+		// labels keep size, same as nodes
+		edge.Label.XY = [2]int{int(float64(edge.Label.XY[0]) * l.Scale), int(float64(edge.Label.XY[1]) * l.Scale)}
+		g.Edges[e] = edge
+	}
+}
+
+// UpdateGraphLayoutE validates graph and updates its layout.
+func (l *ScalerLayout) UpdateGraphLayoutE(g Graph) error {
+	if err := g.Validate(); err != nil {
+		return err
 	}
+	l.UpdateGraphLayout(g)
+	return nil
 }
@@ -0,0 +1,218 @@
+package layout
+
+import (
+	"container/heap"
+	"context"
+	"math"
+	"time"
+)
+
+// StressMajorizationLayout places nodes so that distances between them are close to shortest paths between them in graph.
+// Stress is sum of (distance - path length)^2 / path length^2 over all pairs of nodes, it is minimized by majorization.
+// Path of edge has length of Edge.Length edge lengths. Nodes in different components are at distance of longest path plus edge length.
+// Nodes are moved by their centers, nodes in same position are spread around it first.
+// time: O(V * E log V) for paths and O(V^2) per step, memory: O(V^2)
+type StressMajorizationLayout struct {
+	EdgeLength float64  // length of path of single edge, zero is twice average diagonal of nodes
+	Epsilon    float64  // stop when stress decreases by less than this fraction of it, zero is 1e-4
+	MaxSteps   int      // limit of iterations
+	Observer   Observer // receives stress after each step
+}
+
+// UpdateGraphLayoutContext stops iterations when context is done, keeping current positions.
+func (l StressMajorizationLayout) UpdateGraphLayoutContext(ctx context.Context, g Graph) error {
+	if err := g.Validate(); err != nil {
+		return err
+	}
+	l.updateGraphLayout(ctx, g)
+	return nil
+}
+
+func (l StressMajorizationLayout) UpdateGraphLayout(g Graph) {
+	l.updateGraphLayout(context.Background(), g)
+}
+
+// UpdateGraphLayoutE validates graph and updates its layout until completion.
+func (l StressMajorizationLayout) UpdateGraphLayoutE(g Graph) error {
+	return l.UpdateGraphLayoutContext(context.Background(), g)
+}
+
+func (l StressMajorizationLayout) updateGraphLayout(ctx context.Context, g Graph) {
+	nodes := g.nodeIDs()
+	if len(nodes) == 0 {
+		return
+	}
+	epsilon := l.Epsilon
+	if epsilon <= 0 {
+		epsilon = 1e-4
+	}
+	d := l.distances(g, nodes)
+	center := spreadCenters(g, nodes, l.edgeLength(g))
+	pos := make([][2]float64, len(nodes))
+	for i, n := range nodes {
+		pos[i] = center[n]
+	}
+
+	start := time.Now()
+	stress := pathStress(pos, d)
+	for step := 0; step < l.MaxSteps && ctx.Err() == nil; step++ {
+		// each node is moved to weighted average of positions where it has distances of paths to other nodes
+		for i := range pos {
+			var sum [2]float64
+			weights := 0.0
+			for j := range pos {
+				dij := d[i*len(pos)+j]
+				if i == j || dij == 0 {
+					continue
+				}
+				w := 1 / (dij * dij)
+				dx, dy := pos[i][0]-pos[j][0], pos[i][1]-pos[j][1]
+				r := math.Hypot(dx, dy)
+				if r > 0 {
+					dx, dy = dx*dij/r, dy*dij/r
+				}
+				sum[0] += w * (pos[j][0] + dx)
+				sum[1] += w * (pos[j][1] + dy)
+				weights += w
+			}
+			if weights > 0 {
+				pos[i] = [2]float64{sum[0] / weights, sum[1] / weights}
+			}
+		}
+
+		prev := stress
+		stress = pathStress(pos, d)
+		observe(l.Observer, Event{Phase: PhaseStressStep, Step: step, Stress: stress, Elapsed: time.Since(start)})
+
+		if prev-stress <= epsilon*prev {
+			break
+		}
+	}
+
+	for i, n := range nodes {
+		node := g.Nodes[n]
+		node.XY = [2]int{int(math.Round(pos[i][0])) - node.W/2, int(math.Round(pos[i][1])) - node.H/2}
+		g.Nodes[n] = node
+	}
+}
+
+// Stress is stress of current positions of centers of nodes, same as reported after steps of layout.
+func (l StressMajorizationLayout) Stress(g Graph) float64 {
+	nodes := g.nodeIDs()
+	pos := make([][2]float64, len(nodes))
+	for i, n := range nodes {
+		c := g.Nodes[n].CenterXY()
+		pos[i] = [2]float64{float64(c[0]), float64(c[1])}
+	}
+	return pathStress(pos, l.distances(g, nodes))
+}
+
+func (l StressMajorizationLayout) edgeLength(g Graph) float64 {
+	if l.EdgeLength > 0 {
+		return l.EdgeLength
+	}
+	return naturalEdgeLength(g)
+}
+
+// pathStress is stress of positions for distances of paths between them, d is row by row matrix.
+func pathStress(pos [][2]float64, d []float64) float64 {
+	stress := 0.0
+	for i := range pos {
+		for j := i + 1; j < len(pos); j++ {
+			if dij := d[i*len(pos)+j]; dij > 0 {
+				r := math.Hypot(pos[i][0]-pos[j][0], pos[i][1]-pos[j][1]) - dij
+				stress += r * r / (dij * dij)
+			}
+		}
+	}
+	return stress
+}
+
+// distances are lengths of shortest paths between nodes in order of nodes, as row by row matrix.
+// Edges are undirected. Nodes in different components are at longest distance plus edge length.
+func (l StressMajorizationLayout) distances(g Graph, nodes []uint64) []float64 {
+	k := l.edgeLength(g)
+	index := make(map[uint64]int, len(nodes))
+	for i, n := range nodes {
+		index[n] = i
+	}
+	type arc struct {
+		to     int
+		length float64
+	}
+	adjacent := make([][]arc, len(nodes))
+	for _, e := range g.edgeIDs() {
+		if e.isSelfLoop() {
+			continue
+		}
+		length := k
+		if m := g.Edges[e].Length; m > 0 {
+			length = k * m
+		}
+		u, v := index[e[0]], index[e[1]]
+		adjacent[u] = append(adjacent[u], arc{to: v, length: length})
+		adjacent[v] = append(adjacent[v], arc{to: u, length: length})
+	}
+
+	n := len(nodes)
+	d := make([]float64, n*n)
+	for i := range d {
+		d[i] = math.Inf(1)
+	}
+	longest := 0.0
+	for s := 0; s < n; s++ {
+		row := d[s*n : (s+1)*n]
+		row[s] = 0
+		que := &distanceHeap{{node: s}}
+		for que.Len() > 0 {
+			p := heap.Pop(que).(distanceItem)
+			if p.dist > row[p.node] {
+				continue
+			}
+			for _, a := range adjacent[p.node] {
+				if v := p.dist + a.length; v < row[a.to] {
+					row[a.to] = v
+					heap.Push(que, distanceItem{node: a.to, dist: v})
+				}
+			}
+		}
+		for _, v := range row {
+			if !math.IsInf(v, 1) && v > longest {
+				longest = v
+			}
+		}
+	}
+	for i, v := range d {
+		if math.IsInf(v, 1) {
+			d[i] = longest + k
+		}
+	}
+	return d
+}
+
+type distanceItem struct {
+	node int
+	dist float64
+}
+
+type distanceHeap []distanceItem
+
+func (h distanceHeap) Len() int { return len(h) }
+
+func (h distanceHeap) Less(i, j int) bool {
+	if h[i].dist != h[j].dist {
+		return h[i].dist < h[j].dist
+	}
+	return h[i].node < h[j].node
+}
+
+func (h distanceHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *distanceHeap) Push(x interface{}) { *h = append(*h, x.(distanceItem)) }
+
+func (h *distanceHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
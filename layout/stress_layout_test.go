@@ -0,0 +1,70 @@
+package layout_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/nikolaydubina/go-graph-layout/layout"
+)
+
+func TestStressMajorizationLayout(t *testing.T) {
+	dist := func(g layout.Graph, a, b uint64) float64 {
+		p, q := g.Nodes[a].CenterXY(), g.Nodes[b].CenterXY()
+		return math.Hypot(float64(p[0]-q[0]), float64(p[1]-q[1]))
+	}
+
+	t.Run("path", func(t *testing.T) {
+		g := layout.Graph{Nodes: map[uint64]layout.Node{}, Edges: map[layout.EdgeID]layout.Edge{}}
+		for i := uint64(0); i < 6; i++ {
+			g.Nodes[i] = layout.Node{W: 20, H: 20}
+			if i > 0 {
+				g.Edges[layout.EdgeID{i - 1, i}] = layout.Edge{}
+			}
+		}
+
+		var stress []float64
+		l := layout.StressMajorizationLayout{
+			EdgeLength: 100,
+			MaxSteps:   500,
+			Observer:   layout.ObserverFunc(func(e layout.Event) { stress = append(stress, e.Stress) }),
+		}
+		l.UpdateGraphLayout(g)
+
+		for i := 1; i < len(stress); i++ {
+			if stress[i] > stress[i-1] {
+				t.Errorf("stress increased at step %d: %v", i, stress)
+			}
+		}
+		if s := stress[len(stress)-1]; s > 1e-3 {
+			t.Errorf("expected path on straight line, got stress %f", s)
+		}
+		if s := l.Stress(g); math.Abs(s-stress[len(stress)-1]) > 1e-3 {
+			t.Errorf("expected reported stress %f, got %f", stress[len(stress)-1], s)
+		}
+		// distances are lengths of paths
+		for i := uint64(1); i < 6; i++ {
+			if d := dist(g, 0, i); math.Abs(d-100*float64(i)) > 2 {
+				t.Errorf("expected distance %d to node %d, got %f", 100*i, i, d)
+			}
+		}
+	})
+
+	t.Run("edge length", func(t *testing.T) {
+		g := layout.Graph{
+			Nodes: map[uint64]layout.Node{1: {}, 2: {}, 3: {}, 4: {}},
+			Edges: map[layout.EdgeID]layout.Edge{{1, 2}: {Length: 3}, {3, 4}: {MinLen: 3}},
+		}
+		layout.StressMajorizationLayout{EdgeLength: 50, MaxSteps: 500}.UpdateGraphLayout(g)
+
+		if d := dist(g, 1, 2); math.Abs(d-150) > 2 {
+			t.Errorf("expected edge of length 150, got %f", d)
+		}
+		// other component is at longest distance plus edge length
+		if d := dist(g, 3, 4); math.Abs(d-50) > 20 {
+			t.Errorf("expected edge of length 50 regardless of layers it spans, got %f", d)
+		}
+		if d := dist(g, 1, 3); d < 100 {
+			t.Errorf("expected components apart, got distance %f", d)
+		}
+	})
+}
@@ -0,0 +1,30 @@
+package svg
+
+import (
+	"fmt"
+	"html"
+)
+
+// Cluster is box around group of nodes, with title in top left corner.
+type Cluster struct {
+	ID    string // used to make DOM IDs
+	XY    [2]int // lowest X and Y coordinate of box
+	W     int
+	H     int
+	Title string
+}
+
+func (c Cluster) Render() string {
+	return fmt.Sprintf(
+		`<g id="svg:graph:cluster:%s"><rect x="%d" y="%d" width="%d" height="%d" rx="5" style="fill:none;stroke-width:1;stroke:gray;stroke-dasharray:4 2;"></rect><text x="%d" y="%d" dominant-baseline="hanging" style="font-size: %dpx; fill: gray;">%s</text></g>`,
+		c.ID,
+		c.XY[0],
+		c.XY[1],
+		c.W,
+		c.H,
+		c.XY[0]+padding/2,
+		c.XY[1]+padding/2,
+		nodeFontSize,
+		html.EscapeString(c.Title),
+	)
+}
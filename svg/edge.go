@@ -2,18 +2,82 @@ package svg
 
 import (
 	"fmt"
+	"html"
 	"strings"
 )
 
 // Edge is polylines of straight lines going through all points.
+// When Curve is set, edge is piecewise cubic Bezier curve instead.
+// Head and Tail reference markers that have to be in SVG.Definitions.
 type Edge struct {
-	Path [][2]int
+	Path  [][2]int
+	Curve [][2]int // [start, {control, control, end}, ...]
+	Head  Arrow    // marker at end of edge
+	Tail  Arrow    // marker at start of edge
+	Label EdgeLabel
 }
 
 func (e Edge) Render() string {
+	if e.Label.Text == "" {
+		return e.renderLine()
+	}
+	return e.renderLine() + "\n" + e.Label.Render()
+}
+
+func (e Edge) renderLine() string {
+	if len(e.Curve) >= 4 {
+		return e.renderCurve()
+	}
+
 	var points []string
 	for _, point := range e.Path {
 		points = append(points, fmt.Sprintf("%d,%d", point[0], point[1]))
 	}
-	return fmt.Sprintf(`<polyline style="fill:none;stroke-width:1;stroke:black;" points="%s"></polyline>`, strings.Join(points, " "))
+	return fmt.Sprintf(`<polyline style="fill:none;stroke-width:1;stroke:black;" points="%s"%s></polyline>`, strings.Join(points, " "), e.markers())
+}
+
+func (e Edge) renderCurve() string {
+	var points []string
+	for _, point := range e.Curve[1:] {
+		points = append(points, fmt.Sprintf("%d,%d", point[0], point[1]))
+	}
+	d := fmt.Sprintf("M %d,%d C %s", e.Curve[0][0], e.Curve[0][1], strings.Join(points, " "))
+	return fmt.Sprintf(`<path style="fill:none;stroke-width:1;stroke:black;" d="%s"%s></path>`, d, e.markers())
+}
+
+func (e Edge) markers() string {
+	var s string
+	if e.Tail != ArrowNone {
+		s += fmt.Sprintf(` marker-start="url(#%s)"`, MarkerID(e.Tail))
+	}
+	if e.Head != ArrowNone {
+		s += fmt.Sprintf(` marker-end="url(#%s)"`, MarkerID(e.Head))
+	}
+	return s
+}
+
+// EdgeLabel is text of edge in box.
+// Width and Height are size of box, to be used in layout.
+type EdgeLabel struct {
+	XY   [2]int // lowest X and Y coordinate of label box
+	Text string
+}
+
+func (l EdgeLabel) Width() int {
+	return int(float64(nodeFontSize*len(l.Text))*textWidthMultiplier) + padding
+}
+
+func (l EdgeLabel) Height() int {
+	return nodeFontSize*textHeightMultiplier + padding
+}
+
+// Render text in middle of box, with white outline so that it is readable over edges.
+func (l EdgeLabel) Render() string {
+	return fmt.Sprintf(
+		`<text x="%d" y="%d" text-anchor="middle" dominant-baseline="central" style="font-size: %dpx; fill: black; stroke: white; stroke-width: 3px; paint-order: stroke;">%s</text>`,
+		l.XY[0]+l.Width()/2,
+		l.XY[1]+l.Height()/2,
+		nodeFontSize,
+		html.EscapeString(l.Text),
+	)
 }
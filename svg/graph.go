@@ -2,14 +2,16 @@ package svg
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 )
 
 // Graph is rendered graph.
 type Graph struct {
-	ID    string
-	Nodes map[uint64]Node
-	Edges map[[2]uint64]Edge
+	ID       string
+	Nodes    map[uint64]Node
+	Edges    map[[3]uint64]Edge // {from, to, number of edge between same nodes}
+	Clusters map[uint64]Cluster
 }
 
 // Render creates root svg element
@@ -18,13 +20,42 @@ func (g Graph) Render() string {
 		fmt.Sprintf(`<g id="%s">`, g.ID),
 	}
 
-	for _, edge := range g.Edges {
-		body = append(body, edge.Render())
+	// clusters are under edges and nodes
+	clusters := make([]uint64, 0, len(g.Clusters))
+	for c := range g.Clusters {
+		clusters = append(clusters, c)
 	}
+	sort.Slice(clusters, func(i, j int) bool { return clusters[i] < clusters[j] })
+	for _, c := range clusters {
+		body = append(body, g.Clusters[c].Render())
+	}
+
+	// sorted by ids, so that same graph renders same svg
+	edges := make([][3]uint64, 0, len(g.Edges))
+	for e := range g.Edges {
+		edges = append(edges, e)
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		for k := range edges[i] {
+			if edges[i][k] != edges[j][k] {
+				return edges[i][k] < edges[j][k]
+			}
+		}
+		return false
+	})
+	for _, e := range edges {
+		body = append(body, g.Edges[e].Render())
+	}
+
+	nodes := make([]uint64, 0, len(g.Nodes))
+	for n := range g.Nodes {
+		nodes = append(nodes, n)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i] < nodes[j] })
 
 	// draw nodes always on top of edges
-	for _, node := range g.Nodes {
-		body = append(body, node.Render())
+	for _, n := range nodes {
+		body = append(body, g.Nodes[n].Render())
 	}
 
 	body = append(body, "</g>")
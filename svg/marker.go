@@ -0,0 +1,67 @@
+package svg
+
+import "fmt"
+
+// Arrow is style of marker at head or tail of edge.
+type Arrow uint8
+
+const (
+	ArrowNone Arrow = iota
+	ArrowNormal
+	ArrowOpen
+	ArrowDiamond
+	ArrowDot
+)
+
+// Arrows are all styles of markers.
+var Arrows = []Arrow{ArrowNormal, ArrowOpen, ArrowDiamond, ArrowDot}
+
+func (a Arrow) String() string {
+	switch a {
+	case ArrowNormal:
+		return "normal"
+	case ArrowOpen:
+		return "open"
+	case ArrowDiamond:
+		return "diamond"
+	case ArrowDot:
+		return "dot"
+	default:
+		return "none"
+	}
+}
+
+// Marker is definition of arrow to be referenced by edges.
+// Tip of arrow is at end of edge, and it is turned to point out of edge at start too.
+type Marker struct {
+	Arrow Arrow
+}
+
+// MarkerID is DOM ID of marker of arrow, without colons so that it can be used in url().
+func MarkerID(a Arrow) string {
+	return fmt.Sprintf("svg-marker-arrow-%s", a)
+}
+
+func (m Marker) Render() string {
+	var shape string
+	switch m.Arrow {
+	case ArrowNormal:
+		shape = `<path d="M 0 0 L 10 5 L 0 10 z" style="fill:black;stroke:none;"></path>`
+	case ArrowOpen:
+		shape = `<path d="M 0 0 L 10 5 L 0 10" style="fill:none;stroke-width:1;stroke:black;"></path>`
+	case ArrowDiamond:
+		shape = `<path d="M 0 5 L 5 0 L 10 5 L 5 10 z" style="fill:black;stroke:none;"></path>`
+	case ArrowDot:
+		shape = `<circle cx="5" cy="5" r="5" style="fill:black;stroke:none;"></circle>`
+	}
+	return fmt.Sprintf(`<marker id="%s" viewBox="0 0 10 10" refX="10" refY="5" markerWidth="8" markerHeight="8" orient="auto-start-reverse">%s</marker>`, MarkerID(m.Arrow), shape)
+}
+
+// Markers are definitions for all styles of arrows, to be added to SVG.Definitions.
+func Markers() []Renderable {
+	defs := make([]Renderable, 0, len(Arrows))
+	for _, a := range Arrows {
+		defs = append(defs, Marker{Arrow: a})
+	}
+	return defs
+}